@@ -0,0 +1,291 @@
+package command
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// archiveFormats are the archive formats that binaries can be packaged in
+var archiveFormats = []string{"tar.gz", "tar.xz", "tar.zst", "zip"}
+
+// archiveTime is used as the modification time of every archive entry, unless there is a SOURCE_DATE_EPOCH, so that
+// archives do not depend on when they were built
+var archiveTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type archiveFile struct {
+	Source string
+	Name   string
+	Mode   os.FileMode
+}
+
+// writeArchive packages files under a top level directory in an archive of the given format.  The entries are sorted
+// and have modTime and no owner so that the same files always make the same archive.
+func writeArchive(format, archivePath, directory string, files []archiveFile, modTime time.Time) error {
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+
+	if format == "zip" {
+		err = writeZip(archive, directory, files, modTime)
+	} else {
+		err = writeCompressedTar(archive, format, directory, files, modTime)
+	}
+
+	closeErr := archive.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(archivePath)
+	}
+
+	return err
+}
+
+func writeCompressedTar(writer io.Writer, format, directory string, files []archiveFile, modTime time.Time) error {
+	var compressor io.WriteCloser
+	switch format {
+	case "tar.gz":
+		gzipWriter, err := gzip.NewWriterLevel(writer, gzip.BestCompression)
+		if err != nil {
+			return err
+		}
+
+		compressor = gzipWriter
+	case "tar.xz":
+		xzWriter, err := xz.NewWriter(writer)
+		if err != nil {
+			return err
+		}
+
+		compressor = xzWriter
+	case "tar.zst":
+		// A single encoder goroutine keeps the output the same on every run
+		zstdWriter, err := zstd.NewWriter(writer, zstd.WithEncoderLevel(zstd.SpeedBestCompression), zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return err
+		}
+
+		compressor = zstdWriter
+	default:
+		return fmt.Errorf("Unknown archive format %s", format)
+	}
+
+	err := writeTar(compressor, directory, files, modTime)
+	if err != nil {
+		return err
+	}
+
+	return compressor.Close()
+}
+
+func writeTar(writer io.Writer, directory string, files []archiveFile, modTime time.Time) error {
+	tarWriter := tar.NewWriter(writer)
+	err := tarWriter.WriteHeader(tarHeader(fmt.Sprintf("%s/", directory), 0755, tar.TypeDir, 0, modTime))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range sortedArchiveFiles(files) {
+		err = addTarFile(tarWriter, directory, file, modTime)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tarWriter.Close()
+}
+
+func addTarFile(tarWriter *tar.Writer, directory string, file archiveFile, modTime time.Time) error {
+	source, err := os.Open(file.Source)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = source.Close()
+	}()
+
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = tarWriter.WriteHeader(tarHeader(path.Join(directory, file.Name), int64(file.Mode), tar.TypeReg, info.Size(), modTime))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tarWriter, source)
+	return err
+}
+
+// tarHeader is owned by root with no user or group names so that the archive does not depend on who built it
+func tarHeader(name string, mode int64, typeflag byte, size int64, modTime time.Time) *tar.Header {
+	return &tar.Header{
+		Name:     name,
+		Mode:     mode,
+		Size:     size,
+		Typeflag: typeflag,
+		ModTime:  modTime,
+		Uid:      0,
+		Gid:      0,
+		Uname:    "",
+		Gname:    "",
+	}
+}
+
+func writeZip(writer io.Writer, directory string, files []archiveFile, modTime time.Time) error {
+	zipWriter := zip.NewWriter(writer)
+	directoryHeader := &zip.FileHeader{Name: fmt.Sprintf("%s/", directory), Modified: modTime}
+	directoryHeader.SetMode(os.ModeDir | 0755)
+	_, err := zipWriter.CreateHeader(directoryHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range sortedArchiveFiles(files) {
+		err = addZipFile(zipWriter, directory, file, modTime)
+		if err != nil {
+			return err
+		}
+	}
+
+	return zipWriter.Close()
+}
+
+func addZipFile(zipWriter *zip.Writer, directory string, file archiveFile, modTime time.Time) error {
+	source, err := os.Open(file.Source)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = source.Close()
+	}()
+
+	header := &zip.FileHeader{Name: path.Join(directory, file.Name), Method: zip.Deflate, Modified: modTime}
+	header.SetMode(file.Mode)
+	entry, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(entry, source)
+	return err
+}
+
+// sortedArchiveFiles orders the archive entries by name so the archive does not depend on the order files were found in
+func sortedArchiveFiles(files []archiveFile) []archiveFile {
+	sorted := append([]archiveFile(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted
+}
+
+// resolveArchiveFiles expands the configured extra files relative to mainPath.  Directories are added recursively
+// and a pattern that does not match anything is an error unless the file is optional.
+func resolveArchiveFiles(mainPath string, configured []archiveFileConfig) ([]archiveFile, error) {
+	files := []archiveFile{}
+	for _, file := range configured {
+		matches, err := filepath.Glob(filepath.Join(mainPath, file.Source))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pattern %s: %v", file.Source, err)
+		}
+
+		if len(matches) == 0 {
+			if file.Optional {
+				continue
+			}
+
+			return nil, fmt.Errorf("No files match %s", file.Source)
+		}
+
+		sort.Strings(matches)
+		for _, match := range matches {
+			name, err := filepath.Rel(mainPath, match)
+			if err != nil {
+				return nil, err
+			}
+
+			if file.Destination != "" {
+				if len(matches) == 1 && !strings.HasSuffix(file.Destination, "/") {
+					name = file.Destination
+				} else {
+					name = path.Join(file.Destination, filepath.Base(match))
+				}
+			}
+
+			found, err := walkArchiveFiles(match, path.Clean(filepath.ToSlash(name)))
+			if err != nil {
+				return nil, err
+			}
+
+			files = append(files, found...)
+		}
+	}
+
+	return files, nil
+}
+
+func walkArchiveFiles(source, name string) ([]archiveFile, error) {
+	files := []archiveFile{}
+	err := filepath.Walk(source, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		relative, err := filepath.Rel(source, filePath)
+		if err != nil {
+			return err
+		}
+
+		mode := os.FileMode(0644)
+		if info.Mode()&0111 != 0 {
+			mode = 0755
+		}
+
+		files = append(files, archiveFile{Source: filePath, Name: path.Join(name, filepath.ToSlash(relative)), Mode: mode})
+		return nil
+	})
+
+	return files, err
+}
+
+// checkArchiveFiles makes sure no two files would be written to the same place in an archive
+func checkArchiveFiles(files []archiveFile) error {
+	seen := make(map[string]string, len(files))
+	for _, file := range files {
+		if other, ok := seen[file.Name]; ok {
+			return fmt.Errorf("Both %s and %s would be archived as %s", other, file.Source, file.Name)
+		}
+
+		seen[file.Name] = file.Source
+	}
+
+	return nil
+}
+
+func isArchiveFormat(format string) bool {
+	return contains(archiveFormats, format)
+}
+
+func archiveFormatNames() string {
+	return strings.Join(archiveFormats, ", ")
+}
@@ -0,0 +1,288 @@
+package command_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/ulikunitz/xz"
+	"github.com/urfave/cli"
+)
+
+// largeBinary compresses well, so the archives of it are much smaller
+var largeBinary = strings.Repeat("\x00", 1<<20)
+
+type archiveEntry struct {
+	Name    string
+	Mode    os.FileMode
+	ModTime time.Time
+	Content string
+}
+
+func TestReleaseArchives(t *testing.T) {
+	uploads := make(map[string][]byte)
+	ts := getReleaseTestServerWithUploads(t, "", "", uploads)
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeTestFile(t, distFile(mainPath, "darwin", "amd64", "projectName-darwin-amd64-go1.8-tag"), largeBinary, 0777)
+	writeTestFile(t, distFile(mainPath, "solaris", "amd64", "projectName-solaris-amd64-go1.8-tag"), largeBinary, 0777)
+	writeConfig(t, mainPath, "builds:\n  arch: [amd64]\narchives:\n  formats:\n    darwin: tar.xz\n    solaris: tar.zst\n")
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return architecture == "amd64"
+	})
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+	assert.Equal(t, "", errWriter.String())
+
+	epoch := time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(
+		t,
+		[]archiveEntry{
+			{Name: "projectName-linux-amd64-go1.8-tag/", Mode: os.ModeDir | 0755, ModTime: epoch},
+			{Name: "projectName-linux-amd64-go1.8-tag/projectName", Mode: 0755, ModTime: epoch, Content: "foo"},
+		},
+		readTarGz(t, uploads["projectName-linux-amd64-go1.8-tag.tar.gz"]),
+	)
+	assert.Equal(
+		t,
+		[]archiveEntry{
+			{Name: "projectName-windows-amd64-go1.8-tag/", Mode: os.ModeDir | 0755, ModTime: epoch},
+			{Name: "projectName-windows-amd64-go1.8-tag/projectName.exe", Mode: 0755, ModTime: epoch, Content: "foo"},
+		},
+		readZip(t, uploads["projectName-windows-amd64-go1.8-tag.zip"]),
+	)
+	assert.Equal(
+		t,
+		[]archiveEntry{
+			{Name: "projectName-darwin-amd64-go1.8-tag/", Mode: os.ModeDir | 0755, ModTime: epoch},
+			{Name: "projectName-darwin-amd64-go1.8-tag/projectName", Mode: 0755, ModTime: epoch, Content: largeBinary},
+		},
+		readTarXz(t, uploads["projectName-darwin-amd64-go1.8-tag.tar.xz"]),
+	)
+	assert.Equal(
+		t,
+		[]archiveEntry{
+			{Name: "projectName-solaris-amd64-go1.8-tag/", Mode: os.ModeDir | 0755, ModTime: epoch},
+			{Name: "projectName-solaris-amd64-go1.8-tag/projectName", Mode: 0755, ModTime: epoch, Content: largeBinary},
+		},
+		readTarZst(t, uploads["projectName-solaris-amd64-go1.8-tag.tar.zst"]),
+	)
+	assert.True(t, len(uploads["projectName-darwin-amd64-go1.8-tag.tar.xz"]) < len(largeBinary)/100)
+	assert.True(t, len(uploads["projectName-solaris-amd64-go1.8-tag.tar.zst"]) < len(largeBinary)/100)
+	assert.Equal(t, 6, len(uploads))
+}
+
+func TestReleaseArchiveFiles(t *testing.T) {
+	uploads := make(map[string][]byte)
+	ts := getReleaseTestServerWithUploads(t, "", "", uploads)
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeTestFile(t, fmt.Sprintf("%s/README.md", mainPath), "readme", 0644)
+	writeTestFile(t, fmt.Sprintf("%s/LICENSE", mainPath), "license", 0644)
+	writeTestFile(t, fmt.Sprintf("%s/autocomplete/bash", mainPath), "bash", 0644)
+	writeTestFile(t, fmt.Sprintf("%s/autocomplete/zsh", mainPath), "zsh", 0755)
+	writeTestFile(t, fmt.Sprintf("%s/LICENSE.windows.txt", mainPath), "windows license", 0644)
+	writeConfig(
+		t,
+		mainPath,
+		`builds:
+  os: [linux, windows]
+  arch: [amd64]
+archives:
+  files:
+    - README.md
+    - src: autocomplete/*
+      dst: completion/
+    - src: LICENSE
+      dst: doc/COPYING
+    - src: CHANGELOG.md
+      optional: true
+  overrides:
+    windows:
+      files:
+        - src: LICENSE.windows.txt
+          dst: LICENSE.txt
+`,
+	)
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return architecture == "amd64" && (operatingSystem == "linux" || operatingSystem == "windows")
+	})
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+	assert.Equal(t, "", errWriter.String())
+
+	epoch := time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(
+		t,
+		[]archiveEntry{
+			{Name: "projectName-linux-amd64-go1.8-tag/", Mode: os.ModeDir | 0755, ModTime: epoch},
+			{Name: "projectName-linux-amd64-go1.8-tag/README.md", Mode: 0644, ModTime: epoch, Content: "readme"},
+			{Name: "projectName-linux-amd64-go1.8-tag/completion/bash", Mode: 0644, ModTime: epoch, Content: "bash"},
+			{Name: "projectName-linux-amd64-go1.8-tag/completion/zsh", Mode: 0755, ModTime: epoch, Content: "zsh"},
+			{Name: "projectName-linux-amd64-go1.8-tag/doc/COPYING", Mode: 0644, ModTime: epoch, Content: "license"},
+			{Name: "projectName-linux-amd64-go1.8-tag/projectName", Mode: 0755, ModTime: epoch, Content: "foo"},
+		},
+		readTarGz(t, uploads["projectName-linux-amd64-go1.8-tag.tar.gz"]),
+	)
+	assert.Equal(
+		t,
+		[]archiveEntry{
+			{Name: "projectName-windows-amd64-go1.8-tag/", Mode: os.ModeDir | 0755, ModTime: epoch},
+			{Name: "projectName-windows-amd64-go1.8-tag/LICENSE.txt", Mode: 0644, ModTime: epoch, Content: "windows license"},
+			{Name: "projectName-windows-amd64-go1.8-tag/projectName.exe", Mode: 0755, ModTime: epoch, Content: "foo"},
+		},
+		readZip(t, uploads["projectName-windows-amd64-go1.8-tag.zip"]),
+	)
+	assert.Equal(t, 4, len(uploads))
+}
+
+func TestReleaseArchiveFilesErrors(t *testing.T) {
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	testCases := []struct {
+		config string
+		err    string
+	}{
+		{"archives:\n  files: [CHANGELOG.md]\n", "Could not find archive files for linux: No files match CHANGELOG.md"},
+		{"archives:\n  files:\n    - src: README.md\n      dst: projectName\n", "Could not package linux/386: Both "},
+	}
+	for _, testCase := range testCases {
+		set := flag.NewFlagSet("test", 0)
+		set.String("token", "fakeToken", "doc")
+		set.String("mainPath", mainPath, "doc")
+		err = set.Parse([]string{"owner", "repo", "tag", "projectName"})
+		assert.Nil(t, err)
+		writeConfig(t, mainPath, testCase.config)
+		writeTestFile(t, fmt.Sprintf("%s/README.md", mainPath), "readme", 0644)
+		expectedRunner := &runner.Test{
+			ExpectedCommands: []*runner.ExpectedCommand{
+				getDistListCommand(t, mainPath),
+				runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+			},
+		}
+		app, _, _ := appWithTestWriters()
+		err = command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil))
+		if assert.NotNil(t, err, testCase.config) {
+			assert.Contains(t, err.Error(), testCase.err)
+		}
+
+		assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+		cleanUp(t, mainPath)
+	}
+}
+
+func writeTestFile(t *testing.T, fileName, content string, mode os.FileMode) {
+	t.Helper()
+	assert.Nil(t, os.MkdirAll(filepath.Dir(fileName), 0777))
+	assert.Nil(t, ioutil.WriteFile(fileName, []byte(content), mode))
+	assert.Nil(t, os.Chmod(fileName, mode))
+}
+
+func readTarGz(t *testing.T, data []byte) []archiveEntry {
+	t.Helper()
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	if !assert.Nil(t, err) {
+		return nil
+	}
+
+	return readTar(t, gzipReader)
+}
+
+func readTarXz(t *testing.T, data []byte) []archiveEntry {
+	t.Helper()
+	xzReader, err := xz.NewReader(bytes.NewReader(data))
+	if !assert.Nil(t, err) {
+		return nil
+	}
+
+	return readTar(t, xzReader)
+}
+
+func readTarZst(t *testing.T, data []byte) []archiveEntry {
+	t.Helper()
+	zstdReader, err := zstd.NewReader(bytes.NewReader(data))
+	if !assert.Nil(t, err) {
+		return nil
+	}
+
+	defer zstdReader.Close()
+	return readTar(t, zstdReader)
+}
+
+func readTar(t *testing.T, reader io.Reader) []archiveEntry {
+	t.Helper()
+	entries := []archiveEntry{}
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return entries
+		}
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, header.Uid)
+		assert.Equal(t, 0, header.Gid)
+		content := new(bytes.Buffer)
+		_, err = io.Copy(content, tarReader)
+		assert.Nil(t, err)
+		entries = append(entries, archiveEntry{header.Name, header.FileInfo().Mode(), header.ModTime.UTC(), content.String()})
+	}
+}
+
+func readZip(t *testing.T, data []byte) []archiveEntry {
+	t.Helper()
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if !assert.Nil(t, err) {
+		return nil
+	}
+
+	entries := []archiveEntry{}
+	for _, file := range zipReader.File {
+		reader, err := file.Open()
+		assert.Nil(t, err)
+		content := new(bytes.Buffer)
+		_, err = io.Copy(content, reader)
+		assert.Nil(t, err)
+		entries = append(entries, archiveEntry{file.Name, file.Mode(), file.Modified.UTC(), content.String()})
+	}
+
+	return entries
+}
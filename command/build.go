@@ -0,0 +1,91 @@
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/guywithnose/runner"
+	"github.com/urfave/cli"
+)
+
+// CmdBuild builds the binaries for a release into the dist directory and records them in its manifest
+func CmdBuild(cmdWrapper runner.Builder) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		return cmdBuildHelper(c, cmdWrapper)
+	}
+}
+
+func cmdBuildHelper(c *cli.Context, cmdWrapper runner.Builder) error {
+	mainPath, err := getMainPath(c)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(c.String("config"), mainPath)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	owner, repo, tagName, projectName, err := releaseArguments(c, cmdWrapper, cfg, mainPath, "Usage: \"goRelease build {owner} {repo} {tagName} {projectName} --dist {dist}\"")
+	if err != nil {
+		return err
+	}
+
+	dist := distDirectory(c, mainPath)
+	err = checkWorkingTree(cmdWrapper, mainPath, dist, tagName, c.Bool("allowDirty") || cfg.Release.AllowDirty, c.App.ErrWriter)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dist, 0755)
+	if err != nil {
+		return fmt.Errorf("Unable to create %s: %v", dist, err)
+	}
+
+	data, targets, err := prepareBuilds(c, cmdWrapper, cfg, mainPath, projectName, tagName, dist)
+	if err != nil {
+		return err
+	}
+
+	// The commit is always recorded so that publish can check the tag against what was built
+	if data.Commit == "" {
+		data.Commit, err = getCommit(cmdWrapper, mainPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	allowPartial, requiredTargets := partialOptions(c, cfg)
+	err = checkRequiredTargets(requiredTargets, targets)
+	if err != nil {
+		return err
+	}
+
+	buildConcurrency, err := concurrencyOption(c, "buildConcurrency", defaultBuildConcurrency)
+	if err != nil {
+		return err
+	}
+
+	manifest := newManifest(owner, repo, data, dist)
+	results := newReleaseResults(c.App.ErrWriter, targets)
+	eachTarget(targets, buildConcurrency, func(target buildTarget) []artifact {
+		if !runBuild(cmdWrapper, &target, mainPath, results) {
+			return nil
+		}
+
+		err := manifest.addBinary(target)
+		if err != nil {
+			results.failed(target.String(), stageBuild, "Unable to record binary %s: %v\n", target.FileName, err)
+		}
+
+		return nil
+	}, nil)
+
+	err = manifest.write()
+	if err != nil {
+		return fmt.Errorf("Unable to write manifest: %v", err)
+	}
+
+	results.writeSummary(c.App.Writer)
+	return results.err(allowPartial, requiredTargets)
+}
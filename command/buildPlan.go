@@ -0,0 +1,352 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/guywithnose/runner"
+)
+
+// templateData is available to the name, ldflags and gcflags templates.  SourceDateEpoch is 0 unless the build is
+// reproducible or SOURCE_DATE_EPOCH is set, Date is then the same time.
+type templateData struct {
+	Project         string
+	Tag             string
+	Version         string
+	Commit          string
+	Date            string
+	SourceDateEpoch int64
+	GoVersion       string
+	OS              string
+	Arch            string
+}
+
+// buildFlags are the go build flags that can be set globally or overridden per OS or os/arch target
+type buildFlags struct {
+	Ldflags   string   `yaml:"ldflags"`
+	Tags      []string `yaml:"tags"`
+	Trimpath  *bool    `yaml:"trimpath"`
+	Buildmode string   `yaml:"buildmode"`
+	Gcflags   string   `yaml:"gcflags"`
+}
+
+type buildTarget struct {
+	osBuildInfo
+	Architecture  string
+	FileName      string
+	Command       []string
+	Environment   []string
+	ArchivePath   string
+	Directory     string
+	BinaryName    string
+	ExtraFiles    []archiveFile
+	Data          templateData
+	SBOMs         []sbomFile
+	BuildStarted  time.Time
+	BuildFinished time.Time
+}
+
+func (target buildTarget) String() string {
+	return targetName(target.OperatingSystem, target.Architecture)
+}
+
+// archiveFiles lists everything that goes in the target's archive
+func (target buildTarget) archiveFiles() []archiveFile {
+	files := []archiveFile{{Source: target.FileName, Name: target.BinaryName, Mode: 0755}}
+	return append(files, target.ExtraFiles...)
+}
+
+// getTemplateData gathers the values that the build templates can use.  The commit is only looked up when a template or
+// the provenance uses it.
+func getTemplateData(cmdWrapper runner.Builder, cfg *config, mainPath, goExecutable, projectName, tagName string) (templateData, error) {
+	data := templateData{
+		Project:   projectName,
+		Tag:       tagName,
+		Version:   strings.TrimPrefix(tagName, "v"),
+		Date:      time.Now().UTC().Format(time.RFC3339),
+		GoVersion: getGoVersion(cmdWrapper, mainPath, goExecutable),
+	}
+
+	epoch, err := sourceDateEpoch(cmdWrapper, mainPath, tagName, cfg.Builds.Reproducible)
+	if err != nil {
+		return data, err
+	}
+
+	if epoch != 0 {
+		data.SourceDateEpoch = epoch
+		data.Date = time.Unix(epoch, 0).UTC().Format(time.RFC3339)
+	}
+
+	if cfg.usesVariable("Commit") || cfg.Provenance.enabled() {
+		data.Commit, err = getCommit(cmdWrapper, mainPath)
+		if err != nil {
+			return data, err
+		}
+	}
+
+	return data, nil
+}
+
+func getCommit(cmdWrapper runner.Builder, mainPath string) (string, error) {
+	output, err := cmdWrapper.New(mainPath, "git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("Unable to determine commit: %v", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// sourceDateEpoch is the time that reproducible builds are stamped with, see https://reproducible-builds.org/specs/source-date-epoch/.
+// SOURCE_DATE_EPOCH is used when it is set, otherwise reproducible builds use the time the tag was committed.  A tag
+// that does not exist yet is going to be created at HEAD, so the time HEAD was committed is used for it.
+func sourceDateEpoch(cmdWrapper runner.Builder, mainPath, tagName string, reproducible bool) (int64, error) {
+	if value := os.Getenv("SOURCE_DATE_EPOCH"); value != "" {
+		epoch, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid SOURCE_DATE_EPOCH %s: %v", value, err)
+		}
+
+		return epoch, nil
+	}
+
+	if !reproducible {
+		return 0, nil
+	}
+
+	commit := tagName
+	_, err := cmdWrapper.New(mainPath, "git", "rev-parse", "--verify", "--quiet", fmt.Sprintf("refs/tags/%s^{commit}", tagName)).Output()
+	if err != nil {
+		commit = "HEAD"
+	}
+
+	output, err := cmdWrapper.New(mainPath, "git", "log", "-1", "--format=%ct", commit, "--").Output()
+	if err != nil {
+		return 0, fmt.Errorf("Unable to determine when %s was committed: %v", tagName, err)
+	}
+
+	epoch, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to determine when %s was committed: %v", tagName, err)
+	}
+
+	return epoch, nil
+}
+
+// archiveModTime is the modification time of the archive entries, SOURCE_DATE_EPOCH when there is one
+func (data templateData) archiveModTime() time.Time {
+	if data.SourceDateEpoch == 0 {
+		return archiveTime
+	}
+
+	return time.Unix(data.SourceDateEpoch, 0).UTC()
+}
+
+func getGoVersion(cmdWrapper runner.Builder, mainPath, goExecutable string) string {
+	versionInfo, _ := cmdWrapper.New(mainPath, goExecutable, "version").CombinedOutput()
+	versionParts := strings.Split(string(versionInfo), " ")
+	if len(versionParts) >= 3 {
+		return versionParts[2]
+	}
+
+	return "UNKNOWN"
+}
+
+// planBuilds determines the file name, go build command and environment for every target before anything is built.
+// Binaries and archives are written to a folder for each target in outputDir.
+func planBuilds(builds []osBuildInfo, cfg *config, data templateData, goExecutable, mainPath, outputDir string) ([]buildTarget, error) {
+	targets := []buildTarget{}
+	for _, build := range builds {
+		extraFiles, err := resolveArchiveFiles(mainPath, cfg.archiveFiles(build.OperatingSystem))
+		if err != nil {
+			return nil, fmt.Errorf("Could not find archive files for %s: %v", build.OperatingSystem, err)
+		}
+
+		for _, architecture := range build.Architectures {
+			targetData := data
+			targetData.OS = build.OperatingSystem
+			targetData.Arch = architecture
+			binaryName, err := cfg.binaryName(targetData)
+			if err != nil {
+				return nil, fmt.Errorf("Could not name binary for %s/%s: %v", build.OperatingSystem, architecture, err)
+			}
+
+			targetDir := fmt.Sprintf("%s/%s_%s", outputDir, build.OperatingSystem, architecture)
+			fileName := fmt.Sprintf("%s/%s%s", targetDir, binaryName, build.Extension)
+			flags := cfg.Builds.flagsFor(build.OperatingSystem, architecture)
+			if cfg.Builds.Reproducible {
+				flags = flags.reproducible()
+			}
+
+			command, err := buildCommand(goExecutable, flags, targetData, fileName)
+			if err != nil {
+				return nil, fmt.Errorf("Could not prepare build for %s/%s: %v", build.OperatingSystem, architecture, err)
+			}
+
+			archivePath := fmt.Sprintf("%s/%s.%s", targetDir, binaryName, build.ArchiveFormat)
+			target := buildTarget{
+				osBuildInfo:  build,
+				Architecture: architecture,
+				FileName:     fileName,
+				Command:      command,
+				ArchivePath:  archivePath,
+				Directory:    binaryName,
+				BinaryName:   fmt.Sprintf("%s%s", data.Project, build.Extension),
+				ExtraFiles:   extraFiles,
+				Data:         targetData,
+				SBOMs:        sbomFileNames(archivePath, cfg.SBOM.Formats),
+				Environment: []string{
+					fmt.Sprintf("GOOS=%s", build.OperatingSystem),
+					fmt.Sprintf("GOARCH=%s", architecture),
+					fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH")),
+				},
+			}
+
+			if data.SourceDateEpoch != 0 {
+				target.Environment = append(target.Environment, fmt.Sprintf("SOURCE_DATE_EPOCH=%d", data.SourceDateEpoch))
+			}
+
+			err = checkArchiveFiles(target.archiveFiles())
+			if err != nil {
+				return nil, fmt.Errorf("Could not package %s/%s: %v", build.OperatingSystem, architecture, err)
+			}
+
+			targets = append(targets, target)
+		}
+	}
+
+	return targets, nil
+}
+
+func buildCommand(goExecutable string, flags buildFlags, data templateData, fileName string) ([]string, error) {
+	command := []string{goExecutable, "build"}
+	if flags.Trimpath != nil && *flags.Trimpath {
+		command = append(command, "-trimpath")
+	}
+
+	if flags.Buildmode != "" {
+		command = append(command, "-buildmode", flags.Buildmode)
+	}
+
+	if flags.Gcflags != "" {
+		gcflags, err := renderTemplate("gcflags", flags.Gcflags, data)
+		if err != nil {
+			return nil, err
+		}
+
+		command = append(command, "-gcflags", gcflags)
+	}
+
+	if flags.Ldflags != "" {
+		ldflags, err := renderTemplate("ldflags", flags.Ldflags, data)
+		if err != nil {
+			return nil, err
+		}
+
+		command = append(command, "-ldflags", ldflags)
+	}
+
+	if len(flags.Tags) != 0 {
+		command = append(command, "-tags", strings.Join(flags.Tags, ","))
+	}
+
+	return append(command, "-o", fileName), nil
+}
+
+func renderTemplate(name, text string, data interface{}) (string, error) {
+	parsed, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var rendered bytes.Buffer
+	err = parsed.Execute(&rendered, data)
+	return rendered.String(), err
+}
+
+// flagsFor merges the global build flags with the overrides for the OS and then the os/arch target
+func (builds buildConfig) flagsFor(operatingSystem, architecture string) buildFlags {
+	flags := builds.buildFlags()
+	for _, name := range []string{operatingSystem, targetName(operatingSystem, architecture)} {
+		override, ok := builds.Overrides[name]
+		if !ok {
+			continue
+		}
+
+		if override.Ldflags != "" {
+			flags.Ldflags = override.Ldflags
+		}
+
+		if override.Tags != nil {
+			flags.Tags = override.Tags
+		}
+
+		if override.Trimpath != nil {
+			flags.Trimpath = override.Trimpath
+		}
+
+		if override.Buildmode != "" {
+			flags.Buildmode = override.Buildmode
+		}
+
+		if override.Gcflags != "" {
+			flags.Gcflags = override.Gcflags
+		}
+	}
+
+	return flags
+}
+
+// reproducible makes the build flags leave out the paths and build ID that differ between machines
+func (flags buildFlags) reproducible() buildFlags {
+	trimpath := true
+	flags.Trimpath = &trimpath
+	if !contains(strings.Fields(flags.Ldflags), "-buildid=") {
+		flags.Ldflags = strings.TrimSpace(fmt.Sprintf("%s -buildid=", flags.Ldflags))
+	}
+
+	return flags
+}
+
+func (builds buildConfig) buildFlags() buildFlags {
+	trimpath := builds.Trimpath
+	return buildFlags{
+		Ldflags:   builds.Ldflags,
+		Tags:      builds.Tags,
+		Trimpath:  &trimpath,
+		Buildmode: builds.Buildmode,
+		Gcflags:   builds.Gcflags,
+	}
+}
+
+// usesVariable is whether any template in the config that is rendered with the templateData refers to the variable
+func (cfg *config) usesVariable(name string) bool {
+	templates := []string{
+		cfg.Builds.Ldflags,
+		cfg.Builds.Gcflags,
+		cfg.Archives.NameTemplate,
+		cfg.Checksums.NameTemplate,
+		cfg.Release.Name,
+		cfg.Release.Body,
+		cfg.Release.TargetCommitish,
+	}
+	for _, override := range cfg.Builds.Overrides {
+		templates = append(templates, override.Ldflags, override.Gcflags)
+	}
+
+	return anyTemplateUses(templates, name)
+}
+
+func anyTemplateUses(templates []string, name string) bool {
+	for _, text := range templates {
+		if strings.Contains(text, fmt.Sprintf(".%s", name)) {
+			return true
+		}
+	}
+
+	return false
+}
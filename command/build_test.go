@@ -0,0 +1,316 @@
+package command_test
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+type testManifest struct {
+	Owner string
+	Repo  string
+	Data  struct {
+		Commit string
+	}
+	Targets []struct {
+		OS         string
+		Arch       string
+		Binary     string
+		Archive    string
+		ExtraFiles []struct {
+			Source string
+		}
+		Build struct {
+			Command     []string
+			Environment []string
+		}
+	}
+	Artifacts []struct {
+		Path   string
+		OS     string
+		Arch   string
+		Type   string
+		Size   int64
+		Digest string
+	}
+}
+
+func TestBuildPackagePublish(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	dist := fmt.Sprintf("%s/dist", mainPath)
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64, windows/amd64]\n")
+	createFiles(t, mainPath, "tag")
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("mainPath", mainPath, "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	expectedCommands := append(
+		getExpectedCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+			return architecture == "amd64" && (operatingSystem == "linux" || operatingSystem == "windows")
+		}),
+		getBuildGitCommands(t, mainPath, "abc123")...,
+	)
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdBuild(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, "", errWriter.String())
+	manifest := readTestManifest(t, dist)
+	assert.Equal(t, "owner", manifest.Owner)
+	assert.Equal(t, "repo", manifest.Repo)
+	assert.Equal(t, "abc123", manifest.Data.Commit)
+	assert.Equal(
+		t,
+		[]string{
+			"linux_amd64/projectName-linux-amd64-go1.8-tag linux/amd64 binary",
+			"windows_amd64/projectName-windows-amd64-go1.8-tag.exe windows/amd64 binary",
+		},
+		manifestArtifacts(manifest),
+	)
+	assert.Equal(t, int64(3), manifest.Artifacts[0].Size)
+	assert.Equal(t, "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae", manifest.Artifacts[0].Digest)
+
+	set = flag.NewFlagSet("test", 0)
+	set.String("mainPath", mainPath, "doc")
+	assert.Nil(t, set.Parse([]string{}))
+	app, _, errWriter = appWithTestWriters()
+	assert.Nil(t, command.CmdPackage(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "", errWriter.String())
+	assert.Equal(
+		t,
+		[]string{
+			"linux_amd64/projectName-linux-amd64-go1.8-tag linux/amd64 binary",
+			"linux_amd64/projectName-linux-amd64-go1.8-tag.tar.gz linux/amd64 archive",
+			"projectName_tag_checksums.txt / checksums",
+			"windows_amd64/projectName-windows-amd64-go1.8-tag.exe windows/amd64 binary",
+			"windows_amd64/projectName-windows-amd64-go1.8-tag.zip windows/amd64 archive",
+		},
+		manifestArtifacts(readTestManifest(t, dist)),
+	)
+
+	uploads := make(map[string][]byte)
+	ts := getReleaseTestServerWithUploads(t, "", "", uploads)
+	defer ts.Close()
+	set = flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	assert.Nil(t, set.Parse([]string{}))
+	app, _, errWriter = appWithTestWriters()
+	expectedRunner = &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/tag^{commit}"), "abc123\n", 0),
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git ls-remote --tags origin refs/tags/tag refs/tags/tag^{}"), "abc123\trefs/tags/tag\n", 0),
+		},
+	}
+	assert.Nil(t, command.CmdPublish(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, "", errWriter.String())
+	names := []string{}
+	for name := range uploads {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	assert.Equal(
+		t,
+		[]string{
+			"digests.json",
+			"projectName-linux-amd64-go1.8-tag.tar.gz",
+			"projectName-windows-amd64-go1.8-tag.zip",
+			"projectName_tag_checksums.txt",
+		},
+		names,
+	)
+
+	// Nothing is removed from the dist directory
+	for _, fileName := range []string{"linux_amd64/projectName-linux-amd64-go1.8-tag", "windows_amd64/projectName-windows-amd64-go1.8-tag.zip", "projectName_tag_checksums.txt"} {
+		_, err = os.Stat(fmt.Sprintf("%s/%s", dist, fileName))
+		assert.Nil(t, err)
+	}
+}
+
+func TestBuildUsage(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	set.String("mainPath", fmt.Sprintf("%s/build", os.TempDir()), "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo"}))
+	app, _, _ := appWithTestWriters()
+	err := command.CmdBuild(&runner.Test{})(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Usage: \"goRelease build {owner} {repo} {tagName} {projectName} --dist {dist}\"")
+}
+
+func TestPackageWithoutBuild(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	assert.Nil(t, os.Mkdir(mainPath, 0777))
+	set := flag.NewFlagSet("test", 0)
+	set.String("mainPath", mainPath, "doc")
+	assert.Nil(t, set.Parse([]string{}))
+	app, _, _ := appWithTestWriters()
+	err := command.CmdPackage(&runner.Test{})(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, fmt.Sprintf("There is no artifacts.json in %s/dist, run goRelease build first", mainPath))
+}
+
+func TestPackageInvalidManifest(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeTestFile(t, fmt.Sprintf("%s/dist/artifacts.json", mainPath), "{", 0644)
+	set := flag.NewFlagSet("test", 0)
+	set.String("mainPath", mainPath, "doc")
+	set.String("dist", fmt.Sprintf("%s/dist", mainPath), "doc")
+	assert.Nil(t, set.Parse([]string{}))
+	app, _, _ := appWithTestWriters()
+	err := command.CmdPackage(&runner.Test{})(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, fmt.Sprintf("Invalid manifest %s/dist/artifacts.json: unexpected end of JSON input", mainPath))
+}
+
+func TestPublishNothingPackaged(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeTestFile(t, fmt.Sprintf("%s/dist/artifacts.json", mainPath), `{"owner": "owner", "repo": "repo", "artifacts": [{"path": "projectName", "type": "binary"}]}`, 0644)
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("mainPath", mainPath, "doc")
+	assert.Nil(t, set.Parse([]string{}))
+	app, _, _ := appWithTestWriters()
+	err := command.CmdPublish(&runner.Test{})(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, fmt.Sprintf("There is nothing to publish in %s/dist, run goRelease package first", mainPath))
+}
+
+func TestPublishTagNotAtBuild(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeTestFile(t, fmt.Sprintf("%s/dist/projectName.tar.gz", mainPath), "foo", 0644)
+	writeTestManifest(t, mainPath, "tag", "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae")
+	ts := getReleaseTestServer(t, "", "")
+	defer ts.Close()
+	set := getPublishFlagSet(t, ts.URL, mainPath)
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/tag^{commit}"), "def456\n", 0),
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git ls-remote --tags origin refs/tags/tag refs/tags/tag^{}"), "def456\trefs/tags/tag\n", 0),
+		},
+	}
+	app, _, _ := appWithTestWriters()
+	err := command.CmdPublish(expectedRunner)(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "tag is def456 but the build is of abc123, build the tag to publish it")
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+}
+
+func TestPublishCreatesTagAtBuild(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeTestFile(t, fmt.Sprintf("%s/dist/projectName.tar.gz", mainPath), "foo", 0644)
+	writeTestManifest(t, mainPath, "tag", "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae")
+	ts := getReleaseTestServer(t, "", "")
+	defer ts.Close()
+	set := getPublishFlagSet(t, ts.URL, mainPath)
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/tag^{commit}"), "", 1),
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git ls-remote --tags origin refs/tags/tag refs/tags/tag^{}"), "", 0),
+			runner.NewExpectedCommand(mainPath, "git tag tag abc123", "", 0),
+			runner.NewExpectedCommand(mainPath, "git push origin refs/tags/tag", "", 0),
+		},
+	}
+	app, writer, _ := appWithTestWriters()
+	assert.Nil(t, command.CmdPublish(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Contains(t, writer.String(), "Created tag tag at abc123\nPushed tag tag to origin\n")
+}
+
+func TestPublishChangedArtifact(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeTestFile(t, fmt.Sprintf("%s/dist/projectName.tar.gz", mainPath), "bar", 0644)
+	writeTestManifest(t, mainPath, "tag", "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae")
+	set := getPublishFlagSet(t, "http://127.0.0.1:1", mainPath)
+	app, _, _ := appWithTestWriters()
+	err := command.CmdPublish(&runner.Test{})(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, fmt.Sprintf("%s/dist/projectName.tar.gz has changed since it was packaged, run goRelease package again", mainPath))
+
+	assert.Nil(t, os.Remove(fmt.Sprintf("%s/dist/projectName.tar.gz", mainPath)))
+	err = command.CmdPublish(&runner.Test{})(cli.NewContext(app, set, nil))
+	assert.EqualError(
+		t,
+		err,
+		fmt.Sprintf(
+			"Unable to check %[1]s/dist/projectName.tar.gz: stat %[1]s/dist/projectName.tar.gz: no such file or directory",
+			mainPath,
+		),
+	)
+}
+
+func TestPublishNoToken(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	assert.Nil(t, set.Parse([]string{}))
+	app, _, _ := appWithTestWriters()
+	err := command.CmdPublish(&runner.Test{})(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "You must specify a token")
+}
+
+// getBuildGitCommands are the git commands that build runs to check that the working tree is clean and record the commit
+func getBuildGitCommands(t *testing.T, mainPath, commit string) []*runner.ExpectedCommand {
+	t.Helper()
+	return []*runner.ExpectedCommand{
+		runner.NewExpectedCommand(mainPath, "git status --porcelain -- :!dist", "", 0),
+		runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", fmt.Sprintf("%s\n", commit), 0),
+	}
+}
+
+// writeTestManifest writes a manifest of tag built from abc123 with a 3 byte projectName.tar.gz archive that has digest
+func writeTestManifest(t *testing.T, mainPath, tagName, digest string) {
+	t.Helper()
+	writeTestFile(
+		t,
+		fmt.Sprintf("%s/dist/artifacts.json", mainPath),
+		fmt.Sprintf(
+			`{"owner": "owner", "repo": "repo", "data": {"Tag": %q, "Commit": "abc123"}, `+
+				`"artifacts": [{"path": "projectName.tar.gz", "type": "archive", "size": 3, "digest": %q}]}`,
+			tagName,
+			digest,
+		),
+		0644,
+	)
+}
+
+func getPublishFlagSet(t *testing.T, url, mainPath string) *flag.FlagSet {
+	t.Helper()
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", url), "doc")
+	set.String("mainPath", mainPath, "doc")
+	assert.Nil(t, set.Parse([]string{}))
+	return set
+}
+
+func readTestManifest(t *testing.T, dist string) testManifest {
+	t.Helper()
+	contents, err := ioutil.ReadFile(fmt.Sprintf("%s/artifacts.json", dist))
+	assert.Nil(t, err)
+	manifest := testManifest{}
+	assert.Nil(t, json.Unmarshal(contents, &manifest))
+	return manifest
+}
+
+func manifestArtifacts(manifest testManifest) []string {
+	artifacts := []string{}
+	for _, asset := range manifest.Artifacts {
+		artifacts = append(artifacts, fmt.Sprintf("%s %s/%s %s", asset.Path, asset.OS, asset.Arch, asset.Type))
+	}
+
+	sort.Strings(artifacts)
+	return artifacts
+}
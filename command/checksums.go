@@ -0,0 +1,174 @@
+package command
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const defaultChecksumNameTemplate = "{{.Project}}_{{.Tag}}_checksums.txt"
+
+// checksumAlgorithms are the hashes that can be used for the checksums file
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256":  sha256.New,
+	"sha512":  sha512.New,
+	"blake2b": newBlake2b,
+}
+
+// newBlake2b creates an unkeyed BLAKE2b-512 hash, which can't fail without a key
+func newBlake2b() hash.Hash {
+	digest, _ := blake2b.New512(nil)
+	return digest
+}
+
+type checksumConfig struct {
+	Algorithm    string   `yaml:"algorithm"`
+	NameTemplate string   `yaml:"nameTemplate"`
+	OnlyFor      []string `yaml:"onlyFor"`
+	Sidecars     bool     `yaml:"sidecars"`
+	Disable      bool     `yaml:"disable"`
+}
+
+// checksums collects the checksums of uploaded assets so they can be written to a single file once everything is uploaded
+type checksums struct {
+	algorithm string
+	newHash   func() hash.Hash
+	onlyFor   []string
+	sidecars  bool
+	fileName  string
+	mutex     sync.Mutex
+	sums      map[string]string
+}
+
+func newChecksums(cfg checksumConfig, data templateData, mainPath string) (*checksums, error) {
+	algorithm := stringOption(cfg.Algorithm, "sha256")
+	newHash, ok := checksumAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("Unknown checksum algorithm %s (expected one of %s)", algorithm, checksumAlgorithmNames())
+	}
+
+	for _, pattern := range cfg.OnlyFor {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("Invalid checksum pattern %s: %v", pattern, err)
+		}
+	}
+
+	fileName, err := renderTemplate("checksums", stringOption(cfg.NameTemplate, defaultChecksumNameTemplate), data)
+	if err != nil {
+		return nil, fmt.Errorf("Could not name checksums file: %v", err)
+	}
+
+	return &checksums{
+		algorithm: algorithm,
+		newHash:   newHash,
+		onlyFor:   cfg.OnlyFor,
+		sidecars:  cfg.Sidecars,
+		fileName:  filepath.Join(mainPath, fileName),
+		sums:      make(map[string]string),
+	}, nil
+}
+
+// includes reports whether an asset should be listed in the checksums file
+func (sums *checksums) includes(fileName string) bool {
+	if len(sums.onlyFor) == 0 {
+		return true
+	}
+
+	for _, pattern := range sums.onlyFor {
+		if matched, _ := path.Match(pattern, path.Base(fileName)); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (sums *checksums) sum(fileName string) (string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	digest := sums.newHash()
+	_, err = io.Copy(digest, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+func (sums *checksums) add(fileName, sum string) {
+	sums.mutex.Lock()
+	defer sums.mutex.Unlock()
+	sums.sums[path.Base(fileName)] = sum
+}
+
+// writeSidecar writes a checksum file for a single asset next to it, e.g. foo.tar.gz.sha256
+func (sums *checksums) writeSidecar(fileName, sum string) (string, error) {
+	sidecarName := sums.sidecarName(fileName)
+	return sidecarName, ioutil.WriteFile(sidecarName, []byte(checksumLine(sum, path.Base(fileName))), 0644)
+}
+
+func (sums *checksums) sidecarName(fileName string) string {
+	return fmt.Sprintf("%s.%s", fileName, sums.algorithm)
+}
+
+// write creates the checksums file in the format used by sha256sum, sorted by asset name
+func (sums *checksums) write() (string, error) {
+	names := make([]string, 0, len(sums.sums))
+	for name := range sums.sums {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	var contents strings.Builder
+	for _, name := range names {
+		contents.WriteString(checksumLine(sums.sums[name], name))
+	}
+
+	return sums.fileName, ioutil.WriteFile(sums.fileName, []byte(contents.String()), 0644)
+}
+
+func checksumLine(sum, name string) string {
+	return fmt.Sprintf("%s  %s\n", sum, name)
+}
+
+// readChecksums parses a checksums file in the format used by sha256sum into the checksum of each asset by name
+func readChecksums(contents []byte) map[string]string {
+	sums := map[string]string{}
+	for _, line := range strings.Split(string(contents), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			sums[strings.TrimPrefix(fields[1], "*")] = fields[0]
+		}
+	}
+
+	return sums
+}
+
+func checksumAlgorithmNames() string {
+	names := make([]string, 0, len(checksumAlgorithms))
+	for name := range checksumAlgorithms {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
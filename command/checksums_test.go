@@ -0,0 +1,157 @@
+package command_test
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestReleaseChecksums(t *testing.T) {
+	uploads := make(map[string][]byte)
+	ts := getReleaseTestServerWithUploads(t, "", "", uploads)
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	expectedRunner := &runner.Test{ExpectedCommands: getReleaseCommands(t, mainPath), AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, "", errWriter.String())
+
+	names := []string{}
+	for name := range uploads {
+		if name != "projectName_tag_checksums.txt" && name != "digests.json" {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	expectedLines := []string{}
+	for _, name := range names {
+		sum := sha256.Sum256(uploads[name])
+		expectedLines = append(expectedLines, fmt.Sprintf("%s  %s", hex.EncodeToString(sum[:]), name))
+	}
+
+	assert.Equal(t, len(testBuilds), len(expectedLines))
+	assert.Equal(t, strings.Join(expectedLines, "\n")+"\n", string(uploads["projectName_tag_checksums.txt"]))
+	_, err = os.Stat(fmt.Sprintf("%s/dist/projectName_tag_checksums.txt", mainPath))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReleaseChecksumsOptions(t *testing.T) {
+	uploads := make(map[string][]byte)
+	ts := getReleaseTestServerWithUploads(t, "", "", uploads)
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.String("checksumAlgorithm", "sha512", "doc")
+	onlyFor := cli.StringSlice{"*.zip"}
+	set.Var(&onlyFor, "checksumsOnlyFor", "doc")
+	set.Bool("checksumSidecars", true, "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  arch: [amd64]\nchecksums:\n  algorithm: blake2b\n  nameTemplate: \"{{.Project}}-{{.Version}}.sums\"\n")
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return architecture == "amd64"
+	})
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, "", errWriter.String())
+
+	sum := sha512.Sum512(uploads["projectName-windows-amd64-go1.8-tag.zip"])
+	expected := fmt.Sprintf("%s  projectName-windows-amd64-go1.8-tag.zip\n", hex.EncodeToString(sum[:]))
+	assert.Equal(t, expected, string(uploads["projectName-tag.sums"]))
+	assert.Equal(t, expected, string(uploads["projectName-windows-amd64-go1.8-tag.zip.sha512"]))
+	assert.Equal(t, 7, len(uploads))
+}
+
+func TestReleaseChecksumsDisabled(t *testing.T) {
+	uploads := make(map[string][]byte)
+	ts := getReleaseTestServerWithUploads(t, "", "", uploads)
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "checksums:\n  disable: true\n")
+	expectedRunner := &runner.Test{ExpectedCommands: getReleaseCommands(t, mainPath), AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "", errWriter.String())
+	assert.Equal(t, len(testBuilds)+1, len(uploads))
+}
+
+func TestReleaseChecksumsUploadFailure(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	ts := getReleaseTestServer(t, "/repos/owner/repo/releases/1/assets?name=projectName_tag_checksums.txt", "POST")
+	defer ts.Close()
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.Int("maxAttempts", 1, "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	expectedRunner := &runner.Test{ExpectedCommands: getReleaseCommands(t, mainPath), AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	err = command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Failed targets: checksums")
+	assert.Equal(
+		t,
+		fmt.Sprintf(
+			"Unable to upload checksums %s/dist/projectName_tag_checksums.txt: POST %s/repos/owner/repo/releases/1/assets?name=projectName_tag_checksums.txt: "+
+				"500  []\n",
+			mainPath,
+			ts.URL,
+		),
+		errWriter.String(),
+	)
+}
+
+func TestReleaseInvalidChecksumAlgorithm(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.String("checksumAlgorithm", "md5", "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	goRunner := getDistListRunner(t, mainPath)
+	app, _, _ := appWithTestWriters()
+	err = command.CmdRelease(goRunner)(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Unknown checksum algorithm md5 (expected one of blake2b, sha256, sha512)")
+}
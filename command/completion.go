@@ -14,7 +14,7 @@ func Completion(c *cli.Context) {
 	if len(os.Args) > 2 {
 		lastParam := os.Args[len(os.Args)-2]
 		log.Println(lastParam)
-		for _, flag := range c.App.Flags {
+		for _, flag := range completionFlags(c) {
 			name := strings.Split(flag.GetName(), ",")[0]
 			log.Println(name)
 			if lastParam == fmt.Sprintf("--%s", name) {
@@ -24,11 +24,32 @@ func Completion(c *cli.Context) {
 		}
 	}
 
+	if c.Command.Name == "" && c.NArg() == 0 {
+		completeCommands(c)
+	}
+
 	completeFlags(c)
 }
 
+// completionFlags are the flags of the subcommand being completed, or the top level flags outside of a subcommand
+func completionFlags(c *cli.Context) []cli.Flag {
+	if c.Command.Name != "" {
+		return c.Command.Flags
+	}
+
+	return c.App.Flags
+}
+
+func completeCommands(c *cli.Context) {
+	for _, command := range c.App.Commands {
+		if !command.Hidden && command.Name != "help" {
+			fmt.Fprintln(c.App.Writer, command.Name)
+		}
+	}
+}
+
 func completeFlags(c *cli.Context) {
-	for _, flag := range c.App.Flags {
+	for _, flag := range completionFlags(c) {
 		name := strings.Split(flag.GetName(), ",")[0]
 		if !c.IsSet(name) {
 			fmt.Fprintf(c.App.Writer, "--%s\n", name)
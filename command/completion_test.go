@@ -24,9 +24,50 @@ func TestReleaseCompletion(t *testing.T) {
 			"--token",
 			"--apiUrl",
 			"--mainPath",
+			"--config",
 			"--os",
+			"--arch",
+			"--target",
+			"--firstClassOnly",
+			"--ldflags",
+			"--tags",
+			"--trimpath",
+			"--reproducible",
+			"--buildmode",
+			"--gcflags",
+			"--checksumAlgorithm",
+			"--checksumsOnlyFor",
+			"--checksumSidecars",
+			"--signingKey",
+			"--signingPublicKey",
+			"--signArtifacts",
+			"--minisignKey",
+			"--minisignPublicKey",
+			"--minisignArtifacts",
+			"--sbom",
+			"--provenanceKey",
+			"--provenancePublicKey",
+			"--dryRun",
+			"--planFormat",
 			"--publish",
+			"--releaseNotesFile",
+			"--releaseName",
+			"--releaseBody",
+			"--targetCommitish",
+			"--prerelease",
 			"--removeOldAssets",
+			"--uploadUnchanged",
+			"--onConflict",
+			"--dist",
+			"--keep",
+			"--bump",
+			"--skipVersionCheck",
+			"--allowDirty",
+			"--allowPartial",
+			"--requiredTargets",
+			"--maxAttempts",
+			"--buildConcurrency",
+			"--uploadConcurrency",
 			"",
 		},
 		output,
@@ -50,6 +91,98 @@ func TestReleaseCompletionMainPath(t *testing.T) {
 	)
 }
 
+func TestReleaseCompletionCommands(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	os.Args = []string{os.Args[0], "--completion"}
+	app, writer, _ := appWithTestWriters()
+	app.Flags = []cli.Flag{cli.StringFlag{Name: "token"}}
+	app.Commands = []cli.Command{{Name: "build"}, {Name: "publish"}, {Name: "secret", Hidden: true}}
+	command.Completion(cli.NewContext(app, set, nil))
+	output := strings.Split(writer.String(), "\n")
+	assert.Equal(t, []string{"build", "publish", "--token", ""}, output)
+}
+
+func TestReleaseCompletionSubcommand(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	os.Args = []string{os.Args[0], "publish", "--completion"}
+	app, writer, _ := appWithTestWriters()
+	app.Flags = command.Flags
+	context := cli.NewContext(app, set, nil)
+	context.Command = cli.Command{Name: "publish", Flags: command.PublishFlags}
+	command.Completion(context)
+	output := strings.Split(writer.String(), "\n")
+	assert.Equal(
+		t,
+		[]string{
+			"--token",
+			"--apiUrl",
+			"--mainPath",
+			"--config",
+			"--publish",
+			"--releaseNotesFile",
+			"--releaseName",
+			"--releaseBody",
+			"--targetCommitish",
+			"--prerelease",
+			"--removeOldAssets",
+			"--uploadUnchanged",
+			"--skipVersionCheck",
+			"--onConflict",
+			"--dist",
+			"--allowPartial",
+			"--requiredTargets",
+			"--maxAttempts",
+			"--uploadConcurrency",
+			"",
+		},
+		output,
+	)
+}
+
+func TestReleaseCompletionSubcommandDist(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	os.Args = []string{os.Args[0], "build", "--dist", "--completion"}
+	app, writer, _ := appWithTestWriters()
+	context := cli.NewContext(app, set, nil)
+	context.Command = cli.Command{Name: "build", Flags: command.BuildFlags}
+	command.Completion(context)
+	assert.Equal(t, "fileCompletion\n", writer.String())
+}
+
+func TestReleaseCompletionReproduce(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	os.Args = []string{os.Args[0], "reproduce", "--completion"}
+	app, writer, _ := appWithTestWriters()
+	context := cli.NewContext(app, set, nil)
+	context.Command = cli.Command{Name: "reproduce", Flags: command.ReproduceFlags}
+	command.Completion(context)
+	assert.Equal(
+		t,
+		[]string{
+			"--token",
+			"--apiUrl",
+			"--mainPath",
+			"--config",
+			"--os",
+			"--arch",
+			"--target",
+			"--firstClassOnly",
+			"--ldflags",
+			"--tags",
+			"--trimpath",
+			"--buildmode",
+			"--gcflags",
+			"--checksumAlgorithm",
+			"--dist",
+			"--maxAttempts",
+			"--buildConcurrency",
+			"--checksums",
+			"",
+		},
+		strings.Split(writer.String(), "\n"),
+	)
+}
+
 func appWithTestWriters() (*cli.App, *bytes.Buffer, *bytes.Buffer) {
 	app := cli.NewApp()
 	writer := new(bytes.Buffer)
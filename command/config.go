@@ -0,0 +1,402 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames are the files that are checked for in mainPath when --config is not specified
+var configFileNames = []string{".goRelease.yml", ".goRelease.yaml"}
+
+const defaultNameTemplate = "{{.Project}}-{{.OS}}-{{.Arch}}-{{.GoVersion}}-{{.Tag}}"
+
+type config struct {
+	ProjectName  string             `yaml:"projectName"`
+	Builds       buildConfig        `yaml:"builds"`
+	Archives     archiveConfig      `yaml:"archives"`
+	Checksums    checksumConfig     `yaml:"checksums"`
+	Signing      signingConfig      `yaml:"signing"`
+	Minisign     minisignConfig     `yaml:"minisign"`
+	SBOM         sbomConfig         `yaml:"sbom"`
+	Provenance   provenanceConfig   `yaml:"provenance"`
+	Release      releaseConfig      `yaml:"release"`
+	ReleaseNotes releaseNotesConfig `yaml:"releaseNotes"`
+	Retry        retryConfig        `yaml:"retry"`
+
+	fileName     string
+	lines        map[string]int
+	nameTemplate *template.Template
+}
+
+type buildConfig struct {
+	OperatingSystems []string              `yaml:"os"`
+	Architectures    []string              `yaml:"arch"`
+	Targets          []string              `yaml:"targets"`
+	FirstClassOnly   bool                  `yaml:"firstClassOnly"`
+	Ldflags          string                `yaml:"ldflags"`
+	Tags             []string              `yaml:"tags"`
+	Trimpath         bool                  `yaml:"trimpath"`
+	Reproducible     bool                  `yaml:"reproducible"`
+	Buildmode        string                `yaml:"buildmode"`
+	Gcflags          string                `yaml:"gcflags"`
+	Overrides        map[string]buildFlags `yaml:"overrides"`
+}
+
+type archiveConfig struct {
+	NameTemplate string                           `yaml:"nameTemplate"`
+	Format       string                           `yaml:"format"`
+	Formats      map[string]string                `yaml:"formats"`
+	Files        []archiveFileConfig              `yaml:"files"`
+	Overrides    map[string]archiveOverrideConfig `yaml:"overrides"`
+}
+
+// archiveFileConfig is an extra file to include in every archive.  It can be written as just the src pattern.
+type archiveFileConfig struct {
+	Source      string `yaml:"src"`
+	Destination string `yaml:"dst"`
+	Optional    bool   `yaml:"optional"`
+}
+
+// UnmarshalYAML accepts a scalar as the src pattern.  Node.Decode does not reject unknown fields, so the keys of a
+// mapping are checked here.
+func (file *archiveFileConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&file.Source)
+	}
+
+	if node.Kind == yaml.MappingNode {
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			key := node.Content[index]
+			if key.Value != "src" && key.Value != "dst" && key.Value != "optional" && key.Tag != "!!merge" {
+				return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: field %s not found in type command.archiveFileConfig", key.Line, key.Value)}}
+			}
+		}
+	}
+
+	type plain archiveFileConfig
+	return node.Decode((*plain)(file))
+}
+
+type archiveOverrideConfig struct {
+	Files []archiveFileConfig `yaml:"files"`
+}
+
+type releaseConfig struct {
+	Owner            string   `yaml:"owner"`
+	Repo             string   `yaml:"repo"`
+	APIURL           string   `yaml:"apiUrl"`
+	Publish          bool     `yaml:"publish"`
+	RemoveOldAssets  bool     `yaml:"removeOldAssets"`
+	OnConflict       string   `yaml:"onConflict"`
+	AllowDirty       bool     `yaml:"allowDirty"`
+	SkipVersionCheck bool     `yaml:"skipVersionCheck"`
+	AllowPartial     bool     `yaml:"allowPartial"`
+	RequiredTargets  []string `yaml:"requiredTargets"`
+	Name             string   `yaml:"name"`
+	Body             string   `yaml:"body"`
+	TargetCommitish  string   `yaml:"targetCommitish"`
+	Prerelease       *bool    `yaml:"prerelease"`
+}
+
+// loadConfig reads the config file at configPath, or the first of configFileNames found in mainPath if configPath is empty
+func loadConfig(configPath, mainPath string) (*config, error) {
+	if configPath == "" {
+		for _, fileName := range configFileNames {
+			candidate := filepath.Join(mainPath, fileName)
+			if _, err := os.Stat(candidate); err == nil {
+				configPath = candidate
+				break
+			}
+		}
+	}
+
+	cfg := &config{lines: make(map[string]int)}
+	if configPath == "" {
+		return cfg, cfg.validate()
+	}
+
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read config: %v", err)
+	}
+
+	cfg.fileName = configPath
+	cfg.lines, err = decodeYaml(data, cfg)
+	if err != nil {
+		return nil, configSyntaxError(configPath, err)
+	}
+
+	return cfg, cfg.validate()
+}
+
+func (cfg *config) validate() error {
+	for index, target := range cfg.Builds.Targets {
+		parts := strings.Split(strings.TrimPrefix(target, "!"), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return cfg.errorAt(fmt.Sprintf("builds.targets[%d]", index), "invalid target %s, expected os/arch", target)
+		}
+	}
+
+	if cfg.Archives.Format != "" {
+		if !isArchiveFormat(cfg.Archives.Format) {
+			return cfg.errorAt("archives.format", "unknown archive format %s (expected one of %s)", cfg.Archives.Format, archiveFormatNames())
+		}
+	}
+
+	for operatingSystem, format := range cfg.Archives.Formats {
+		if !isArchiveFormat(format) {
+			return cfg.errorAt(
+				fmt.Sprintf("archives.formats.%s", operatingSystem),
+				"unknown archive format %s (expected one of %s)",
+				format,
+				archiveFormatNames(),
+			)
+		}
+	}
+
+	err := cfg.validateBuildTemplates()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.validateArchiveFiles("archives.files", cfg.Archives.Files)
+	if err != nil {
+		return err
+	}
+
+	for operatingSystem, override := range cfg.Archives.Overrides {
+		if operatingSystem == "" || strings.Contains(operatingSystem, "/") {
+			return cfg.errorAt(fmt.Sprintf("archives.overrides.%s", operatingSystem), "invalid override %s, expected os", operatingSystem)
+		}
+
+		err = cfg.validateArchiveFiles(fmt.Sprintf("archives.overrides.%s.files", operatingSystem), override.Files)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = cfg.validateChecksums()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Signing.Artifacts != "" && !contains(signArtifactsOptions, cfg.Signing.Artifacts) {
+		return cfg.errorAt(
+			"signing.artifacts",
+			"unknown signing artifacts %s (expected one of %s)",
+			cfg.Signing.Artifacts,
+			strings.Join(signArtifactsOptions, ", "),
+		)
+	}
+
+	if cfg.Minisign.Artifacts != "" && !contains(signArtifactsOptions, cfg.Minisign.Artifacts) {
+		return cfg.errorAt(
+			"minisign.artifacts",
+			"unknown minisign artifacts %s (expected one of %s)",
+			cfg.Minisign.Artifacts,
+			strings.Join(signArtifactsOptions, ", "),
+		)
+	}
+
+	for index, format := range cfg.SBOM.Formats {
+		if !contains(sbomFormats, format) {
+			return cfg.errorAt(
+				fmt.Sprintf("sbom.formats[%d]", index),
+				"unknown SBOM format %s (expected one of %s)",
+				format,
+				strings.Join(sbomFormats, ", "),
+			)
+		}
+	}
+
+	if cfg.Release.OnConflict != "" && !contains(conflictPolicies, cfg.Release.OnConflict) {
+		return cfg.errorAt("release.onConflict", "unknown conflict policy %s (expected one of %s)", cfg.Release.OnConflict, strings.Join(conflictPolicies, ", "))
+	}
+
+	for _, field := range []struct {
+		path string
+		text string
+	}{{"release.name", cfg.Release.Name}, {"release.body", cfg.Release.Body}, {"release.targetCommitish", cfg.Release.TargetCommitish}} {
+		if _, err := renderTemplate(field.path, field.text, templateData{}); err != nil {
+			return cfg.errorAt(field.path, "invalid template: %v", err)
+		}
+	}
+
+	for _, patterns := range []struct {
+		path     string
+		patterns []string
+	}{{"releaseNotes.include", cfg.ReleaseNotes.Include}, {"releaseNotes.exclude", cfg.ReleaseNotes.Exclude}} {
+		for index, pattern := range patterns.patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return cfg.errorAt(fmt.Sprintf("%s[%d]", patterns.path, index), "invalid pattern %s: %v", pattern, err)
+			}
+		}
+	}
+
+	if cfg.Retry.MaxAttempts < 0 {
+		return cfg.errorAt("retry.maxAttempts", "must be at least 1")
+	}
+
+	if cfg.Retry.Delay != "" {
+		if _, err := time.ParseDuration(cfg.Retry.Delay); err != nil {
+			return cfg.errorAt("retry.delay", "invalid delay %s: %v", cfg.Retry.Delay, err)
+		}
+	}
+
+	nameTemplate := cfg.Archives.NameTemplate
+	if nameTemplate == "" {
+		nameTemplate = defaultNameTemplate
+	}
+
+	cfg.nameTemplate, err = template.New("nameTemplate").Option("missingkey=error").Parse(nameTemplate)
+	if err == nil {
+		err = cfg.nameTemplate.Execute(ioutil.Discard, templateData{})
+	}
+
+	if err != nil {
+		return cfg.errorAt("archives.nameTemplate", "invalid template: %v", err)
+	}
+
+	return nil
+}
+
+func (cfg *config) validateBuildTemplates() error {
+	templates := map[string]string{
+		"builds.ldflags": cfg.Builds.Ldflags,
+		"builds.gcflags": cfg.Builds.Gcflags,
+	}
+	for name, override := range cfg.Builds.Overrides {
+		parts := strings.Split(name, "/")
+		if parts[0] == "" || len(parts) > 2 || (len(parts) == 2 && parts[1] == "") {
+			return cfg.errorAt(fmt.Sprintf("builds.overrides.%s", name), "invalid override %s, expected os or os/arch", name)
+		}
+
+		templates[fmt.Sprintf("builds.overrides.%s.ldflags", name)] = override.Ldflags
+		templates[fmt.Sprintf("builds.overrides.%s.gcflags", name)] = override.Gcflags
+	}
+
+	paths := make([]string, 0, len(templates))
+	for path := range templates {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+	for _, path := range paths {
+		_, err := renderTemplate(path, templates[path], templateData{})
+		if err != nil {
+			return cfg.errorAt(path, "invalid template: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (cfg *config) validateArchiveFiles(configPath string, files []archiveFileConfig) error {
+	for index, file := range files {
+		filePath := fmt.Sprintf("%s[%d]", configPath, index)
+		if file.Source == "" {
+			return cfg.errorAt(filePath, "src is required")
+		}
+
+		if _, err := filepath.Match(file.Source, ""); err != nil {
+			return cfg.errorAt(filePath, "invalid pattern %s: %v", file.Source, err)
+		}
+
+		if filepath.IsAbs(file.Destination) || strings.HasPrefix(filepath.Clean(file.Destination), "..") {
+			return cfg.errorAt(filePath, "dst %s must be inside the archive", file.Destination)
+		}
+	}
+
+	return nil
+}
+
+func (cfg *config) validateChecksums() error {
+	if cfg.Checksums.Algorithm != "" {
+		if _, ok := checksumAlgorithms[cfg.Checksums.Algorithm]; !ok {
+			return cfg.errorAt(
+				"checksums.algorithm",
+				"unknown checksum algorithm %s (expected one of %s)",
+				cfg.Checksums.Algorithm,
+				checksumAlgorithmNames(),
+			)
+		}
+	}
+
+	for index, pattern := range cfg.Checksums.OnlyFor {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return cfg.errorAt(fmt.Sprintf("checksums.onlyFor[%d]", index), "invalid pattern %s: %v", pattern, err)
+		}
+	}
+
+	if cfg.Checksums.NameTemplate != "" {
+		_, err := renderTemplate("checksums.nameTemplate", cfg.Checksums.NameTemplate, templateData{})
+		if err != nil {
+			return cfg.errorAt("checksums.nameTemplate", "invalid template: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// archiveFiles returns the extra files that should be packaged with binaries for an OS
+func (cfg *config) archiveFiles(operatingSystem string) []archiveFileConfig {
+	if override, ok := cfg.Archives.Overrides[operatingSystem]; ok && override.Files != nil {
+		return override.Files
+	}
+
+	return cfg.Archives.Files
+}
+
+func configSyntaxError(configPath string, err error) error {
+	if yamlErr, ok := err.(*yamlError); ok {
+		return fmt.Errorf("Invalid config %s:%d: %s", configPath, yamlErr.line, yamlErr.message)
+	}
+
+	return fmt.Errorf("Invalid config %s: %v", configPath, err)
+}
+
+func (cfg *config) errorAt(path, format string, args ...interface{}) error {
+	return fmt.Errorf("Invalid config %s:%d: %s: %s", cfg.fileName, cfg.lines[path], path, fmt.Sprintf(format, args...))
+}
+
+// applyArchiveFormats overrides the default packaging of each build with the configured archive formats
+func (cfg *config) applyArchiveFormats(builds []osBuildInfo) []osBuildInfo {
+	for index, build := range builds {
+		format, ok := cfg.Archives.Formats[build.OperatingSystem]
+		if !ok {
+			format = cfg.Archives.Format
+		}
+
+		if format != "" {
+			builds[index].ArchiveFormat = format
+		}
+	}
+
+	return builds
+}
+
+func (cfg *config) binaryName(data templateData) (string, error) {
+	var name bytes.Buffer
+	err := cfg.nameTemplate.Execute(&name, data)
+	return name.String(), err
+}
+
+// pathRelativeTo resolves a path from the config file against mainPath
+func pathRelativeTo(mainPath, fileName string) string {
+	if fileName == "" || filepath.IsAbs(fileName) {
+		return fileName
+	}
+
+	return filepath.Join(mainPath, fileName)
+}
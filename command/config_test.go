@@ -0,0 +1,304 @@
+package command_test
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+const testConfig = `---
+# Release settings for the test project
+projectName: projectName
+release:
+  owner: owner
+  repo: "repo"
+builds:
+  os: [linux, 'darwin']
+  arch:
+    - amd64
+  targets:
+  - "!darwin/amd64" # darwin only builds when asked for explicitly
+  ldflags: -s -w
+  tags: [netgo, osusergo]
+archives:
+  nameTemplate: "{{.Project}}_{{.Tag}}_{{.OS}}_{{.Arch}}"
+  formats: {linux: zip}
+`
+
+func TestReleaseConfig(t *testing.T) {
+	ts := getReleaseTestServer(t, "", "")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	err := set.Parse([]string{"tag"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, testConfig)
+	fileName := distFile(mainPath, "linux", "amd64", "projectName_tag_linux_amd64")
+	assert.Nil(t, ioutil.WriteFile(fileName, []byte("foo"), 0777))
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	expectedCommands := append(
+		[]*runner.ExpectedCommand{
+			getDistListCommand(t, mainPath),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+		},
+		getTagCommands(t, mainPath, "tag")...,
+	)
+	expectedRunner := &runner.Test{
+		ExpectedCommands: append(
+			expectedCommands,
+			runner.NewExpectedCommand(
+				mainPath,
+				fmt.Sprintf("%s build -ldflags -s -w -tags netgo,osusergo -o %s", goExecutable, fileName),
+				"",
+				0,
+			).WithEnvironment([]string{"GOOS=linux", "GOARCH=amd64", fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH"))}),
+		),
+	}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+	assert.Equal(t, "", errWriter.String())
+}
+
+func TestReleaseConfigFlagsOverride(t *testing.T) {
+	ts := getReleaseTestServer(t, "", "")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	osFlag := cli.StringSlice{"windows"}
+	set.Var(&osFlag, "os", "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  os:\n  - linux\n")
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return operatingSystem == "windows"
+	})
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+	app, _, _ := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+}
+
+const buildFlagsConfig = `builds:
+  os: [linux, windows]
+  arch: [amd64]
+  ldflags: -X main.version={{.Tag}} -X main.commit={{.Commit}} -X main.date={{.Date}} -X main.goVersion={{.GoVersion}}
+  tags: [netgo]
+  overrides:
+    windows: &windows
+      ldflags: >-
+        -X main.version={{.Version}}
+        -H windowsgui
+      tags: []
+    windows/amd64: {<<: *windows, buildmode: pie, trimpath: false}
+`
+
+func TestReleaseBuildFlags(t *testing.T) {
+	ts := getReleaseTestServer(t, "", "")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.Bool("trimpath", true, "doc")
+	set.String("gcflags", "all=-N -l", "doc")
+	err := set.Parse([]string{"owner", "repo", "v1.2.0", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "v1.2.0")
+	writeConfig(t, mainPath, buildFlagsConfig)
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	linuxFile := distFile(mainPath, "linux", "amd64", "projectName-linux-amd64-go1.8-v1.2.0")
+	windowsFile := distFile(mainPath, "windows", "amd64", "projectName-windows-amd64-go1.8-v1.2.0.exe")
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			getDistListCommand(t, mainPath),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+			runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "abc123\n", 0),
+			runner.NewExpectedCommand(mainPath, "git tag --merged v1.2.0", "v1.2.0\n", 0),
+			runner.NewExpectedCommand(mainPath, "git log --no-merges --format=%H%x1f%s%x1f%b%x1e v1.2.0 --", "", 0),
+			runner.NewExpectedCommand(
+				mainPath,
+				fmt.Sprintf(
+					"%s build -trimpath -gcflags all=-N -l -ldflags -X main.version=v1.2.0 -X main.commit=abc123 "+
+						"-X main.date=[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9:]{8}Z -X main.goVersion=go1.8 -tags netgo -o %s",
+					goExecutable,
+					linuxFile,
+				),
+				"",
+				0,
+			).WithEnvironment([]string{"GOOS=linux", "GOARCH=amd64", fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH"))}),
+			runner.NewExpectedCommand(
+				mainPath,
+				fmt.Sprintf("%s build -buildmode pie -gcflags all=-N -l -ldflags -X main.version=1.2.0 -H windowsgui -o %s", goExecutable, windowsFile),
+				"",
+				0,
+			).WithEnvironment([]string{"GOOS=windows", "GOARCH=amd64", fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH"))}),
+		},
+		AnyOrder: true,
+	}
+	expectedRunner.ExpectedCommands = append(expectedRunner.ExpectedCommands, getTagCommands(t, mainPath, "v1.2.0")...)
+	app, _, _ := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+}
+
+func TestReleaseBuildFlagsCommitFailure(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.String("ldflags", "-X main.commit={{.Commit}}", "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "")
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			getDistListCommand(t, mainPath),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+			runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "fatal: not a git repository", 128),
+		},
+	}
+	app, _, _ := appWithTestWriters()
+	err = command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Unable to determine commit: exit status 128")
+}
+
+func TestReleaseTemplatesLookUpCommit(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	for _, config := range []string{
+		"archives:\n  nameTemplate: \"{{.Project}}-{{.Commit}}-{{.OS}}-{{.Arch}}\"\n",
+		"checksums:\n  nameTemplate: \"{{.Project}}_{{.Commit}}_checksums.txt\"\n",
+		"release:\n  name: \"{{.Tag}} ({{.Commit}})\"\n",
+		"release:\n  body: \"Built from {{.Commit}}\"\n",
+		"builds:\n  overrides:\n    linux:\n      gcflags: \"-commit={{.Commit}}\"\n",
+	} {
+		set := flag.NewFlagSet("test", 0)
+		set.String("token", "fakeToken", "doc")
+		set.String("mainPath", mainPath, "doc")
+		assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+		writeConfig(t, mainPath, config)
+		expectedRunner := &runner.Test{
+			ExpectedCommands: []*runner.ExpectedCommand{
+				getDistListCommand(t, mainPath),
+				runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+				runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "fatal: not a git repository", 128),
+			},
+		}
+		app, _, _ := appWithTestWriters()
+		err = command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil))
+		assert.EqualError(t, err, "Unable to determine commit: exit status 128", config)
+		assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands, config)
+	}
+}
+
+func TestReleaseConfigErrors(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	configPath := fmt.Sprintf("%s/.goRelease.yml", mainPath)
+	testCases := []struct {
+		config string
+		err    string
+	}{
+		{"builds:\n  os: linux\n", "2: cannot unmarshal !!str `linux` into []string"},
+		{"releaseNotes:\n  exclude: [\"^chore\", \"(\"]\n", "2: releaseNotes.exclude[1]: invalid pattern (: error parsing regexp: missing closing ): `(`"},
+		{"builds:\n  firstClassOnly: sometimes\n", "2: cannot unmarshal !!str `sometimes` into bool"},
+		{"release:\n  owner: owner\n  tag: v1\n", "3: field tag not found in type command.releaseConfig"},
+		{"release:\n  owner: owner\n  owner: other\n", "3: mapping key \"owner\" already defined at line 2"},
+		{"builds:\n  overrides:\n    linux: &linux\n      trimpath: true\n    darwin:\n      <<: *linux\n      trimpath: yes please\n", "7: cannot unmarshal !!str `yes please` into bool"},
+		{"builds:\n  overrides:\n    linux:\n      <<: [netgo]\n", " yaml: map merge requires map or sequence of maps as the value"},
+		{"builds:\n  os:\n    - linux\n   - darwin\n", "1: did not find expected key"},
+		{"builds:\n\tos: linux\n", "2: found character that cannot start any token"},
+		{"builds:\n  os: [linux, darwin\n", "1: did not find expected ',' or ']'"},
+		{"projectName: \"unterminated\n", "2: found unexpected end of stream"},
+		{"builds:\n  targets:\n    - linux/amd64\n    - linux\n", "4: builds.targets[1]: invalid target linux, expected os/arch"},
+		{"archives:\n  format: rar\n", "2: archives.format: unknown archive format rar (expected one of tar.gz, tar.xz, tar.zst, zip)"},
+		{"archives:\n  formats:\n    linux: tar.gz\n    windows: 7z\n", "4: archives.formats.windows: unknown archive format 7z (expected one of tar.gz, tar.xz, tar.zst, zip)"},
+		{"archives:\n  nameTemplate: \"{{.Project}\"\n", "2: archives.nameTemplate: invalid template"},
+		{"archives:\n  nameTemplate: \"{{.Revision}}\"\n", "2: archives.nameTemplate: invalid template"},
+		{"builds:\n  ldflags: -X main.version={{.Tag}\n", "2: builds.ldflags: invalid template"},
+		{"builds:\n  overrides:\n    windows:\n      gcflags: \"{{.Bogus}}\"\n", "4: builds.overrides.windows.gcflags: invalid template"},
+		{"builds:\n  overrides:\n    linux/arm/v7:\n      trimpath: true\n", "3: builds.overrides.linux/arm/v7: invalid override linux/arm/v7, expected os or os/arch"},
+		{"archives:\n  files:\n    - README.md\n    - dst: doc/\n", "4: archives.files[1]: src is required"},
+		{"archives:\n  files: [\"[a-\"]\n", "2: archives.files[0]: invalid pattern [a-: syntax error in pattern"},
+		{"archives:\n  files:\n    - src: LICENSE\n      dst: ../LICENSE\n", "3: archives.files[0]: dst ../LICENSE must be inside the archive"},
+		{"archives:\n  files:\n    - src: LICENSE\n      destination: doc/\n", "4: field destination not found in type command.archiveFileConfig"},
+		{"archives:\n  overrides:\n    linux/amd64:\n      files: [README.md]\n", "3: archives.overrides.linux/amd64: invalid override linux/amd64, expected os"},
+		{"archives:\n  overrides:\n    windows:\n      files:\n        - optional: true\n", "5: archives.overrides.windows.files[0]: src is required"},
+		{"checksums:\n  algorithm: md5\n", "2: checksums.algorithm: unknown checksum algorithm md5 (expected one of blake2b, sha256, sha512)"},
+		{"checksums:\n  onlyFor:\n    - \"*.zip\"\n    - \"[\"\n", "4: checksums.onlyFor[1]: invalid pattern [: syntax error in pattern"},
+		{"checksums:\n  nameTemplate: \"{{.Checksum}}\"\n", "2: checksums.nameTemplate: invalid template"},
+		{"signing:\n  key: release.asc\n  artifacts: binaries\n", "3: signing.artifacts: unknown signing artifacts binaries (expected one of checksum, all)"},
+		{"minisign:\n  artifacts: every\n", "2: minisign.artifacts: unknown minisign artifacts every (expected one of checksum, all)"},
+		{"sbom:\n  formats: [spdx, swid]\n", "2: sbom.formats[1]: unknown SBOM format swid (expected one of cyclonedx, spdx)"},
+		{"release:\n  onConflict: overwrite\n", "2: release.onConflict: unknown conflict policy overwrite (expected one of fail, replace, skip)"},
+		{"retry:\n  maxAttempts: -1\n", "2: retry.maxAttempts: must be at least 1"},
+		{"retry:\n  delay: soon\n", "2: retry.delay: invalid delay soon"},
+	}
+	for _, testCase := range testCases {
+		set := flag.NewFlagSet("test", 0)
+		set.String("token", "fakeToken", "doc")
+		set.String("mainPath", mainPath, "doc")
+		err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+		assert.Nil(t, err)
+		writeConfig(t, mainPath, testCase.config)
+		app, _, _ := appWithTestWriters()
+		err = command.CmdRelease(&runner.Test{})(cli.NewContext(app, set, nil))
+		if assert.NotNil(t, err, testCase.config) {
+			prefix := fmt.Sprintf("Invalid config %s:%s", configPath, testCase.err)
+			assert.True(t, strings.HasPrefix(err.Error(), prefix), "%q does not start with %q", err.Error(), prefix)
+		}
+
+		cleanUp(t, mainPath)
+	}
+}
+
+func TestReleaseConfigNotFound(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("config", "/doesntexist/.goRelease.yml", "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	app, _, _ := appWithTestWriters()
+	err = command.CmdRelease(&runner.Test{})(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Unable to read config: open /doesntexist/.goRelease.yml: no such file or directory")
+}
+
+func writeConfig(t *testing.T, mainPath, config string) {
+	t.Helper()
+	if _, err := os.Stat(mainPath); os.IsNotExist(err) {
+		assert.Nil(t, os.Mkdir(mainPath, 0777))
+	}
+
+	assert.Nil(t, ioutil.WriteFile(fmt.Sprintf("%s/.goRelease.yml", mainPath), []byte(config), 0644))
+}
@@ -0,0 +1,74 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/urfave/cli"
+)
+
+// What happens when the release already has an asset with the same name as one being uploaded
+const (
+	onConflictFail    = "fail"
+	onConflictSkip    = "skip"
+	onConflictReplace = "replace"
+)
+
+var conflictPolicies = []string{onConflictFail, onConflictReplace, onConflictSkip}
+
+// conflictOption reads the conflict policy from the flags and config.  removeOldAssets is kept as another way of
+// asking for replace.
+func conflictOption(c *cli.Context, cfg *config) (string, error) {
+	onConflict := stringOption(c.String("onConflict"), cfg.Release.OnConflict)
+	if onConflict == "" && (c.Bool("removeOldAssets") || cfg.Release.RemoveOldAssets) {
+		return onConflictReplace, nil
+	}
+
+	onConflict = stringOption(onConflict, onConflictFail)
+	if !contains(conflictPolicies, onConflict) {
+		return "", cli.NewExitError(fmt.Sprintf("Unknown conflict policy %s (expected one of %s)", onConflict, strings.Join(conflictPolicies, ", ")), 1)
+	}
+
+	return onConflict, nil
+}
+
+// loadExistingAssets looks up the assets that are already on the release so that uploads with the same name can be
+// handled with the conflict policy
+func (release *githubRelease) loadExistingAssets() error {
+	assets, err := getAssets(release.client, release.id, release.owner, release.repo)
+	if err != nil {
+		return err
+	}
+
+	release.existing = make(map[string]*github.ReleaseAsset, len(assets))
+	for _, asset := range assets {
+		release.existing[asset.GetName()] = asset
+	}
+
+	return nil
+}
+
+// resolveConflict applies the conflict policy to an asset that is about to be uploaded.  It reports whether the
+// upload should go ahead.  An asset that an earlier run uploaded and that has changed since is always replaced, it is
+// stale and the conflict policy is only for assets that goRelease did not make.
+func (release *githubRelease) resolveConflict(name, digest string) (bool, error) {
+	existing, ok := release.existing[name]
+	if !ok {
+		return true, nil
+	}
+
+	if release.stale(name, digest) {
+		return true, deleteAsset(release.client, release.owner, release.repo, existing)
+	}
+
+	switch release.onConflict {
+	case onConflictSkip:
+		release.progress.skip(name, "the release already has it")
+		return false, nil
+	case onConflictReplace:
+		return true, deleteAsset(release.client, release.owner, release.repo, existing)
+	}
+
+	return false, fmt.Errorf("The release already has an asset named %s", name)
+}
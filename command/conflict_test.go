@@ -0,0 +1,148 @@
+package command_test
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestReleaseOnConflictReplace(t *testing.T) {
+	uploads := make(map[string][]byte)
+	deleted := []string{}
+	ts, release := getConflictTestServer(t, uploads, &deleted)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := getSummaryFlagSet(t, ts.URL, mainPath)
+	set.String("onConflict", "replace", "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/386, linux/amd64]\n")
+	app, _, _ := appWithTestWriters()
+	err := command.CmdRelease(getSummaryRunner(t, mainPath))(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Failed targets: linux/386")
+
+	// The linux/386 archive from an earlier run is left alone because its build failed
+	sort.Strings(deleted)
+	assert.Equal(t, []string{"/repos/owner/repo/releases/assets/7", "/repos/owner/repo/releases/assets/9"}, deleted)
+	assert.Contains(t, uploads, "projectName_tag_checksums.txt")
+	assert.Contains(t, uploads, "projectName-linux-amd64-go1.8-tag.tar.gz")
+}
+
+func TestReleaseRemoveOldAssetsReplaces(t *testing.T) {
+	uploads := make(map[string][]byte)
+	deleted := []string{}
+	ts, release := getConflictTestServer(t, uploads, &deleted)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := getSummaryFlagSet(t, ts.URL, mainPath)
+	set.Bool("removeOldAssets", true, "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/386, linux/amd64]\n")
+	app, _, _ := appWithTestWriters()
+	err := command.CmdRelease(getSummaryRunner(t, mainPath))(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Failed targets: linux/386")
+	sort.Strings(deleted)
+	assert.Equal(t, []string{"/repos/owner/repo/releases/assets/7", "/repos/owner/repo/releases/assets/9"}, deleted)
+}
+
+func TestReleaseOnConflictSkip(t *testing.T) {
+	uploads := make(map[string][]byte)
+	deleted := []string{}
+	ts, release := getConflictTestServer(t, uploads, &deleted)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := getSummaryFlagSet(t, ts.URL, mainPath)
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\nrelease:\n  onConflict: skip\n")
+	expectedRunner := &runner.Test{
+		ExpectedCommands: getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+			return operatingSystem == "linux" && architecture == "amd64"
+		}),
+		AnyOrder: true,
+	}
+	app, writer, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "", errWriter.String())
+	assert.Equal(t, []string{}, deleted)
+	assert.Equal(t, []string{}, uploadNames(uploads))
+	assert.True(
+		t,
+		strings.HasPrefix(
+			writer.String(),
+			"Skipped projectName-linux-amd64-go1.8-tag.tar.gz, the release already has it\n"+
+				"Skipped projectName_tag_checksums.txt, the release already has it\n",
+		),
+		writer.String(),
+	)
+}
+
+func TestReleaseInvalidOnConflict(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("onConflict", "overwrite", "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	app, _, _ := appWithTestWriters()
+	err := command.CmdRelease(&runner.Test{})(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Unknown conflict policy overwrite (expected one of fail, replace, skip)")
+}
+
+// getConflictTestServer is a release test server where release 1 already has the checksums (7) and the linux/386 (8)
+// and linux/amd64 (9) archives.  Deleted assets are recorded in deleted.
+func getConflictTestServer(t *testing.T, uploads map[string][]byte, deleted *[]string) (*httptest.Server, *httptest.Server) {
+	t.Helper()
+	return getRetryTestServer(t, uploads, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == "GET" && r.URL.String() == "/repos/owner/repo/releases/1/assets?per_page=100" {
+			assets := []*github.ReleaseAsset{}
+			for id, name := range map[int]string{
+				7: "projectName_tag_checksums.txt",
+				8: "projectName-linux-386-go1.8-tag.tar.gz",
+				9: "projectName-linux-amd64-go1.8-tag.tar.gz",
+			} {
+				id, name := id, name
+				assets = append(assets, &github.ReleaseAsset{ID: &id, Name: &name})
+			}
+
+			bytes, _ := json.Marshal(assets)
+			fmt.Fprint(w, string(bytes))
+			return true
+		}
+
+		if r.Method == "DELETE" {
+			*deleted = append(*deleted, r.URL.String())
+			w.WriteHeader(http.StatusNoContent)
+			return true
+		}
+
+		return false
+	})
+}
+
+func uploadNames(uploads map[string][]byte) []string {
+	names := []string{}
+	for name := range uploads {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
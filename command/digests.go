@@ -0,0 +1,156 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/go-github/github"
+)
+
+// digestManifestName is the release asset that records the digest of every other asset on the release so that a
+// release can be run again without uploading the assets that have not changed
+const digestManifestName = "digests.json"
+
+// releaseDigests are the digests of the assets on a release by name, as recorded by the last run and as uploaded by
+// this one.  Assets are only compared with the last run when compare is set.
+type releaseDigests struct {
+	compare  bool
+	mutex    sync.Mutex
+	previous map[string]string
+	current  map[string]string
+}
+
+// loadDigests reads the digest manifest from the release.  A manifest that cannot be read is reported and ignored, the
+// assets are then all uploaded again.
+func (release *githubRelease) loadDigests(compare bool, errWriter io.Writer) error {
+	release.digests = &releaseDigests{compare: compare, previous: map[string]string{}, current: map[string]string{}}
+	asset, ok := release.existing[digestManifestName]
+	if !ok {
+		return nil
+	}
+
+	contents, err := downloadAsset(release.client, release.owner, release.repo, asset)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(contents, &release.digests.previous)
+	if err != nil {
+		fmt.Fprintf(errWriter, "Ignoring the %s on the release: %v\n", digestManifestName, err)
+		release.digests.previous = map[string]string{}
+	}
+
+	return nil
+}
+
+// unchanged reports whether the release already has an asset with the same name and digest
+func (release *githubRelease) unchanged(name, digest string) bool {
+	if !release.digests.compare {
+		return false
+	}
+
+	_, ok := release.existing[name]
+	return ok && release.digests.previous[name] == digest
+}
+
+// stale reports whether the digest manifest has a different digest for the asset, so it was uploaded by an earlier
+// run and has changed since
+func (release *githubRelease) stale(name, digest string) bool {
+	previous, ok := release.digests.previous[name]
+	return ok && previous != digest
+}
+
+func (release *githubRelease) recordDigest(name, digest string) {
+	release.digests.mutex.Lock()
+	defer release.digests.mutex.Unlock()
+	release.digests.current[name] = digest
+}
+
+// uploadDigests writes the digest manifest to dist and uploads it in place of the old one.  The digests from the last
+// run are kept for the assets that are still on the release and were not uploaded again.  Nothing is uploaded when
+// the manifest has not changed.
+func (release *githubRelease) uploadDigests(dist string) error {
+	digests := map[string]string{}
+	for name, digest := range release.digests.previous {
+		if _, ok := release.existing[name]; ok {
+			digests[name] = digest
+		}
+	}
+
+	for name, digest := range release.digests.current {
+		digests[name] = digest
+	}
+
+	contents, err := json.MarshalIndent(digests, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	old, ok := release.existing[digestManifestName]
+	if !ok && len(digests) == 0 {
+		return nil
+	}
+
+	if ok {
+		previous, _ := json.MarshalIndent(release.digests.previous, "", "  ")
+		if bytes.Equal(previous, contents) {
+			return nil
+		}
+	}
+
+	fileName := filepath.Join(dist, digestManifestName)
+	err = ioutil.WriteFile(fileName, append(contents, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		err = deleteAsset(release.client, release.owner, release.repo, old)
+		if err != nil {
+			return err
+		}
+	}
+
+	return uploadToRelease(release.client, release.id, release.owner, release.repo, fileName, release.progress)
+}
+
+// downloadAsset reads the contents of a release asset, following github's redirect to where it is stored
+func downloadAsset(client *githubClient, owner, repo string, asset *github.ReleaseAsset) ([]byte, error) {
+	var contents []byte
+	err := client.retry.do(fmt.Sprintf("downloading %s", asset.GetName()), func() (*github.Response, error) {
+		reader, redirectURL, err := client.Repositories.DownloadReleaseAsset(context.Background(), owner, repo, asset.GetID())
+		if err != nil {
+			return nil, err
+		}
+
+		if redirectURL != "" {
+			response, err := http.Get(redirectURL)
+			if err != nil {
+				return nil, err
+			}
+
+			if response.StatusCode != http.StatusOK {
+				_ = response.Body.Close()
+				return &github.Response{Response: response}, fmt.Errorf("GET %s: %d", redirectURL, response.StatusCode)
+			}
+
+			reader = response.Body
+		}
+
+		defer func() {
+			_ = reader.Close()
+		}()
+
+		contents, err = ioutil.ReadAll(reader)
+		return nil, err
+	})
+
+	return contents, err
+}
@@ -0,0 +1,304 @@
+package command_test
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestReleaseSkipsUnchanged(t *testing.T) {
+	assets := newTestReleaseAssets()
+	ts, release := getDigestTestServer(t, assets)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	runDigestRelease(t, ts.URL, mainPath, "foo")
+	assert.Equal(
+		t,
+		[]string{
+			"digests.json",
+			"projectName-linux-amd64-go1.8-tag.tar.gz",
+			"projectName-windows-amd64-go1.8-tag.zip",
+			"projectName_tag_checksums.txt",
+		},
+		assets.names(),
+	)
+	digests := map[string]string{}
+	assert.Nil(t, json.Unmarshal(assets.contents["digests.json"], &digests))
+	assert.Equal(t, testDigest(assets.contents["projectName_tag_checksums.txt"]), digests["projectName_tag_checksums.txt"])
+
+	assets.requests = []string{}
+	writer := runDigestRelease(t, ts.URL, mainPath, "foo")
+	assert.Equal(t, []string{}, assets.requests)
+	assert.Contains(t, writer, "Skipped projectName-linux-amd64-go1.8-tag.tar.gz, it has not changed\n")
+	assert.Contains(t, writer, "Skipped projectName-windows-amd64-go1.8-tag.zip, it has not changed\n")
+	assert.Contains(t, writer, "Skipped projectName_tag_checksums.txt, it has not changed\n")
+}
+
+func TestReleaseUploadsChanged(t *testing.T) {
+	assets := newTestReleaseAssets()
+	ts, release := getDigestTestServer(t, assets)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	runDigestRelease(t, ts.URL, mainPath, "foo")
+	assets.requests = []string{}
+	runDigestRelease(t, ts.URL, mainPath, "bar")
+	assert.Equal(
+		t,
+		[]string{
+			"DELETE projectName-windows-amd64-go1.8-tag.zip",
+			"POST projectName-windows-amd64-go1.8-tag.zip",
+			"DELETE projectName_tag_checksums.txt",
+			"POST projectName_tag_checksums.txt",
+			"DELETE digests.json",
+			"POST digests.json",
+		},
+		assets.requests,
+	)
+}
+
+func TestReleaseRetryAfterPartialFailure(t *testing.T) {
+	assets := newTestReleaseAssets()
+	ts, release := getDigestTestServer(t, assets)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	set := getSummaryFlagSet(t, ts.URL, mainPath)
+	set.Bool("allowPartial", true, "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64, windows/amd64]\n")
+	expectedRunner := &runner.Test{
+		ExpectedCommands: append(
+			getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+				return operatingSystem == "linux" && architecture == "amd64"
+			}),
+			runner.NewExpectedCommand(
+				mainPath,
+				fmt.Sprintf("%s build -o %s", goExecutable, distFile(mainPath, "windows", "amd64", "projectName-windows-amd64-go1.8-tag.exe")),
+				"Build error",
+				2,
+			).WithEnvironment([]string{"GOOS=windows", "GOARCH=amd64", fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH"))}),
+		),
+		AnyOrder: true,
+	}
+	app, _, _ := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []string{"digests.json", "projectName-linux-amd64-go1.8-tag.tar.gz", "projectName_tag_checksums.txt"}, assets.names())
+
+	// The retry uses the default conflict policy, the checksums file that changed is replaced because the last run made it
+	assets.requests = []string{}
+	writer := runDigestRelease(t, ts.URL, mainPath, "foo")
+	assert.Equal(
+		t,
+		[]string{
+			"POST projectName-windows-amd64-go1.8-tag.zip",
+			"DELETE projectName_tag_checksums.txt",
+			"POST projectName_tag_checksums.txt",
+			"DELETE digests.json",
+			"POST digests.json",
+		},
+		assets.requests,
+	)
+	assert.Contains(t, writer, "Skipped projectName-linux-amd64-go1.8-tag.tar.gz, it has not changed\n")
+}
+
+func TestReleaseConflictWithUnknownAsset(t *testing.T) {
+	assets := newTestReleaseAssets()
+	assets.add("projectName-windows-amd64-go1.8-tag.zip", []byte("uploaded by hand"))
+	ts, release := getDigestTestServer(t, assets)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	set := getSummaryFlagSet(t, ts.URL, mainPath)
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64, windows/amd64]\n")
+	expectedRunner := &runner.Test{
+		ExpectedCommands: getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+			return architecture == "amd64" && (operatingSystem == "linux" || operatingSystem == "windows")
+		}),
+		AnyOrder: true,
+	}
+	app, _, errWriter := appWithTestWriters()
+	assert.EqualError(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)), "Failed targets: windows/amd64")
+	assert.Contains(t, errWriter.String(), "The release already has an asset named projectName-windows-amd64-go1.8-tag.zip")
+	assert.Equal(t, []byte("uploaded by hand"), assets.contents["projectName-windows-amd64-go1.8-tag.zip"])
+}
+
+func TestReleaseUploadUnchanged(t *testing.T) {
+	assets := newTestReleaseAssets()
+	ts, release := getDigestTestServer(t, assets)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	runDigestRelease(t, ts.URL, mainPath, "foo")
+	assets.requests = []string{}
+	runDigestRelease(t, ts.URL, mainPath, "foo", "uploadUnchanged", "removeOldAssets")
+	sort.Strings(assets.requests)
+	assert.Equal(
+		t,
+		[]string{
+			"DELETE projectName-linux-amd64-go1.8-tag.tar.gz",
+			"DELETE projectName-windows-amd64-go1.8-tag.zip",
+			"DELETE projectName_tag_checksums.txt",
+			"POST projectName-linux-amd64-go1.8-tag.tar.gz",
+			"POST projectName-windows-amd64-go1.8-tag.zip",
+			"POST projectName_tag_checksums.txt",
+		},
+		assets.requests,
+	)
+}
+
+func TestReleaseInvalidDigests(t *testing.T) {
+	assets := newTestReleaseAssets()
+	assets.add("digests.json", []byte("not json"))
+	ts, release := getDigestTestServer(t, assets)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	set := getSummaryFlagSet(t, ts.URL, mainPath)
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+	expectedRunner := &runner.Test{
+		ExpectedCommands: getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+			return operatingSystem == "linux" && architecture == "amd64"
+		}),
+		AnyOrder: true,
+	}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "Ignoring the digests.json on the release: invalid character 'o' in literal null (expecting 'u')\n", errWriter.String())
+	digests := map[string]string{}
+	assert.Nil(t, json.Unmarshal(assets.contents["digests.json"], &digests))
+	assert.Equal(t, 2, len(digests))
+}
+
+// runDigestRelease releases linux/amd64 and windows/amd64 with the bool flags that are given set, and returns the
+// output.  The windows binary is built with windowsBinary in it.
+func runDigestRelease(t *testing.T, url, mainPath, windowsBinary string, boolFlags ...string) string {
+	t.Helper()
+	set := getSummaryFlagSet(t, url, mainPath)
+	for _, name := range boolFlags {
+		set.Bool(name, true, "doc")
+	}
+
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	createFiles(t, mainPath, "tag")
+	writeTestFile(t, distFile(mainPath, "windows", "amd64", "projectName-windows-amd64-go1.8-tag.exe"), windowsBinary, 0777)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64, windows/amd64]\n")
+	expectedRunner := &runner.Test{
+		ExpectedCommands: getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+			return architecture == "amd64" && (operatingSystem == "linux" || operatingSystem == "windows")
+		}),
+		AnyOrder: true,
+	}
+	app, writer, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "", errWriter.String())
+	return writer.String()
+}
+
+// testReleaseAssets are the assets on a fake release, requests records the assets that were deleted or uploaded
+type testReleaseAssets struct {
+	nextID   int
+	ids      map[string]int
+	contents map[string][]byte
+	requests []string
+}
+
+func newTestReleaseAssets() *testReleaseAssets {
+	return &testReleaseAssets{nextID: 10, ids: map[string]int{}, contents: map[string][]byte{}}
+}
+
+func (assets *testReleaseAssets) add(name string, contents []byte) {
+	assets.nextID++
+	assets.ids[name] = assets.nextID
+	assets.contents[name] = contents
+}
+
+func (assets *testReleaseAssets) named(id string) string {
+	for name, assetID := range assets.ids {
+		if fmt.Sprintf("%d", assetID) == id {
+			return name
+		}
+	}
+
+	return ""
+}
+
+func (assets *testReleaseAssets) names() []string {
+	names := []string{}
+	for name := range assets.ids {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// getDigestTestServer is a release test server where release 1 keeps the assets that are uploaded to it
+func getDigestTestServer(t *testing.T, assets *testReleaseAssets) (*httptest.Server, *httptest.Server) {
+	t.Helper()
+	return getRetryTestServer(t, nil, func(w http.ResponseWriter, r *http.Request) bool {
+		assetPath := "/repos/owner/repo/releases/assets/"
+		switch {
+		case r.Method == "GET" && r.URL.String() == "/repos/owner/repo/releases/1/assets?per_page=100":
+			list := []*github.ReleaseAsset{}
+			for _, name := range assets.names() {
+				id, name := assets.ids[name], name
+				list = append(list, &github.ReleaseAsset{ID: &id, Name: &name})
+			}
+
+			bytes, _ := json.Marshal(list)
+			fmt.Fprint(w, string(bytes))
+		case r.Method == "POST" && strings.HasPrefix(r.URL.String(), "/repos/owner/repo/releases/1/assets?name="):
+			name := r.URL.Query().Get("name")
+			body, err := ioutil.ReadAll(r.Body)
+			assert.Nil(t, err)
+			assets.add(name, body)
+			assets.requests = append(assets.requests, fmt.Sprintf("POST %s", name))
+			fmt.Fprint(w, "{}")
+		case r.Method == "GET" && strings.HasPrefix(r.URL.Path, assetPath):
+			_, _ = w.Write(assets.contents[assets.named(strings.TrimPrefix(r.URL.Path, assetPath))])
+		case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, assetPath):
+			name := assets.named(strings.TrimPrefix(r.URL.Path, assetPath))
+			delete(assets.ids, name)
+			delete(assets.contents, name)
+			assets.requests = append(assets.requests, fmt.Sprintf("DELETE %s", name))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			return false
+		}
+
+		return true
+	})
+}
+
+func testDigest(contents []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(contents))
+}
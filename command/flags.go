@@ -1,6 +1,10 @@
 package command
 
-import "github.com/urfave/cli"
+import (
+	"strings"
+
+	"github.com/urfave/cli"
+)
 
 // Flags is the valid command parameters
 var Flags = []cli.Flag{
@@ -17,16 +21,224 @@ var Flags = []cli.Flag{
 		Name:  "mainPath, p",
 		Usage: "The path that contains the main package (Default: current)",
 	},
+	cli.StringFlag{
+		Name:  "config, c",
+		Usage: "The config file to use (Default: .goRelease.yml in mainPath)",
+	},
 	cli.StringSliceFlag{
 		Name:  "os",
 		Usage: "Set the OSes to build against",
 	},
+	cli.StringSliceFlag{
+		Name:  "arch",
+		Usage: "Set the architectures to build against",
+	},
+	cli.StringSliceFlag{
+		Name:  "target",
+		Usage: "Set the os/arch targets to build against.  Prefix a target with ! to exclude it (e.g. !linux/s390x)",
+	},
+	cli.BoolFlag{
+		Name:  "firstClassOnly",
+		Usage: "Only build the first class ports reported by 'go tool dist list'",
+	},
+	cli.StringFlag{
+		Name:  "ldflags",
+		Usage: "The -ldflags to pass to go build.  Can use {{.Tag}}, {{.Version}}, {{.Commit}}, {{.Date}}, {{.GoVersion}}, {{.OS}} and {{.Arch}}",
+	},
+	cli.StringSliceFlag{
+		Name:  "tags",
+		Usage: "The build tags to pass to go build",
+	},
+	cli.BoolFlag{
+		Name:  "trimpath",
+		Usage: "Pass -trimpath to go build",
+	},
+	cli.BoolFlag{
+		Name:  "reproducible",
+		Usage: "Build with -trimpath, an empty -buildid and SOURCE_DATE_EPOCH (default: when the tag was committed) so the binaries can be rebuilt byte for byte",
+	},
+	cli.StringFlag{
+		Name:  "buildmode",
+		Usage: "The -buildmode to pass to go build",
+	},
+	cli.StringFlag{
+		Name:  "gcflags",
+		Usage: "The -gcflags to pass to go build.  Can use the same variables as --ldflags",
+	},
+	cli.StringFlag{
+		Name:  "checksumAlgorithm",
+		Usage: "The hash to use for the checksums file: sha256 (default), sha512 or blake2b",
+	},
+	cli.StringSliceFlag{
+		Name:  "checksumsOnlyFor",
+		Usage: "Only include assets matching these patterns in the checksums file (e.g. *.tar.gz)",
+	},
+	cli.BoolFlag{
+		Name:  "checksumSidecars",
+		Usage: "Upload a checksum file for each asset alongside it (e.g. foo.tar.gz.sha256)",
+	},
+	cli.StringFlag{
+		Name:  "signingKey",
+		Usage: "An armored OpenPGP private key to sign the release with.  The passphrase is read from $GO_RELEASE_SIGNING_PASSPHRASE",
+	},
+	cli.StringFlag{
+		Name:  "signingPublicKey",
+		Usage: "An OpenPGP public key to verify the signatures with before they are uploaded",
+	},
+	cli.StringFlag{
+		Name:  "signArtifacts",
+		Usage: "What to sign: checksum (the checksums file, default) or all (every asset)",
+	},
+	cli.StringFlag{
+		Name:  "minisignKey",
+		Usage: "A minisign secret key to sign the release with.  The key can also be given in $GO_RELEASE_MINISIGN_KEY and the password is read from $GO_RELEASE_MINISIGN_PASSWORD",
+	},
+	cli.StringFlag{
+		Name:  "minisignPublicKey",
+		Usage: "A minisign public key to verify the minisign signatures with before they are uploaded",
+	},
+	cli.StringFlag{
+		Name:  "minisignArtifacts",
+		Usage: "What to sign with minisign: checksum (the checksums file, default) or all (every asset)",
+	},
+	cli.StringSliceFlag{
+		Name:  "sbom",
+		Usage: "Upload an SBOM for each asset in these formats: cyclonedx or spdx",
+	},
+	cli.StringFlag{
+		Name:  "provenanceKey",
+		Usage: "A PEM Ed25519 or ECDSA P-256 private key to sign an SLSA provenance attestation for each asset with",
+	},
+	cli.StringFlag{
+		Name:  "provenancePublicKey",
+		Usage: "The public key for --provenanceKey, checked before anything is built",
+	},
+	cli.BoolFlag{
+		Name:  "dryRun",
+		Usage: "Print the builds, archives and github API calls for the release without building or changing anything",
+	},
+	cli.StringFlag{
+		Name:  "planFormat",
+		Usage: "How --dryRun prints the plan: text (default) or json",
+	},
 	cli.BoolFlag{
 		Name:  "publish",
 		Usage: "Should the new release be published.  If not specified and the release does not exist, the release will be created as draft.",
 	},
+	cli.StringFlag{
+		Name:  "releaseNotesFile",
+		Usage: "Use the notes in this file for a new release instead of generating them from the commits since the previous tag",
+	},
+	cli.StringFlag{
+		Name:  "releaseName",
+		Usage: "The title of the release.  Can use {{.Project}}, {{.Tag}}, {{.Version}}, {{.Commit}} and {{.Date}}",
+	},
+	cli.StringFlag{
+		Name:  "releaseBody",
+		Usage: "The description of the release instead of the release notes.  Can use the same variables as --releaseName",
+	},
+	cli.StringFlag{
+		Name:  "targetCommitish",
+		Usage: "The branch or commit the tag is created from if it does not exist (default: the default branch).  Can use the same variables as --releaseName",
+	},
+	cli.StringFlag{
+		Name:  "prerelease",
+		Usage: "Whether the release is a prerelease, true or false (default: when the tag is a semantic version with a prerelease such as v1.2.0-rc.1)",
+	},
 	cli.BoolFlag{
 		Name:  "removeOldAssets",
-		Usage: "Should the old assets be remove before uploading.",
+		Usage: "Replace assets that have the same name as an uploaded asset, the same as --onConflict replace",
+	},
+	cli.BoolFlag{
+		Name:  "uploadUnchanged",
+		Usage: "Upload every asset, even the ones the release already has with the same digest",
+	},
+	cli.StringFlag{
+		Name:  "onConflict",
+		Usage: "What to do when the release already has an asset with the same name: fail (default), skip or replace",
+	},
+	cli.StringFlag{
+		Name:  "dist, d",
+		Usage: "The directory to write build output and artifacts.json to (Default: dist in mainPath)",
 	},
+	cli.BoolFlag{
+		Name:  "keep, k",
+		Usage: "Keep the built files in the dist directory after they are uploaded",
+	},
+	cli.StringFlag{
+		Name:  "bump",
+		Usage: "Release the next version instead of a tag: auto (from the Conventional Commits since the last tag), major, minor or patch",
+	},
+	cli.BoolFlag{
+		Name:  "skipVersionCheck",
+		Usage: "Release a new tag even if it is not a semantic version (e.g. v1.2.3) greater than the latest release",
+	},
+	cli.BoolFlag{
+		Name:  "allowDirty",
+		Usage: "Release even though the working tree has uncommitted changes",
+	},
+	cli.BoolFlag{
+		Name:  "allowPartial",
+		Usage: "Succeed when some targets fail, as long as the required targets and the checksums do not",
+	},
+	cli.StringSliceFlag{
+		Name:  "requiredTargets",
+		Usage: "A target (os/arch) that has to be released, others are allowed to fail (can be repeated)",
+	},
+	cli.IntFlag{
+		Name:  "maxAttempts",
+		Usage: "How many times a github API call or upload is tried before giving up",
+	},
+	cli.IntFlag{
+		Name:  "buildConcurrency",
+		Usage: "How many targets are built at once (defaults to the number of CPUs)",
+	},
+	cli.IntFlag{
+		Name:  "uploadConcurrency",
+		Usage: "How many assets are uploaded at once (defaults to 4)",
+	},
+}
+
+// BuildFlags are the valid build parameters
+var BuildFlags = flagsNamed("mainPath", "config", "os", "arch", "target", "firstClassOnly", "ldflags", "tags", "trimpath", "reproducible", "buildmode", "gcflags", "dist", "allowDirty", "allowPartial", "requiredTargets", "buildConcurrency")
+
+// PackageFlags are the valid package parameters
+var PackageFlags = flagsNamed(
+	"apiUrl",
+	"mainPath",
+	"config",
+	"checksumAlgorithm",
+	"checksumsOnlyFor",
+	"checksumSidecars",
+	"signingKey",
+	"signingPublicKey",
+	"signArtifacts",
+	"minisignKey",
+	"minisignPublicKey",
+	"minisignArtifacts",
+	"sbom",
+	"provenanceKey",
+	"provenancePublicKey",
+	"dist",
+	"allowPartial",
+	"requiredTargets",
+	"buildConcurrency",
+	"uploadConcurrency",
+)
+
+// PublishFlags are the valid publish parameters
+var PublishFlags = flagsNamed("token", "apiUrl", "mainPath", "config", "publish", "releaseNotesFile", "releaseName", "releaseBody", "targetCommitish", "prerelease", "removeOldAssets", "uploadUnchanged", "skipVersionCheck", "onConflict", "dist", "allowPartial", "requiredTargets", "maxAttempts", "uploadConcurrency")
+
+// flagsNamed picks flags out of Flags so that each subcommand shares the release command's flag definitions
+func flagsNamed(names ...string) []cli.Flag {
+	flags := make([]cli.Flag, 0, len(names))
+	for _, name := range names {
+		for _, flag := range Flags {
+			if strings.Split(flag.GetName(), ",")[0] == name {
+				flags = append(flags, flag)
+			}
+		}
+	}
+
+	return flags
 }
@@ -0,0 +1,148 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/guywithnose/runner"
+	"github.com/urfave/cli"
+)
+
+// statusCommand lists the changes in the working tree except for the ones in dist, which is left over from earlier builds
+// when it is not ignored
+func statusCommand(mainPath, dist string) []string {
+	args := []string{"git", "status", "--porcelain"}
+	relative, err := filepath.Rel(mainPath, dist)
+	if err != nil || relative == "." || strings.HasPrefix(relative, "..") {
+		return args
+	}
+
+	return append(args, "--", fmt.Sprintf(":!%s", filepath.ToSlash(relative)))
+}
+
+// checkWorkingTree makes sure that the working tree apart from dist is clean unless allowDirty is set
+func checkWorkingTree(cmdWrapper runner.Builder, mainPath, dist, tagName string, allowDirty bool, errWriter io.Writer) error {
+	output, err := cmdWrapper.New(mainPath, statusCommand(mainPath, dist)...).Output()
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Unable to check the working tree: %v", err), 1)
+	}
+
+	if changes := strings.TrimRight(string(output), "\n"); changes != "" {
+		if !allowDirty {
+			return cli.NewExitError(fmt.Sprintf("The working tree has uncommitted changes, commit them or pass --allowDirty:\n%s", changes), 1)
+		}
+
+		fmt.Fprintf(errWriter, "Releasing %s with uncommitted changes\n", tagName)
+	}
+
+	return nil
+}
+
+// releaseTag is what the local repository and origin have for the tag being released
+type releaseTag struct {
+	commit string
+	local  string
+	remote string
+}
+
+// checkTag makes sure that the release is of the commit that is built, which is HEAD unless commit is given.  The tag
+// has to be at that commit both here and on origin.  A tag that does not exist yet is created at the commit and pushed
+// to origin, so github does not create it on the head of the default branch.  The git commands that create and push
+// the tag are returned, a dry run only checks and returns the ones it would run.
+func checkTag(cmdWrapper runner.Builder, mainPath, tagName, commit string, dryRun bool, writer io.Writer) ([][]string, error) {
+	tag, err := findTag(cmdWrapper, mainPath, tagName, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	built, hint := "HEAD is", ", check out the tag to release it"
+	if commit != "" {
+		built, hint = "the build is of", ", build the tag to publish it"
+	}
+
+	if tag.local != "" && tag.local != tag.commit {
+		return nil, cli.NewExitError(fmt.Sprintf("%s is %s but %s %s%s", tagName, tag.local, built, tag.commit, hint), 1)
+	}
+
+	if tag.remote != "" && tag.remote != tag.commit {
+		return nil, cli.NewExitError(fmt.Sprintf("%s is %s on origin but %s %s", tagName, tag.remote, built, tag.commit), 1)
+	}
+
+	create := []string{"git", "tag", tagName, tag.commit}
+	push := []string{"git", "push", "origin", fmt.Sprintf("refs/tags/%s", tagName)}
+	commands := [][]string{}
+	if tag.local == "" {
+		commands = append(commands, create)
+	}
+
+	if tag.remote == "" {
+		commands = append(commands, push)
+	}
+
+	if dryRun {
+		return commands, nil
+	}
+
+	if tag.local == "" {
+		output, err := cmdWrapper.New(mainPath, create...).CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to create tag %s: %v %s", tagName, err, strings.TrimSpace(string(output)))
+		}
+
+		fmt.Fprintf(writer, "Created tag %s at %s\n", tagName, tag.commit)
+	}
+
+	if tag.remote == "" {
+		output, err := cmdWrapper.New(mainPath, push...).CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to push tag %s to origin: %v %s", tagName, err, strings.TrimSpace(string(output)))
+		}
+
+		fmt.Fprintf(writer, "Pushed tag %s to origin\n", tagName)
+	}
+
+	return commands, nil
+}
+
+// findTag looks up the commit that the tag points to, along with HEAD when commit is not given.  The tag is empty where
+// it does not exist.
+func findTag(cmdWrapper runner.Builder, mainPath, tagName, commit string) (releaseTag, error) {
+	tag := releaseTag{commit: commit}
+	if tag.commit == "" {
+		head, err := getCommit(cmdWrapper, mainPath)
+		if err != nil {
+			return tag, err
+		}
+
+		tag.commit = head
+	}
+
+	ref := fmt.Sprintf("refs/tags/%s", tagName)
+
+	// --verify --quiet fails without any output when the tag does not exist
+	output, err := cmdWrapper.New(mainPath, "git", "rev-parse", "--verify", "--quiet", fmt.Sprintf("%s^{commit}", ref)).Output()
+	if err == nil {
+		tag.local = strings.TrimSpace(string(output))
+	}
+
+	output, err = cmdWrapper.New(mainPath, "git", "ls-remote", "--tags", "origin", ref, fmt.Sprintf("%s^{}", ref)).Output()
+	if err != nil {
+		return tag, cli.NewExitError(fmt.Sprintf("Unable to look up %s on origin: %v", tagName, err), 1)
+	}
+
+	// An annotated tag is listed twice, and the commit it points to is the one with ^{}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		if fields[1] == fmt.Sprintf("%s^{}", ref) || (fields[1] == ref && tag.remote == "") {
+			tag.remote = fields[0]
+		}
+	}
+
+	return tag, nil
+}
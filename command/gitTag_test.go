@@ -0,0 +1,303 @@
+package command_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"testing"
+
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestReleaseCreatesTag(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	expectedRunner := getTagRunner(
+		t,
+		mainPath,
+		true,
+		runner.NewExpectedCommand(mainPath, "git status --porcelain -- :!dist", "", 0),
+		runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "abc123\n", 0),
+		runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/tag^{commit}"), "", 1),
+		runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git ls-remote --tags origin refs/tags/tag refs/tags/tag^{}"), "", 0),
+		runner.NewExpectedCommand(mainPath, "git tag tag abc123", "", 0),
+		runner.NewExpectedCommand(mainPath, "git push origin refs/tags/tag", "", 0),
+	)
+	output, errOutput, err := runTagRelease(t, mainPath, expectedRunner)
+	assert.Nil(t, err)
+	assert.Contains(t, output, "Created tag tag at abc123\nPushed tag tag to origin\n")
+	assert.Equal(t, "", errOutput)
+}
+
+func TestReleaseCreatesTagReproducibly(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	expectedRunner := getTagRunner(
+		t,
+		mainPath,
+		false,
+		runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/tag^{commit}"), "", 1),
+		runner.NewExpectedCommand(mainPath, "git log -1 --format=%ct HEAD --", "1500000000\n", 0),
+		runner.NewExpectedCommand(mainPath, "git status --porcelain -- :!dist", "", 0),
+		runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "abc123\n", 0),
+		runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/tag^{commit}"), "", 1),
+		runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git ls-remote --tags origin refs/tags/tag refs/tags/tag^{}"), "", 0),
+		runner.NewExpectedCommand(mainPath, "git tag tag abc123", "", 0),
+		runner.NewExpectedCommand(mainPath, "git push origin refs/tags/tag", "", 0),
+		runner.NewExpectedCommand(
+			mainPath,
+			fmt.Sprintf("%s build -trimpath -ldflags -buildid= -o %s", goExecutable, distFile(mainPath, "linux", "amd64", "projectName-linux-amd64-go1.8-tag")),
+			"",
+			0,
+		).WithEnvironment([]string{
+			"GOOS=linux",
+			"GOARCH=amd64",
+			fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH")),
+			"SOURCE_DATE_EPOCH=1500000000",
+		}),
+	)
+	output, errOutput, err := runTagRelease(t, mainPath, expectedRunner, "reproducible")
+	assert.Nil(t, err)
+	assert.Contains(t, output, "Created tag tag at abc123\nPushed tag tag to origin\n")
+	assert.Equal(t, "", errOutput)
+}
+
+func TestReleasePushesTag(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	expectedRunner := getTagRunner(
+		t,
+		mainPath,
+		true,
+		runner.NewExpectedCommand(mainPath, "git status --porcelain -- :!dist", "", 0),
+		runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "abc123\n", 0),
+		runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/tag^{commit}"), "abc123\n", 0),
+		runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git ls-remote --tags origin refs/tags/tag refs/tags/tag^{}"), "", 0),
+		runner.NewExpectedCommand(mainPath, "git push origin refs/tags/tag", "", 0),
+	)
+	output, _, err := runTagRelease(t, mainPath, expectedRunner)
+	assert.Nil(t, err)
+	assert.Contains(t, output, "Pushed tag tag to origin\n")
+	assert.NotContains(t, output, "Created tag")
+}
+
+func TestReleaseAnnotatedTag(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	expectedRunner := getTagRunner(
+		t,
+		mainPath,
+		true,
+		runner.NewExpectedCommand(mainPath, "git status --porcelain -- :!dist", "", 0),
+		runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "abc123\n", 0),
+		runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/tag^{commit}"), "abc123\n", 0),
+		runner.NewExpectedCommand(
+			mainPath,
+			regexp.QuoteMeta("git ls-remote --tags origin refs/tags/tag refs/tags/tag^{}"),
+			"def456\trefs/tags/tag\nabc123\trefs/tags/tag^{}\n",
+			0,
+		),
+	)
+	_, _, err := runTagRelease(t, mainPath, expectedRunner)
+	assert.Nil(t, err)
+}
+
+func TestReleaseAllowDirty(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	expectedRunner := getTagRunner(
+		t,
+		mainPath,
+		true,
+		append(
+			[]*runner.ExpectedCommand{runner.NewExpectedCommand(mainPath, "git status --porcelain -- :!dist", " M main.go\n", 0)},
+			getTagCommands(t, mainPath, "tag")[1:]...,
+		)...,
+	)
+	_, errOutput, err := runTagRelease(t, mainPath, expectedRunner, "allowDirty")
+	assert.Nil(t, err)
+	assert.Equal(t, "Releasing tag with uncommitted changes\n", errOutput)
+}
+
+func TestReleaseTagDryRun(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	expectedRunner := getTagRunner(
+		t,
+		mainPath,
+		false,
+		runner.NewExpectedCommand(mainPath, "git status --porcelain -- :!dist", "", 0),
+		runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "abc123\n", 0),
+		runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/tag^{commit}"), "", 1),
+		runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git ls-remote --tags origin refs/tags/tag refs/tags/tag^{}"), "", 0),
+	)
+	output, _, err := runTagRelease(t, mainPath, expectedRunner, "dryRun")
+	assert.Nil(t, err)
+	assert.NotContains(t, output, "Created tag")
+}
+
+func TestReleaseDirtyDist(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	testCases := map[string]string{
+		fmt.Sprintf("%s/out/dist", mainPath):      "git status --porcelain -- :!out/dist",
+		fmt.Sprintf("%s/buildDist", os.TempDir()): "git status --porcelain",
+	}
+	for dist, status := range testCases {
+		ts := getReleaseTestServer(t, "", "")
+		expectedRunner := getTagRunner(
+			t,
+			mainPath,
+			false,
+			runner.NewExpectedCommand(mainPath, status, "", 0),
+			runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "abc123\n", 0),
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/tag^{commit}"), "abc123\n", 0),
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git ls-remote --tags origin refs/tags/tag refs/tags/tag^{}"), "abc123\trefs/tags/tag\n", 0),
+		)
+		set := flag.NewFlagSet("test", 0)
+		set.String("token", "fakeToken", "doc")
+		set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+		set.String("mainPath", mainPath, "doc")
+		set.String("dist", dist, "doc")
+		set.Bool("dryRun", true, "doc")
+		assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+		createFiles(t, mainPath, "tag")
+		writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+		app, _, _ := appWithTestWriters()
+		assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+		assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+		assert.Equal(t, []error(nil), expectedRunner.Errors)
+		ts.Close()
+	}
+}
+
+func TestReleaseTagErrors(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	status := runner.NewExpectedCommand(mainPath, "git status --porcelain -- :!dist", "", 0)
+	head := runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "abc123\n", 0)
+	localTag := regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/tag^{commit}")
+	remoteTag := regexp.QuoteMeta("git ls-remote --tags origin refs/tags/tag refs/tags/tag^{}")
+	testCases := []struct {
+		commands []*runner.ExpectedCommand
+		err      string
+	}{
+		{
+			[]*runner.ExpectedCommand{runner.NewExpectedCommand(mainPath, "git status --porcelain -- :!dist", " M main.go\n?? notes.txt\n", 0)},
+			"The working tree has uncommitted changes, commit them or pass --allowDirty:\n M main.go\n?? notes.txt",
+		},
+		{
+			[]*runner.ExpectedCommand{runner.NewExpectedCommand(mainPath, "git status --porcelain -- :!dist", "fatal: not a git repository", 128)},
+			"Unable to check the working tree: exit status 128",
+		},
+		{
+			[]*runner.ExpectedCommand{
+				status,
+				head,
+				runner.NewExpectedCommand(mainPath, localTag, "def456\n", 0),
+				runner.NewExpectedCommand(mainPath, remoteTag, "def456\trefs/tags/tag\n", 0),
+			},
+			"tag is def456 but HEAD is abc123, check out the tag to release it",
+		},
+		{
+			[]*runner.ExpectedCommand{
+				status,
+				head,
+				runner.NewExpectedCommand(mainPath, localTag, "abc123\n", 0),
+				runner.NewExpectedCommand(mainPath, remoteTag, "def456\trefs/tags/tag\n", 0),
+			},
+			"tag is def456 on origin but HEAD is abc123",
+		},
+		{
+			[]*runner.ExpectedCommand{
+				status,
+				head,
+				runner.NewExpectedCommand(mainPath, localTag, "abc123\n", 0),
+				runner.NewExpectedCommand(mainPath, remoteTag, "fatal: 'origin' does not appear to be a git repository", 128),
+			},
+			"Unable to look up tag on origin: exit status 128",
+		},
+		{
+			[]*runner.ExpectedCommand{
+				status,
+				head,
+				runner.NewExpectedCommand(mainPath, localTag, "", 1),
+				runner.NewExpectedCommand(mainPath, remoteTag, "", 0),
+				runner.NewExpectedCommand(mainPath, "git tag tag abc123", "fatal: cannot lock ref", 128),
+			},
+			"Unable to create tag tag: exit status 128 fatal: cannot lock ref",
+		},
+		{
+			[]*runner.ExpectedCommand{
+				status,
+				head,
+				runner.NewExpectedCommand(mainPath, localTag, "abc123\n", 0),
+				runner.NewExpectedCommand(mainPath, remoteTag, "", 0),
+				runner.NewExpectedCommand(mainPath, "git push origin refs/tags/tag", "error: failed to push some refs", 1),
+			},
+			"Unable to push tag tag to origin: exit status 1 error: failed to push some refs",
+		},
+	}
+	for _, testCase := range testCases {
+		expectedRunner := getTagRunner(t, mainPath, false, testCase.commands...)
+		_, _, err := runTagRelease(t, mainPath, expectedRunner)
+		assert.EqualError(t, err, testCase.err)
+	}
+}
+
+// getTagRunner expects the tag commands after the build is planned, followed by the build if it is released
+func getTagRunner(t *testing.T, mainPath string, build bool, tagCommands ...*runner.ExpectedCommand) *runner.Test {
+	t.Helper()
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	expectedCommands := append(
+		[]*runner.ExpectedCommand{
+			getDistListCommand(t, mainPath),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+		},
+		tagCommands...,
+	)
+	if build {
+		fileName := distFile(mainPath, "linux", "amd64", "projectName-linux-amd64-go1.8-tag")
+		expectedCommands = append(
+			expectedCommands,
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s build -o %s", goExecutable, fileName), "", 0).WithEnvironment([]string{
+				"GOOS=linux",
+				"GOARCH=amd64",
+				fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH")),
+			}),
+		)
+	}
+
+	return &runner.Test{ExpectedCommands: expectedCommands}
+}
+
+// runTagRelease releases tag for linux/amd64 with the bool flags that are given set
+func runTagRelease(t *testing.T, mainPath string, expectedRunner *runner.Test, boolFlags ...string) (string, string, error) {
+	t.Helper()
+	ts := getReleaseTestServer(t, "", "")
+	defer ts.Close()
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	for _, name := range boolFlags {
+		set.Bool(name, true, "doc")
+	}
+
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+	app, writer, errWriter := appWithTestWriters()
+	err := command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+	return writer.String(), errWriter.String(), err
+}
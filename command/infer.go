@@ -0,0 +1,188 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/guywithnose/runner"
+	"github.com/urfave/cli"
+)
+
+// majorVersionSuffix is the last element of a module path for a major version after v1, e.g. example.com/foo/v2
+var majorVersionSuffix = regexp.MustCompile(`^v[0-9]+$`)
+
+// releaseArguments reads owner, repo, tagName and projectName from the arguments.  With only the tag or no arguments at
+// all the rest comes from the config, or is inferred: the owner and repo from the origin remote (which also sets the
+// API URL for an Enterprise host), the tag from HEAD and the project name from the module path of the main package.
+// With --bump the tag is the next version instead, and the arguments are {owner} {repo} {projectName} or none.
+// Inferred values are written to errWriter so they can be checked before anything happens.
+func releaseArguments(c *cli.Context, cmdWrapper runner.Builder, cfg *config, mainPath, usage string) (string, string, string, string, error) {
+	bump, err := bumpOption(c)
+	if err != nil {
+		return "", "", "", "", err
+	}
+
+	if c.NArg() == 4 && bump == "" {
+		return c.Args().Get(0), c.Args().Get(1), c.Args().Get(2), c.Args().Get(3), nil
+	}
+
+	owner, repo, tagName, projectName := cfg.Release.Owner, cfg.Release.Repo, "", cfg.ProjectName
+	switch {
+	case c.NArg() == 3 && bump != "":
+		owner, repo, projectName = c.Args().Get(0), c.Args().Get(1), c.Args().Get(2)
+	case c.NArg() == 1 && bump == "":
+		tagName = c.Args().Get(0)
+	case c.NArg() != 0:
+		return "", "", "", "", cli.NewExitError(usage, 1)
+	}
+
+	if owner == "" || repo == "" {
+		remote, err := inferRepository(cmdWrapper, mainPath)
+		if err != nil {
+			return "", "", "", "", cli.NewExitError(fmt.Sprintf("Unable to infer the owner and repo, pass them as arguments: %v", err), 1)
+		}
+
+		owner, repo = remote.owner, remote.repo
+		fmt.Fprintf(c.App.ErrWriter, "Inferred the repository %s/%s from origin\n", owner, repo)
+		if remote.apiURL != "" && c.String("apiUrl") == "" && cfg.Release.APIURL == "" {
+			cfg.Release.APIURL = remote.apiURL
+			fmt.Fprintf(c.App.ErrWriter, "Inferred the API URL %s from origin\n", remote.apiURL)
+		}
+	}
+
+	switch {
+	case bump != "":
+		next, previous, kind, err := nextVersion(cmdWrapper, mainPath, bump)
+		if err != nil {
+			return "", "", "", "", cli.NewExitError(fmt.Sprintf("Unable to work out the next version: %v", err), 1)
+		}
+
+		tagName = next
+		fmt.Fprintf(c.App.ErrWriter, "Inferred the tag %s from a %s bump of %s\n", tagName, kind, stringOption(previous, "no tags"))
+	case tagName == "":
+		output, err := cmdWrapper.New(mainPath, "git", "describe", "--tags", "--exact-match").Output()
+		if err != nil {
+			return "", "", "", "", cli.NewExitError(fmt.Sprintf("Unable to infer the tag, HEAD is not tagged: %v", err), 1)
+		}
+
+		tagName = strings.TrimSpace(string(output))
+		fmt.Fprintf(c.App.ErrWriter, "Inferred the tag %s from HEAD\n", tagName)
+	}
+
+	if projectName == "" {
+		packagePath, err := mainPackagePath(mainPath)
+		if err != nil {
+			return "", "", "", "", cli.NewExitError(fmt.Sprintf("Unable to infer the project name, set projectName in the config: %v", err), 1)
+		}
+
+		projectName = projectNameFor(packagePath)
+		fmt.Fprintf(c.App.ErrWriter, "Inferred the project name %s from %s\n", projectName, packagePath)
+	}
+
+	return owner, repo, tagName, projectName, nil
+}
+
+// remoteRepository is the github repository that a remote points to
+type remoteRepository struct {
+	owner  string
+	repo   string
+	apiURL string
+}
+
+func inferRepository(cmdWrapper runner.Builder, mainPath string) (remoteRepository, error) {
+	output, err := cmdWrapper.New(mainPath, "git", "remote", "get-url", "origin").Output()
+	if err != nil {
+		return remoteRepository{}, fmt.Errorf("Unable to read the origin remote: %v", err)
+	}
+
+	return parseRemoteURL(strings.TrimSpace(string(output)))
+}
+
+// parseRemoteURL reads the owner and repo from an HTTPS or SSH remote, such as https://github.com/owner/repo.git,
+// ssh://git@github.com/owner/repo.git or git@github.com:owner/repo.git.  A host other than github.com is taken to be
+// github Enterprise, which has its API under /api/v3.
+func parseRemoteURL(remote string) (remoteRepository, error) {
+	host, repositoryPath := "", ""
+	if strings.Contains(remote, "://") {
+		parsed, err := url.Parse(remote)
+		if err != nil {
+			return remoteRepository{}, fmt.Errorf("Invalid remote %s: %v", remote, err)
+		}
+
+		host, repositoryPath = parsed.Hostname(), parsed.Path
+	} else if index := strings.Index(remote, ":"); index != -1 {
+		host, repositoryPath = remote[:index], remote[index+1:]
+		host = host[strings.LastIndex(host, "@")+1:]
+	}
+
+	parts := strings.Split(strings.TrimSuffix(strings.Trim(repositoryPath, "/"), ".git"), "/")
+	if host == "" || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return remoteRepository{}, fmt.Errorf("%s is not a github repository", remote)
+	}
+
+	remoteRepo := remoteRepository{owner: parts[0], repo: parts[1]}
+	if host != "github.com" {
+		remoteRepo.apiURL = fmt.Sprintf("https://%s/api/v3/", host)
+	}
+
+	return remoteRepo, nil
+}
+
+// mainPackagePath is the import path of the package in mainPath, from the module path in the go.mod above it
+func mainPackagePath(mainPath string) (string, error) {
+	directory, err := filepath.Abs(mainPath)
+	if err != nil {
+		return "", err
+	}
+
+	for moduleDirectory := directory; ; moduleDirectory = filepath.Dir(moduleDirectory) {
+		file, err := os.Open(filepath.Join(moduleDirectory, "go.mod"))
+		if err == nil {
+			modulePath := readModulePath(file)
+			_ = file.Close()
+			if modulePath == "" {
+				return "", fmt.Errorf("%s does not have a module path", filepath.Join(moduleDirectory, "go.mod"))
+			}
+
+			relative, err := filepath.Rel(moduleDirectory, directory)
+			if err != nil {
+				return "", err
+			}
+
+			return path.Join(modulePath, filepath.ToSlash(relative)), nil
+		}
+
+		if filepath.Dir(moduleDirectory) == moduleDirectory {
+			return "", fmt.Errorf("There is no go.mod in %s or above it", directory)
+		}
+	}
+}
+
+func readModulePath(reader io.Reader) string {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\"`")
+		}
+	}
+
+	return ""
+}
+
+// projectNameFor is the last element of a package path, leaving out a major version suffix
+func projectNameFor(packagePath string) string {
+	name := path.Base(packagePath)
+	if majorVersionSuffix.MatchString(name) && path.Dir(packagePath) != "." {
+		return path.Base(path.Dir(packagePath))
+	}
+
+	return name
+}
@@ -0,0 +1,159 @@
+package command_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestBuildInfersArguments(t *testing.T) {
+	testCases := []struct {
+		remote    string
+		module    string
+		directory string
+		inferred  string
+	}{
+		{
+			"git@github.com:owner/repo.git",
+			"module github.com/owner/projectName\n",
+			"",
+			"Inferred the repository owner/repo from origin\n" +
+				"Inferred the tag tag from HEAD\n" +
+				"Inferred the project name projectName from github.com/owner/projectName\n",
+		},
+		{
+			"https://github.com/owner/repo",
+			"module github.com/owner/projectName/v2\n\ngo 1.21\n",
+			"",
+			"Inferred the repository owner/repo from origin\n" +
+				"Inferred the tag tag from HEAD\n" +
+				"Inferred the project name projectName from github.com/owner/projectName/v2\n",
+		},
+		{
+			"ssh://git@ghe.example.com:2222/owner/repo.git",
+			"module \"ghe.example.com/owner/projectName\"\n",
+			"",
+			"Inferred the repository owner/repo from origin\n" +
+				"Inferred the API URL https://ghe.example.com/api/v3/ from origin\n" +
+				"Inferred the tag tag from HEAD\n" +
+				"Inferred the project name projectName from ghe.example.com/owner/projectName\n",
+		},
+		{
+			"https://user@ghe.example.com/owner/repo.git",
+			"module ghe.example.com/owner\n",
+			"cmd/projectName",
+			"Inferred the repository owner/repo from origin\n" +
+				"Inferred the API URL https://ghe.example.com/api/v3/ from origin\n" +
+				"Inferred the tag tag from HEAD\n" +
+				"Inferred the project name projectName from ghe.example.com/owner/cmd/projectName\n",
+		},
+	}
+	for _, testCase := range testCases {
+		root := fmt.Sprintf("%s/build", os.TempDir())
+		mainPath := path.Join(root, testCase.directory)
+		createFiles(t, mainPath, "tag")
+		writeTestFile(t, fmt.Sprintf("%s/go.mod", root), testCase.module, 0644)
+		writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+		set := flag.NewFlagSet("test", 0)
+		set.String("mainPath", mainPath, "doc")
+		assert.Nil(t, set.Parse([]string{}))
+		expectedCommands := append(
+			getExpectedCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+				return operatingSystem == "linux" && architecture == "amd64"
+			}),
+			runner.NewExpectedCommand(mainPath, "git remote get-url origin", fmt.Sprintf("%s\n", testCase.remote), 0),
+			runner.NewExpectedCommand(mainPath, "git describe --tags --exact-match", "tag\n", 0),
+		)
+		expectedCommands = append(expectedCommands, getBuildGitCommands(t, mainPath, "abc123")...)
+		expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+		app, _, errWriter := appWithTestWriters()
+		assert.Nil(t, command.CmdBuild(expectedRunner)(cli.NewContext(app, set, nil)), testCase.remote)
+		assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands, testCase.remote)
+		assert.Equal(t, testCase.inferred, errWriter.String(), testCase.remote)
+		manifest := readTestManifest(t, fmt.Sprintf("%s/dist", mainPath))
+		assert.Equal(t, "owner", manifest.Owner)
+		assert.Equal(t, "repo", manifest.Repo)
+		cleanUp(t, root)
+	}
+}
+
+func TestBuildInfersTag(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "projectName: projectName\nrelease:\n  owner: owner\n  repo: repo\nbuilds:\n  targets: [linux/amd64]\n")
+	createFiles(t, mainPath, "tag")
+	set := flag.NewFlagSet("test", 0)
+	set.String("mainPath", mainPath, "doc")
+	assert.Nil(t, set.Parse([]string{}))
+	gitCommands := getBuildGitCommands(t, mainPath, "abc123")
+	buildCommands := getExpectedCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return operatingSystem == "linux" && architecture == "amd64"
+	})
+	expectedCommands := []*runner.ExpectedCommand{
+		runner.NewExpectedCommand(mainPath, "git describe --tags --exact-match", "tag\n", 0),
+		gitCommands[0],
+		buildCommands[0],
+		buildCommands[1],
+		gitCommands[1],
+		buildCommands[2],
+	}
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdBuild(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, "Inferred the tag tag from HEAD\n", errWriter.String())
+}
+
+func TestBuildInferErrors(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+	testCases := []struct {
+		commands []*runner.ExpectedCommand
+		err      string
+	}{
+		{
+			[]*runner.ExpectedCommand{runner.NewExpectedCommand(mainPath, "git remote get-url origin", "error: No such remote 'origin'", 2)},
+			"Unable to infer the owner and repo, pass them as arguments: Unable to read the origin remote: exit status 2",
+		},
+		{
+			[]*runner.ExpectedCommand{runner.NewExpectedCommand(mainPath, "git remote get-url origin", "https://github.com/owner\n", 0)},
+			"Unable to infer the owner and repo, pass them as arguments: https://github.com/owner is not a github repository",
+		},
+		{
+			[]*runner.ExpectedCommand{runner.NewExpectedCommand(mainPath, "git remote get-url origin", "/srv/git/repo.git\n", 0)},
+			"Unable to infer the owner and repo, pass them as arguments: /srv/git/repo.git is not a github repository",
+		},
+		{
+			[]*runner.ExpectedCommand{
+				runner.NewExpectedCommand(mainPath, "git remote get-url origin", "git@github.com:owner/repo.git\n", 0),
+				runner.NewExpectedCommand(mainPath, "git describe --tags --exact-match", "fatal: no tag exactly matches 'abc123'", 128),
+			},
+			"Unable to infer the tag, HEAD is not tagged: exit status 128",
+		},
+		{
+			[]*runner.ExpectedCommand{
+				runner.NewExpectedCommand(mainPath, "git remote get-url origin", "git@github.com:owner/repo.git\n", 0),
+				runner.NewExpectedCommand(mainPath, "git describe --tags --exact-match", "tag\n", 0),
+			},
+			fmt.Sprintf("Unable to infer the project name, set projectName in the config: There is no go.mod in %s or above it", mainPath),
+		},
+	}
+	for _, testCase := range testCases {
+		set := flag.NewFlagSet("test", 0)
+		set.String("mainPath", mainPath, "doc")
+		assert.Nil(t, set.Parse([]string{}))
+		expectedRunner := &runner.Test{ExpectedCommands: testCase.commands}
+		app, _, _ := appWithTestWriters()
+		assert.EqualError(t, command.CmdBuild(expectedRunner)(cli.NewContext(app, set, nil)), testCase.err)
+		assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+		assert.Equal(t, []error(nil), expectedRunner.Errors)
+	}
+}
@@ -0,0 +1,75 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/urfave/cli"
+)
+
+// KeygenFlags are the valid keygen parameters
+var KeygenFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "secretKey, s",
+		Value: "minisign.key",
+		Usage: "Where to write the secret key",
+	},
+	cli.StringFlag{
+		Name:  "publicKey, p",
+		Value: "minisign.pub",
+		Usage: "Where to write the public key",
+	},
+	cli.BoolFlag{
+		Name:  "unencrypted",
+		Usage: "Write the secret key without a password.  Otherwise the password is read from $GO_RELEASE_MINISIGN_PASSWORD",
+	},
+	cli.BoolFlag{
+		Name:  "force, f",
+		Usage: "Overwrite existing key files",
+	},
+}
+
+// CmdKeygen creates a minisign key pair for signing releases
+func CmdKeygen(c *cli.Context) error {
+	if c.NArg() != 0 {
+		return cli.NewExitError("Usage: \"goRelease keygen --secretKey {secretKey} --publicKey {publicKey}\"", 1)
+	}
+
+	secretKeyPath := c.String("secretKey")
+	publicKeyPath := c.String("publicKey")
+	if secretKeyPath == "" || publicKeyPath == "" {
+		return cli.NewExitError("You must specify where to write the secret and public keys", 1)
+	}
+
+	password := os.Getenv(defaultMinisignPasswordEnv)
+	if password == "" && !c.Bool("unencrypted") {
+		return cli.NewExitError(fmt.Sprintf("You must set $%s or use --unencrypted", defaultMinisignPasswordEnv), 1)
+	}
+
+	if !c.Bool("force") {
+		for _, fileName := range []string{secretKeyPath, publicKeyPath} {
+			if _, err := os.Stat(fileName); err == nil {
+				return cli.NewExitError(fmt.Sprintf("%s already exists, use --force to overwrite it", fileName), 1)
+			}
+		}
+	}
+
+	secretKey, publicKey, err := generateMinisignKey(password)
+	if err != nil {
+		return fmt.Errorf("Unable to generate key: %v", err)
+	}
+
+	err = ioutil.WriteFile(secretKeyPath, secretKey, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to write secret key: %v", err)
+	}
+
+	err = ioutil.WriteFile(publicKeyPath, publicKey, 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to write public key: %v", err)
+	}
+
+	fmt.Fprintf(c.App.Writer, "Wrote the secret key to %s and the public key to %s\n%s", secretKeyPath, publicKeyPath, publicKey)
+	return nil
+}
@@ -0,0 +1,62 @@
+package command_test
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/guywithnose/goRelease/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestKeygen(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	assert.Nil(t, os.MkdirAll(mainPath, 0777))
+	defer cleanUp(t, mainPath)
+	set := flag.NewFlagSet("test", 0)
+	set.String("secretKey", fmt.Sprintf("%s/minisign.key", mainPath), "doc")
+	set.String("publicKey", fmt.Sprintf("%s/minisign.pub", mainPath), "doc")
+	set.Bool("unencrypted", true, "doc")
+	app, writer, _ := appWithTestWriters()
+	assert.Nil(t, command.CmdKeygen(cli.NewContext(app, set, nil)))
+
+	secretKey, err := ioutil.ReadFile(fmt.Sprintf("%s/minisign.key", mainPath))
+	assert.Nil(t, err)
+	publicKey, err := ioutil.ReadFile(fmt.Sprintf("%s/minisign.pub", mainPath))
+	assert.Nil(t, err)
+	info, err := os.Stat(fmt.Sprintf("%s/minisign.key", mainPath))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode())
+	assert.True(t, strings.HasPrefix(string(secretKey), "untrusted comment: minisign secret key\nRWQAAEIy"))
+	assert.True(t, strings.HasPrefix(string(publicKey), "untrusted comment: minisign public key "))
+	assert.Equal(
+		t,
+		fmt.Sprintf("Wrote the secret key to %[1]s/minisign.key and the public key to %[1]s/minisign.pub\n%s", mainPath, publicKey),
+		writer.String(),
+	)
+
+	app, _, _ = appWithTestWriters()
+	err = command.CmdKeygen(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, fmt.Sprintf("%s/minisign.key already exists, use --force to overwrite it", mainPath))
+}
+
+func TestKeygenRequiresPassword(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	set.String("secretKey", "minisign.key", "doc")
+	set.String("publicKey", "minisign.pub", "doc")
+	app, _, _ := appWithTestWriters()
+	err := command.CmdKeygen(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "You must set $GO_RELEASE_MINISIGN_PASSWORD or use --unencrypted")
+}
+
+func TestKeygenBadArgs(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	assert.Nil(t, set.Parse([]string{"extra"}))
+	app, _, _ := appWithTestWriters()
+	err := command.CmdKeygen(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Usage: \"goRelease keygen --secretKey {secretKey} --publicKey {publicKey}\"")
+}
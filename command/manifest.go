@@ -0,0 +1,307 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// manifestFileName is the manifest that build, package and release write to the dist directory, and that package and
+// publish read
+const manifestFileName = "artifacts.json"
+
+// The types of artifact that are listed in the manifest
+const (
+	artifactBinary     = "binary"
+	artifactArchive    = "archive"
+	artifactSBOM       = "sbom"
+	artifactProvenance = "provenance"
+	artifactChecksum   = "checksum"
+	artifactChecksums  = "checksums"
+	artifactSignature  = "signature"
+)
+
+// artifact is a file that was built or packaged for a release.  Files that belong to a target have its os and arch.
+// The size and digest are filled in when the artifact is recorded in the manifest.
+type artifact struct {
+	Path   string `json:"path"`
+	OS     string `json:"os,omitempty"`
+	Arch   string `json:"arch,omitempty"`
+	Type   string `json:"type"`
+	Size   int64  `json:"size"`
+	Digest string `json:"digest"`
+}
+
+// related is another file for the same target, such as a signature or checksum of the artifact
+func (asset artifact) related(fileName, artifactType string) artifact {
+	return artifact{Path: fileName, OS: asset.OS, Arch: asset.Arch, Type: artifactType}
+}
+
+// artifactManifest records what has been built into a dist directory so that it can be packaged and published later,
+// possibly on another machine.  Paths are stored relative to the dist directory so it can be moved.
+type artifactManifest struct {
+	Owner     string
+	Repo      string
+	Data      templateData
+	Targets   []buildTarget
+	Artifacts []artifact
+	dist      string
+	mutex     sync.Mutex
+}
+
+// storedManifest is the manifest as it is written to artifacts.json
+type storedManifest struct {
+	Owner     string           `json:"owner"`
+	Repo      string           `json:"repo"`
+	Data      templateData     `json:"data"`
+	Targets   []manifestTarget `json:"targets"`
+	Artifacts []artifact       `json:"artifacts"`
+}
+
+// manifestTarget is what package needs to know about a target that was built: where its binary is, how to archive it
+// and, for the provenance, how it was built.  GOPATH is left out of the build environment since it is a path on the
+// machine that built it.
+type manifestTarget struct {
+	OS            string         `json:"os"`
+	Arch          string         `json:"arch"`
+	Binary        string         `json:"binary"`
+	BinaryName    string         `json:"binaryName"`
+	Archive       string         `json:"archive"`
+	ArchiveFormat string         `json:"archiveFormat"`
+	Directory     string         `json:"directory"`
+	ExtraFiles    []manifestFile `json:"extraFiles"`
+	Build         manifestBuild  `json:"build"`
+}
+
+type manifestFile struct {
+	Source string      `json:"source"`
+	Name   string      `json:"name"`
+	Mode   os.FileMode `json:"mode"`
+}
+
+type manifestBuild struct {
+	Command     []string  `json:"command"`
+	Environment []string  `json:"environment"`
+	Started     time.Time `json:"started"`
+	Finished    time.Time `json:"finished"`
+}
+
+func newManifest(owner, repo string, data templateData, dist string) *artifactManifest {
+	return &artifactManifest{Owner: owner, Repo: repo, Data: data, Targets: []buildTarget{}, Artifacts: []artifact{}, dist: dist}
+}
+
+// upload records a packaged artifact in the manifest
+func (manifest *artifactManifest) upload(asset artifact) error {
+	info, err := os.Stat(asset.Path)
+	if err != nil {
+		return err
+	}
+
+	sum, err := fileSHA256(asset.Path)
+	if err != nil {
+		return err
+	}
+
+	asset.Size = info.Size()
+	asset.Digest = fmt.Sprintf("sha256:%s", sum)
+	manifest.mutex.Lock()
+	defer manifest.mutex.Unlock()
+	manifest.Artifacts = append(manifest.Artifacts, asset)
+	return nil
+}
+
+// verifyArtifacts makes sure that the artifacts are still what was recorded in the manifest, so nothing that changed in
+// the dist directory since it was packaged is published
+func verifyArtifacts(assets []artifact) error {
+	for _, asset := range assets {
+		info, err := os.Stat(asset.Path)
+		if err != nil {
+			return fmt.Errorf("Unable to check %s: %v", asset.Path, err)
+		}
+
+		sum, err := fileSHA256(asset.Path)
+		if err != nil {
+			return fmt.Errorf("Unable to check %s: %v", asset.Path, err)
+		}
+
+		if info.Size() != asset.Size || fmt.Sprintf("sha256:%s", sum) != asset.Digest {
+			return fmt.Errorf("%s has changed since it was packaged, run goRelease package again", asset.Path)
+		}
+	}
+
+	return nil
+}
+
+// addBinary records a target that was built along with its binary
+func (manifest *artifactManifest) addBinary(target buildTarget) error {
+	err := manifest.upload(artifact{Path: target.FileName, OS: target.OperatingSystem, Arch: target.Architecture, Type: artifactBinary})
+	if err != nil {
+		return err
+	}
+
+	manifest.mutex.Lock()
+	defer manifest.mutex.Unlock()
+	manifest.Targets = append(manifest.Targets, target)
+	return nil
+}
+
+// sort orders the targets and artifacts, which are recorded as each target finishes, so the manifest is stable
+func (manifest *artifactManifest) sort() {
+	sort.Slice(manifest.Targets, func(i, j int) bool {
+		return manifest.Targets[i].String() < manifest.Targets[j].String()
+	})
+	sort.Slice(manifest.Artifacts, func(i, j int) bool {
+		return manifest.Artifacts[i].Path < manifest.Artifacts[j].Path
+	})
+}
+
+// recordedDestination records every asset in a manifest once it has been uploaded to the destination
+type recordedDestination struct {
+	destination assetDestination
+	manifest    *artifactManifest
+}
+
+func (recorded *recordedDestination) upload(asset artifact) error {
+	err := recorded.destination.upload(asset)
+	if err != nil {
+		return err
+	}
+
+	return recorded.manifest.upload(asset)
+}
+
+// write stores the manifest in the dist directory.  Artifacts stay listed after their files are cleaned up, with the
+// size and digest they had when they were recorded.
+func (manifest *artifactManifest) write() error {
+	manifest.sort()
+	stored := storedManifest{Owner: manifest.Owner, Repo: manifest.Repo, Data: manifest.Data}
+	stored.Targets = make([]manifestTarget, 0, len(manifest.Targets))
+	for _, target := range manifest.Targets {
+		stored.Targets = append(stored.Targets, newManifestTarget(target, manifest.relative))
+	}
+
+	stored.Artifacts = make([]artifact, 0, len(manifest.Artifacts))
+	for _, asset := range manifest.Artifacts {
+		asset.Path = manifest.relative(asset.Path)
+		stored.Artifacts = append(stored.Artifacts, asset)
+	}
+
+	contents, err := json.MarshalIndent(&stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(manifest.dist, manifestFileName), append(contents, '\n'), 0644)
+}
+
+func (manifest *artifactManifest) relative(fileName string) string {
+	relative, err := filepath.Rel(manifest.dist, fileName)
+	if err != nil {
+		return fileName
+	}
+
+	return filepath.ToSlash(relative)
+}
+
+func readManifest(dist string) (*artifactManifest, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(dist, manifestFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("There is no %s in %s, run goRelease build first", manifestFileName, dist)
+		}
+
+		return nil, fmt.Errorf("Unable to read manifest: %v", err)
+	}
+
+	stored := storedManifest{}
+	err = json.Unmarshal(contents, &stored)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid manifest %s: %v", filepath.Join(dist, manifestFileName), err)
+	}
+
+	manifest := &artifactManifest{Owner: stored.Owner, Repo: stored.Repo, Data: stored.Data, Artifacts: stored.Artifacts, dist: dist}
+	if manifest.Artifacts == nil {
+		manifest.Artifacts = []artifact{}
+	}
+
+	manifest.Targets = make([]buildTarget, 0, len(stored.Targets))
+	for _, target := range stored.Targets {
+		manifest.Targets = append(manifest.Targets, target.buildTarget(stored.Data, manifest.absolute))
+	}
+
+	for index := range manifest.Artifacts {
+		manifest.Artifacts[index].Path = manifest.absolute(manifest.Artifacts[index].Path)
+	}
+
+	return manifest, nil
+}
+
+func (manifest *artifactManifest) absolute(fileName string) string {
+	return filepath.Join(manifest.dist, filepath.FromSlash(fileName))
+}
+
+func newManifestTarget(target buildTarget, relative func(string) string) manifestTarget {
+	stored := manifestTarget{
+		OS:            target.OperatingSystem,
+		Arch:          target.Architecture,
+		Binary:        relative(target.FileName),
+		BinaryName:    target.BinaryName,
+		Archive:       relative(target.ArchivePath),
+		ArchiveFormat: target.ArchiveFormat,
+		Directory:     target.Directory,
+		ExtraFiles:    make([]manifestFile, 0, len(target.ExtraFiles)),
+		Build:         manifestBuild{Command: append([]string{}, target.Command...), Environment: []string{}, Started: target.BuildStarted, Finished: target.BuildFinished},
+	}
+	for _, file := range target.ExtraFiles {
+		stored.ExtraFiles = append(stored.ExtraFiles, manifestFile{Source: relative(file.Source), Name: file.Name, Mode: file.Mode})
+	}
+
+	for index := 1; index < len(stored.Build.Command); index++ {
+		if stored.Build.Command[index-1] == "-o" {
+			stored.Build.Command[index] = relative(stored.Build.Command[index])
+		}
+	}
+
+	for _, variable := range target.Environment {
+		if !strings.HasPrefix(variable, "GOPATH=") {
+			stored.Build.Environment = append(stored.Build.Environment, variable)
+		}
+	}
+
+	return stored
+}
+
+// buildTarget is the target that was built, with the data it was built with
+func (stored manifestTarget) buildTarget(data templateData, absolute func(string) string) buildTarget {
+	data.OS, data.Arch = stored.OS, stored.Arch
+	target := buildTarget{
+		osBuildInfo:   osBuildInfo{OperatingSystem: stored.OS, ArchiveFormat: stored.ArchiveFormat},
+		Architecture:  stored.Arch,
+		FileName:      absolute(stored.Binary),
+		Command:       append([]string{}, stored.Build.Command...),
+		Environment:   stored.Build.Environment,
+		ArchivePath:   absolute(stored.Archive),
+		Directory:     stored.Directory,
+		BinaryName:    stored.BinaryName,
+		Data:          data,
+		BuildStarted:  stored.Build.Started,
+		BuildFinished: stored.Build.Finished,
+	}
+	for _, file := range stored.ExtraFiles {
+		target.ExtraFiles = append(target.ExtraFiles, archiveFile{Source: absolute(file.Source), Name: file.Name, Mode: file.Mode})
+	}
+
+	for index := 1; index < len(target.Command); index++ {
+		if target.Command[index-1] == "-o" {
+			target.Command[index] = absolute(target.Command[index])
+		}
+	}
+
+	return target
+}
@@ -0,0 +1,134 @@
+package command_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestReleaseManifest(t *testing.T) {
+	uploads := make(map[string][]byte)
+	ts := getReleaseTestServerWithUploads(t, "", "", uploads)
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.Bool("keep", true, "doc")
+	set.Bool("checksumSidecars", true, "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\narchives:\n  files: [README.md]\n")
+	writeTestFile(t, fmt.Sprintf("%s/README.md", mainPath), "readme", 0644)
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return operatingSystem == "linux" && architecture == "amd64"
+	})
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, "", errWriter.String())
+
+	manifest := readTestManifest(t, fmt.Sprintf("%s/dist", mainPath))
+	assert.Equal(
+		t,
+		[]string{
+			"linux_amd64/projectName-linux-amd64-go1.8-tag linux/amd64 binary",
+			"linux_amd64/projectName-linux-amd64-go1.8-tag.tar.gz linux/amd64 archive",
+			"linux_amd64/projectName-linux-amd64-go1.8-tag.tar.gz.sha256 linux/amd64 checksum",
+			"projectName_tag_checksums.txt / checksums",
+		},
+		manifestArtifacts(manifest),
+	)
+
+	// Every file is kept, and the manifest describes what was uploaded
+	for _, asset := range manifest.Artifacts {
+		contents, err := ioutil.ReadFile(fmt.Sprintf("%s/dist/%s", mainPath, asset.Path))
+		assert.Nil(t, err)
+		sum := sha256.Sum256(contents)
+		assert.Equal(t, int64(len(contents)), asset.Size)
+		assert.Equal(t, fmt.Sprintf("sha256:%s", hex.EncodeToString(sum[:])), asset.Digest)
+	}
+
+	// Paths are relative to the dist directory, including the build's output, and GOPATH is left out
+	assert.NotContains(t, string(readTestFile(t, fmt.Sprintf("%s/dist/artifacts.json", mainPath))), mainPath)
+	assert.Equal(t, 1, len(manifest.Targets))
+	target := manifest.Targets[0]
+	assert.Equal(t, "linux", target.OS)
+	assert.Equal(t, "amd64", target.Arch)
+	assert.Equal(t, "linux_amd64/projectName-linux-amd64-go1.8-tag", target.Binary)
+	assert.Equal(t, "linux_amd64/projectName-linux-amd64-go1.8-tag.tar.gz", target.Archive)
+	assert.Equal(t, 1, len(target.ExtraFiles))
+	assert.Equal(t, "../README.md", target.ExtraFiles[0].Source)
+	assert.Equal(t, []string{"-o", "linux_amd64/projectName-linux-amd64-go1.8-tag"}, target.Build.Command[len(target.Build.Command)-2:])
+	assert.Equal(t, []string{"GOOS=linux", "GOARCH=amd64"}, target.Build.Environment)
+	assert.Equal(t, uploads["projectName_tag_checksums.txt"], readTestFile(t, fmt.Sprintf("%s/dist/projectName_tag_checksums.txt", mainPath)))
+}
+
+func TestReleaseCleansDist(t *testing.T) {
+	ts := getReleaseTestServer(t, "", "")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.String("dist", fmt.Sprintf("%s/dist", mainPath), "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return operatingSystem == "linux" && architecture == "amd64"
+	})
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "", errWriter.String())
+
+	// Only the folders of the targets that were not built are left beside the manifest
+	files, err := ioutil.ReadDir(fmt.Sprintf("%s/dist", mainPath))
+	assert.Nil(t, err)
+	names := []string{}
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+
+	assert.NotContains(t, names, "linux_amd64")
+	assert.Contains(t, names, "artifacts.json")
+
+	// The files that were uploaded have been removed, but the manifest still describes them
+	manifest := readTestManifest(t, fmt.Sprintf("%s/dist", mainPath))
+	assert.Equal(
+		t,
+		[]string{
+			"linux_amd64/projectName-linux-amd64-go1.8-tag linux/amd64 binary",
+			"linux_amd64/projectName-linux-amd64-go1.8-tag.tar.gz linux/amd64 archive",
+			"projectName_tag_checksums.txt / checksums",
+		},
+		manifestArtifacts(manifest),
+	)
+	assert.Equal(t, int64(3), manifest.Artifacts[0].Size)
+	assert.Equal(t, "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae", manifest.Artifacts[0].Digest)
+	assert.Equal(t, 1, len(manifest.Targets))
+}
+
+func readTestFile(t *testing.T, fileName string) []byte {
+	t.Helper()
+	contents, err := ioutil.ReadFile(fileName)
+	assert.Nil(t, err)
+	return contents
+}
@@ -0,0 +1,401 @@
+package command
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/scrypt"
+)
+
+// The minisign key and signature formats are described at https://jedisct1.github.io/minisign/.  Signatures are
+// always prehashed with BLAKE2b-512 so large assets do not need to be read into memory.
+
+const (
+	defaultMinisignKeyEnv      = "GO_RELEASE_MINISIGN_KEY"
+	defaultMinisignPasswordEnv = "GO_RELEASE_MINISIGN_PASSWORD"
+	minisignKeyIDSize          = 8
+	minisignChecksumSize       = 32
+	minisignSaltSize           = 32
+	minisignSecretKeySize      = 2 + 2 + 2 + minisignSaltSize + 8 + 8 + minisignKeyIDSize + ed25519.PrivateKeySize + minisignChecksumSize
+	minisignPublicKeySize      = 2 + minisignKeyIDSize + ed25519.PublicKeySize
+	minisignSignatureSize      = 2 + minisignKeyIDSize + ed25519.SignatureSize
+
+	// These are the libsodium "sensitive" scrypt limits that minisign uses for new keys
+	minisignOpsLimit = 33554432
+	minisignMemLimit = 1073741824
+)
+
+var (
+	minisignAlgorithm       = []byte("Ed")
+	minisignHashedAlgorithm = []byte("ED")
+	minisignKDFAlgorithm    = []byte("Sc")
+	minisignNoKDF           = []byte{0, 0}
+	minisignChecksumAlg     = []byte("B2")
+)
+
+var errMinisignWrongPassword = errors.New("wrong password for that key")
+
+type minisignConfig struct {
+	Key         string `yaml:"key"`
+	KeyEnv      string `yaml:"keyEnv"`
+	PasswordEnv string `yaml:"passwordEnv"`
+	PublicKey   string `yaml:"publicKey"`
+	Artifacts   string `yaml:"artifacts"`
+}
+
+type minisignPublicKey struct {
+	keyID []byte
+	key   ed25519.PublicKey
+}
+
+type minisignPrivateKey struct {
+	minisignPublicKey
+	key ed25519.PrivateKey
+}
+
+// minisignSigner writes minisign signatures (foo.tar.gz.minisig) with the tag and file name in the trusted comment
+type minisignSigner struct {
+	key       *minisignPrivateKey
+	publicKey *minisignPublicKey
+	tag       string
+	all       bool
+}
+
+// newMinisignSigner loads the secret key from the key file, or from the key environment variable when there is no file
+func newMinisignSigner(cfg minisignConfig, tag string) (*minisignSigner, error) {
+	artifacts := stringOption(cfg.Artifacts, "checksum")
+	if !contains(signArtifactsOptions, artifacts) {
+		return nil, fmt.Errorf("Unknown minisign artifacts %s (expected one of %s)", artifacts, strings.Join(signArtifactsOptions, ", "))
+	}
+
+	source := cfg.Key
+	data := []byte(os.Getenv(stringOption(cfg.KeyEnv, defaultMinisignKeyEnv)))
+	if cfg.Key != "" {
+		var err error
+		data, err = ioutil.ReadFile(cfg.Key)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read minisign key: %v", err)
+		}
+	} else {
+		source = fmt.Sprintf("$%s", stringOption(cfg.KeyEnv, defaultMinisignKeyEnv))
+	}
+
+	key, err := readMinisignPrivateKey(data, os.Getenv(stringOption(cfg.PasswordEnv, defaultMinisignPasswordEnv)))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load minisign key %s: %v", source, err)
+	}
+
+	signer := &minisignSigner{key: key, tag: tag, all: artifacts == "all"}
+	if cfg.PublicKey == "" {
+		return signer, nil
+	}
+
+	data, err = ioutil.ReadFile(cfg.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read minisign public key: %v", err)
+	}
+
+	signer.publicKey, err = readMinisignPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load minisign public key %s: %v", cfg.PublicKey, err)
+	}
+
+	message := []byte("goRelease signing check")
+	signature, err := key.sign(bytes.NewReader(message), "goRelease signing check")
+	if err == nil {
+		err = signer.publicKey.verify(bytes.NewReader(message), signature)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Minisign key %s does not match public key %s: %v", source, cfg.PublicKey, err)
+	}
+
+	return signer, nil
+}
+
+func (signer *minisignSigner) signsEveryAsset() bool {
+	return signer.all
+}
+
+// signFile writes the signature for a file next to it and returns the signature's file name
+func (signer *minisignSigner) signFile(fileName string) (string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+
+	trustedComment := fmt.Sprintf("timestamp:%d\tfile:%s\ttag:%s\thashed", time.Now().Unix(), path.Base(fileName), signer.tag)
+	signature, err := signer.key.sign(file, trustedComment)
+	_ = file.Close()
+	if err != nil {
+		return "", err
+	}
+
+	if signer.publicKey != nil {
+		file, err = os.Open(fileName)
+		if err != nil {
+			return "", err
+		}
+
+		err = signer.publicKey.verify(file, signature)
+		_ = file.Close()
+		if err != nil {
+			return "", fmt.Errorf("Signature verification failed: %v", err)
+		}
+	}
+
+	signatureName := signer.signatureName(fileName)
+	return signatureName, ioutil.WriteFile(signatureName, signature, 0644)
+}
+
+func (signer *minisignSigner) signatureName(fileName string) string {
+	return fmt.Sprintf("%s.minisig", fileName)
+}
+
+// sign creates a signature file with the trusted comment
+func (key *minisignPrivateKey) sign(data io.Reader, trustedComment string) ([]byte, error) {
+	digest := newBlake2b()
+	_, err := io.Copy(digest, data)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := ed25519.Sign(key.key, digest.Sum(nil))
+	globalSignature := ed25519.Sign(key.key, append(append([]byte{}, signature...), trustedComment...))
+	encoded := append(append(append([]byte{}, minisignHashedAlgorithm...), key.keyID...), signature...)
+	return []byte(fmt.Sprintf(
+		"untrusted comment: signature from goRelease minisign key %s\n%s\ntrusted comment: %s\n%s\n",
+		minisignKeyIDString(key.keyID),
+		base64.StdEncoding.EncodeToString(encoded),
+		trustedComment,
+		base64.StdEncoding.EncodeToString(globalSignature),
+	)), nil
+}
+
+// verify checks a signature file, including the signature of its trusted comment
+func (key *minisignPublicKey) verify(data io.Reader, signatureFile []byte) error {
+	lines := strings.Split(strings.TrimRight(strings.Replace(string(signatureFile), "\r\n", "\n", -1), "\n"), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("invalid signature file")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(signature) != minisignSignatureSize {
+		return errors.New("invalid signature")
+	}
+
+	if !bytes.Equal(signature[2:2+minisignKeyIDSize], key.keyID) {
+		return fmt.Errorf("signature was made by key %s, not %s", minisignKeyIDString(signature[2:2+minisignKeyIDSize]), minisignKeyIDString(key.keyID))
+	}
+
+	var message []byte
+	switch {
+	case bytes.Equal(signature[:2], minisignHashedAlgorithm):
+		digest := newBlake2b()
+		_, err = io.Copy(digest, data)
+		message = digest.Sum(nil)
+	case bytes.Equal(signature[:2], minisignAlgorithm):
+		message, err = ioutil.ReadAll(data)
+	default:
+		return errors.New("unsupported signature algorithm")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	signature = signature[2+minisignKeyIDSize:]
+	if !ed25519.Verify(key.key, message, signature) {
+		return errors.New("bad signature")
+	}
+
+	globalSignature, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || !ed25519.Verify(key.key, append(append([]byte{}, signature...), strings.TrimPrefix(lines[2], "trusted comment: ")...), globalSignature) {
+		return errors.New("bad trusted comment signature")
+	}
+
+	return nil
+}
+
+// readMinisignPrivateKey reads a secret key file, or just its base64 line, decrypting it with the password if it is encrypted
+func readMinisignPrivateKey(data []byte, password string) (*minisignPrivateKey, error) {
+	decoded, err := decodeMinisignFile(data, minisignSecretKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(decoded[:2], minisignAlgorithm) || !bytes.Equal(decoded[4:6], minisignChecksumAlg) {
+		return nil, errors.New("unsupported key algorithm")
+	}
+
+	salt := decoded[6 : 6+minisignSaltSize]
+	limits := decoded[6+minisignSaltSize : 6+minisignSaltSize+16]
+	secret := decoded[6+minisignSaltSize+16:]
+	switch {
+	case bytes.Equal(decoded[2:4], minisignKDFAlgorithm):
+		stream, err := minisignKeyStream(password, salt, binary.LittleEndian.Uint64(limits), binary.LittleEndian.Uint64(limits[8:]))
+		if err != nil {
+			return nil, err
+		}
+
+		for index := range secret {
+			secret[index] ^= stream[index]
+		}
+	case !bytes.Equal(decoded[2:4], minisignNoKDF):
+		return nil, errors.New("unsupported key derivation")
+	}
+
+	keyID := secret[:minisignKeyIDSize]
+	privateKey := ed25519.PrivateKey(secret[minisignKeyIDSize : minisignKeyIDSize+ed25519.PrivateKeySize])
+	if subtle.ConstantTimeCompare(minisignChecksum(keyID, privateKey), secret[minisignKeyIDSize+ed25519.PrivateKeySize:]) != 1 {
+		return nil, errMinisignWrongPassword
+	}
+
+	return &minisignPrivateKey{
+		minisignPublicKey: minisignPublicKey{keyID: keyID, key: privateKey.Public().(ed25519.PublicKey)},
+		key:               privateKey,
+	}, nil
+}
+
+// readMinisignPublicKey reads a public key file, or just its base64 line
+func readMinisignPublicKey(data []byte) (*minisignPublicKey, error) {
+	decoded, err := decodeMinisignFile(data, minisignPublicKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(decoded[:2], minisignAlgorithm) {
+		return nil, errors.New("unsupported key algorithm")
+	}
+
+	return &minisignPublicKey{keyID: decoded[2 : 2+minisignKeyIDSize], key: ed25519.PublicKey(decoded[2+minisignKeyIDSize:])}, nil
+}
+
+// decodeMinisignFile decodes the first line of a key file that is not a comment
+func decodeMinisignFile(data []byte, size int) ([]byte, error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "untrusted comment:") {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(decoded) != size {
+			return nil, errors.New("invalid key")
+		}
+
+		return decoded, nil
+	}
+
+	return nil, errors.New("no key found")
+}
+
+// generateMinisignKey creates a new key pair in the minisign formats.  The secret key is encrypted unless password is empty.
+func generateMinisignKey(password string) ([]byte, []byte, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyID := make([]byte, minisignKeyIDSize)
+	salt := make([]byte, minisignSaltSize)
+	_, err = io.ReadFull(rand.Reader, keyID)
+	if err == nil {
+		_, err = io.ReadFull(rand.Reader, salt)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secret := append(append(append([]byte{}, keyID...), privateKey...), minisignChecksum(keyID, privateKey)...)
+	kdf := minisignNoKDF
+	limits := make([]byte, 16)
+	comment := "minisign secret key"
+	if password != "" {
+		kdf = minisignKDFAlgorithm
+		comment = "minisign encrypted secret key"
+		binary.LittleEndian.PutUint64(limits, minisignOpsLimit)
+		binary.LittleEndian.PutUint64(limits[8:], minisignMemLimit)
+		stream, err := minisignKeyStream(password, salt, minisignOpsLimit, minisignMemLimit)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for index := range secret {
+			secret[index] ^= stream[index]
+		}
+	}
+
+	encodedSecret := bytes.Join([][]byte{minisignAlgorithm, kdf, minisignChecksumAlg, salt, limits, secret}, nil)
+	encodedPublic := bytes.Join([][]byte{minisignAlgorithm, keyID, publicKey}, nil)
+	return []byte(fmt.Sprintf("untrusted comment: %s\n%s\n", comment, base64.StdEncoding.EncodeToString(encodedSecret))),
+		[]byte(fmt.Sprintf(
+			"untrusted comment: minisign public key %s\n%s\n",
+			minisignKeyIDString(keyID),
+			base64.StdEncoding.EncodeToString(encodedPublic),
+		)),
+		nil
+}
+
+func minisignChecksum(keyID, privateKey []byte) []byte {
+	checksum, _ := blake2b.New(minisignChecksumSize, nil)
+	checksum.Write(minisignAlgorithm)
+	checksum.Write(keyID)
+	checksum.Write(privateKey)
+	return checksum.Sum(nil)
+}
+
+// minisignKeyStream derives the stream that encrypts a secret key, picking the scrypt parameters from the limits the
+// same way libsodium's crypto_pwhash_scryptsalsa208sha256 does
+func minisignKeyStream(password string, salt []byte, opsLimit, memLimit uint64) ([]byte, error) {
+	if opsLimit < 32768 {
+		opsLimit = 32768
+	}
+
+	r, p := uint64(8), uint64(1)
+	maxN := opsLimit / (r * 4)
+	if opsLimit >= memLimit/32 {
+		maxN = memLimit / (r * 128)
+	}
+
+	nLog2 := uint(1)
+	for ; nLog2 < 63; nLog2++ {
+		if uint64(1)<<nLog2 > maxN/2 {
+			break
+		}
+	}
+
+	if opsLimit >= memLimit/32 {
+		maxrp := (opsLimit / 4) / (uint64(1) << nLog2)
+		if maxrp > 0x3fffffff {
+			maxrp = 0x3fffffff
+		}
+
+		p = maxrp / r
+	}
+
+	if nLog2 > 30 {
+		return nil, errors.New("key derivation limits are too large")
+	}
+
+	return scrypt.Key([]byte(password), salt, 1<<nLog2, int(r), int(p), minisignSecretKeySize-6-minisignSaltSize-16)
+}
+
+// minisignKeyIDString formats a key ID the way minisign prints it
+func minisignKeyIDString(keyID []byte) string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(keyID))
+}
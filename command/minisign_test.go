@@ -0,0 +1,182 @@
+package command_test
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+// testMinisignKey is encrypted with the password "secret" using small scrypt limits so the tests are fast
+const testMinisignKey = `untrusted comment: minisign encrypted secret key
+RWRTY0Iyhu5AutwiGTFi1aH2gqWhlMkS1ecPXIwVg65r61SXKdoAgAAAAAAAAAAAAAEAAAAAIIV9Ahj46tKdRb2rXV7brnW6auXTddnJexZS8hpG3qt+wEvrcYYqgH8Av0uI4DbBkxUcXUbVsn7gTQjRY/gBgujdu+NcFV/9DyIRXFWyo1N8YYTffy50Qu1yVgovKwMwPcR8xs18KD4=
+`
+
+const testMinisignPublicKey = `untrusted comment: minisign public key 870019EC4A96DAC5
+RWTF2pZK7BkAh0cbfYF+GBliyJ5ee8TfQ8EjJ62W/IvDs+7MeAvrNnjJ
+`
+
+// testUnencryptedMinisignKey is a different key that is not encrypted
+const testUnencryptedMinisignKey = `untrusted comment: minisign secret key
+RWQAAEIy5dA7lXMdlibXfxZ9DjC+NUhDAHyi7l6fP7+PDCVe0uoAAAAAAAAAAAAAAAAAAAAAJmK2+K4xC6CT2wp2WIrd0JSQ01NnL7rvWV3zJU/tKobo1ov0HDGqU4oL4XklreR/HjcLx/UsI+o7awh9r2ZM6F0VgjJ6aUFW4uTACnlANh3Fh8zQugbEAMUTUY/iBrWnpL9OMc02O3c=
+`
+
+const testUnencryptedMinisignPublicKey = `untrusted comment: minisign public key A00B31AEF8B66226
+RWQmYrb4rjELoIoL4XklreR/HjcLx/UsI+o7awh9r2ZM6F0VgjJ6aUFW
+`
+
+func TestReleaseMinisignChecksums(t *testing.T) {
+	uploads := make(map[string][]byte)
+	ts := getReleaseTestServerWithUploads(t, "", "", uploads)
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeTestFile(t, fmt.Sprintf("%s/minisign.key", mainPath), testMinisignKey, 0600)
+	writeTestFile(t, fmt.Sprintf("%s/minisign.pub", mainPath), testMinisignPublicKey, 0644)
+	writeConfig(t, mainPath, "builds:\n  os: [linux]\n  arch: [amd64]\nminisign:\n  key: minisign.key\n  publicKey: minisign.pub\n")
+	assert.Nil(t, os.Setenv("GO_RELEASE_MINISIGN_PASSWORD", "secret"))
+	defer func() {
+		assert.Nil(t, os.Unsetenv("GO_RELEASE_MINISIGN_PASSWORD"))
+	}()
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return operatingSystem == "linux" && architecture == "amd64"
+	})
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, "", errWriter.String())
+	assertTrustedComment(t, testMinisignPublicKey, uploads["projectName_tag_checksums.txt.minisig"], "file:projectName_tag_checksums.txt\ttag:tag\thashed")
+	assert.Equal(t, 4, len(uploads))
+}
+
+func TestReleaseMinisignAllArtifacts(t *testing.T) {
+	uploads := make(map[string][]byte)
+	ts := getReleaseTestServerWithUploads(t, "", "", uploads)
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.String("minisignArtifacts", "all", "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  arch: [amd64]\nminisign:\n  keyEnv: TEST_MINISIGN_KEY\n")
+	assert.Nil(t, os.Setenv("TEST_MINISIGN_KEY", testUnencryptedMinisignKey))
+	defer func() {
+		assert.Nil(t, os.Unsetenv("TEST_MINISIGN_KEY"))
+	}()
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return architecture == "amd64"
+	})
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, "", errWriter.String())
+	for _, name := range []string{
+		"projectName-linux-amd64-go1.8-tag.tar.gz",
+		"projectName-darwin-amd64-go1.8-tag.tar.gz",
+		"projectName-solaris-amd64-go1.8-tag.tar.gz",
+		"projectName-windows-amd64-go1.8-tag.zip",
+		"projectName_tag_checksums.txt",
+	} {
+		assertTrustedComment(t, testUnencryptedMinisignPublicKey, uploads[fmt.Sprintf("%s.minisig", name)], fmt.Sprintf("file:%s\ttag:tag\thashed", name))
+	}
+
+	assert.Equal(t, 11, len(uploads))
+}
+
+func TestReleaseMinisignErrors(t *testing.T) {
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	testCases := []struct {
+		config string
+		key    string
+		err    string
+	}{
+		{"minisign:\n  key: missing.key\n", "", fmt.Sprintf("Unable to read minisign key: open %s/missing.key: no such file or directory", mainPath)},
+		{"minisign:\n  key: minisign.key\n", "", fmt.Sprintf("Unable to load minisign key %s/minisign.key: wrong password for that key", mainPath)},
+		{"minisign:\n  key: minisign.pub\n", "", fmt.Sprintf("Unable to load minisign key %s/minisign.pub: invalid key", mainPath)},
+		{"", "not a key", "Unable to load minisign key $GO_RELEASE_MINISIGN_KEY: invalid key"},
+		{
+			"minisign:\n  publicKey: minisign.pub\n",
+			testUnencryptedMinisignKey,
+			fmt.Sprintf(
+				"Minisign key $GO_RELEASE_MINISIGN_KEY does not match public key %s/minisign.pub: signature was made by key A00B31AEF8B66226, not 870019EC4A96DAC5",
+				mainPath,
+			),
+		},
+		{
+			"minisign:\n  artifacts: checksum\nchecksums:\n  disable: true\n",
+			testUnencryptedMinisignKey,
+			"Signing only the checksums file requires checksums, sign every asset to sign without checksums",
+		},
+	}
+	for _, testCase := range testCases {
+		set := flag.NewFlagSet("test", 0)
+		set.String("token", "fakeToken", "doc")
+		set.String("mainPath", mainPath, "doc")
+		err = set.Parse([]string{"owner", "repo", "tag", "projectName"})
+		assert.Nil(t, err)
+		writeConfig(t, mainPath, testCase.config)
+		writeTestFile(t, fmt.Sprintf("%s/minisign.key", mainPath), testMinisignKey, 0600)
+		writeTestFile(t, fmt.Sprintf("%s/minisign.pub", mainPath), testMinisignPublicKey, 0644)
+		assert.Nil(t, os.Setenv("GO_RELEASE_MINISIGN_KEY", testCase.key))
+		expectedRunner := &runner.Test{
+			ExpectedCommands: []*runner.ExpectedCommand{
+				getDistListCommand(t, mainPath),
+				runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+			},
+		}
+		app, _, _ := appWithTestWriters()
+		err = command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil))
+		assert.EqualError(t, err, testCase.err, testCase.config)
+		assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+		cleanUp(t, mainPath)
+	}
+
+	assert.Nil(t, os.Unsetenv("GO_RELEASE_MINISIGN_KEY"))
+}
+
+// assertTrustedComment checks the global signature of a minisign signature file against the public key
+func assertTrustedComment(t *testing.T, publicKeyFile string, signatureFile []byte, commentSuffix string) {
+	t.Helper()
+	publicKey, err := base64.StdEncoding.DecodeString(strings.Split(publicKeyFile, "\n")[1])
+	assert.Nil(t, err)
+	lines := strings.Split(string(signatureFile), "\n")
+	if !assert.Equal(t, 5, len(lines), string(signatureFile)) {
+		return
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(lines[1])
+	assert.Nil(t, err)
+	assert.Equal(t, "ED", string(signature[:2]))
+	assert.Equal(t, publicKey[2:10], signature[2:10])
+	trustedComment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	assert.True(t, strings.HasPrefix(trustedComment, "timestamp:"))
+	assert.True(t, strings.HasSuffix(trustedComment, commentSuffix), trustedComment)
+	globalSignature, err := base64.StdEncoding.DecodeString(lines[3])
+	assert.Nil(t, err)
+	assert.True(t, ed25519.Verify(publicKey[10:], append(signature[10:], trustedComment...), globalSignature))
+}
@@ -0,0 +1,171 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/guywithnose/runner"
+	"github.com/urfave/cli"
+)
+
+// bumps are the ways the next version can be worked out, auto choosing from the commits since the last version
+var bumps = []string{"auto", "major", "minor", "patch"}
+
+// String writes the version as a tag, e.g. v1.2.3
+func (version semver) String() string {
+	tag := fmt.Sprintf("v%d.%d.%d", version.Major, version.Minor, version.Patch)
+	if version.isPrerelease() {
+		tag = fmt.Sprintf("%s-%s", tag, strings.Join(version.Prerelease, "."))
+	}
+
+	if version.Build != "" {
+		tag = fmt.Sprintf("%s+%s", tag, version.Build)
+	}
+
+	return tag
+}
+
+// bump is the next release after the version.  A prerelease of a version is bumped to the version itself when that is
+// at least as big a change, so the release after v2.0.0-rc.1 with breaking changes is v2.0.0.
+func (version semver) bump(kind string) semver {
+	released := semver{Major: version.Major, Minor: version.Minor, Patch: version.Patch}
+	switch kind {
+	case "major":
+		if !version.isPrerelease() || version.Minor != 0 || version.Patch != 0 {
+			released = semver{Major: version.Major + 1}
+		}
+	case "minor":
+		if !version.isPrerelease() || version.Patch != 0 {
+			released = semver{Major: version.Major, Minor: version.Minor + 1}
+		}
+	default:
+		if !version.isPrerelease() {
+			released.Patch++
+		}
+	}
+
+	return released
+}
+
+// nextVersion works out the version after the newest semver tag in the history of HEAD.  An auto bump is major when a
+// commit since that tag is a breaking change, minor when one is a feature and a patch otherwise.  It returns the next
+// version, the one it comes after (empty if there are no tags yet) and the kind of bump.
+func nextVersion(cmdWrapper runner.Builder, mainPath, kind string) (string, string, string, error) {
+	previousTag, err := getPreviousTag(cmdWrapper, mainPath, "HEAD")
+	if err != nil {
+		return "", "", "", err
+	}
+
+	previous, _ := parseSemver(previousTag)
+	if kind == "auto" {
+		commits, err := getCommitsSince(cmdWrapper, mainPath, previousTag, "HEAD")
+		if err != nil {
+			return "", "", "", err
+		}
+
+		if len(commits) == 0 {
+			return "", "", "", fmt.Errorf("There are no commits since %s", previousTag)
+		}
+
+		kind = bumpFor(commits)
+	}
+
+	return previous.bump(kind).String(), previousTag, kind, nil
+}
+
+func bumpFor(commits []releaseNote) string {
+	kind := "patch"
+	for _, commit := range commits {
+		if commit.Breaking {
+			return "major"
+		}
+
+		if commit.Type == "feat" {
+			kind = "minor"
+		}
+	}
+
+	return kind
+}
+
+// bumpOption reads --bump, which is empty when the tag is not bumped
+func bumpOption(c *cli.Context) (string, error) {
+	bump := c.String("bump")
+	if bump != "" && !contains(bumps, bump) {
+		return "", cli.NewExitError(fmt.Sprintf("Unknown bump %s (expected one of %s)", bump, strings.Join(bumps, ", ")), 1)
+	}
+
+	return bump, nil
+}
+
+// checkVersion makes sure that a new release is a semantic version tag with a v prefix, and that it comes after every
+// release there already is, so v1.2.0 is not released after v1.10.0.  A release that already exists is not checked so
+// it can be released again.
+func checkVersion(client *githubClient, owner, repo, tagName string) error {
+	releases, err := getReleases(client, owner, repo)
+	if err != nil {
+		return err
+	}
+
+	latestTag := ""
+	var latest semver
+	for _, release := range releases {
+		if release.GetTagName() == tagName {
+			return nil
+		}
+
+		version, ok := parseSemver(release.GetTagName())
+		if ok && (latestTag == "" || version.compare(latest) > 0) {
+			latestTag, latest = release.GetTagName(), version
+		}
+	}
+
+	version, ok := parseSemver(tagName)
+	if !ok || !strings.HasPrefix(tagName, "v") {
+		return cli.NewExitError(fmt.Sprintf("%s is not a semantic version such as v1.2.3, pass --skipVersionCheck to release it anyway", tagName), 1)
+	}
+
+	if latestTag != "" && version.compare(latest) <= 0 {
+		return cli.NewExitError(fmt.Sprintf("%s is not greater than the latest release %s, pass --skipVersionCheck to release it anyway", tagName, latestTag), 1)
+	}
+
+	return nil
+}
+
+// NextVersionFlags are the valid next-version parameters
+var NextVersionFlags = flagsNamed("mainPath", "bump")
+
+// CmdNextVersion prints the version that comes after the last tag, going by the commits since it
+func CmdNextVersion(cmdWrapper runner.Builder) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		return cmdNextVersionHelper(c, cmdWrapper)
+	}
+}
+
+func cmdNextVersionHelper(c *cli.Context, cmdWrapper runner.Builder) error {
+	if c.NArg() != 0 {
+		return cli.NewExitError("Usage: \"goRelease next-version --bump {auto|major|minor|patch}\"", 1)
+	}
+
+	bump, err := bumpOption(c)
+	if err != nil {
+		return err
+	}
+
+	mainPath, err := getMainPath(c)
+	if err != nil {
+		return err
+	}
+
+	next, previous, kind, err := nextVersion(cmdWrapper, mainPath, stringOption(bump, "auto"))
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Unable to work out the next version: %v", err), 1)
+	}
+
+	if previous != "" {
+		fmt.Fprintf(c.App.ErrWriter, "%s is a %s bump of %s\n", next, kind, previous)
+	}
+
+	fmt.Fprintln(c.App.Writer, next)
+	return nil
+}
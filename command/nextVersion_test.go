@@ -0,0 +1,285 @@
+package command_test
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+const nextVersionLogCommand = "git log --no-merges --format=%H%x1f%s%x1f%b%x1e"
+
+func TestNextVersion(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	testCases := []struct {
+		bump     string
+		tags     string
+		previous string
+		log      string
+		next     string
+	}{
+		{"", "v0.9.0\nv1.0.0\nlatest\n", "v1.0.0", "aaaaaaa1111\x1ffix: handle empty tags\x1f\x1e\n", "v1.0.1 is a patch bump of v1.0.0"},
+		{"auto", "v1.0.0\nv1.1.0\n", "v1.1.0", "aaaaaaa1111\x1ffeat: add a flag\x1f\x1e\nbbbbbbb2222\x1ffix: a bug\x1f\x1e\n", "v1.2.0 is a minor bump of v1.1.0"},
+		{"", "v1.2.3\n", "v1.2.3", releaseNotesLog, "v2.0.0 is a major bump of v1.2.3"},
+		{"", "v2.0.0-rc.1\nv1.9.0\n", "v2.0.0-rc.1", "aaaaaaa1111\x1frefactor!: drop go1.7\x1f\x1e\n", "v2.0.0 is a major bump of v2.0.0-rc.1"},
+		{"", "v1.1.0-rc.1\nv1.0.0\n", "v1.1.0-rc.1", "aaaaaaa1111\x1ffeat: add a flag\x1f\x1e\n", "v1.1.0 is a minor bump of v1.1.0-rc.1"},
+		{"", "v1.0.0\nv1.0.1-rc.1\n", "v1.0.1-rc.1", "aaaaaaa1111\x1ffeat: add a flag\x1f\x1e\n", "v1.1.0 is a minor bump of v1.0.1-rc.1"},
+		{"", "v1.2.0+build.5\n", "v1.2.0+build.5", "aaaaaaa1111\x1fchore: update dependencies\x1f\x1e\n", "v1.2.1 is a patch bump of v1.2.0+build.5"},
+		{"", "", "", "aaaaaaa1111\x1fInitial commit\x1f\x1e\n", "v0.0.1"},
+		{"major", "v1.10.0\nv1.2.0\n", "v1.10.0", "", "v2.0.0 is a major bump of v1.10.0"},
+		{"minor", "v1.10.0\nv1.2.0\n", "v1.10.0", "", "v1.11.0 is a minor bump of v1.10.0"},
+		{"patch", "v1.10.0\nv1.2.0\n", "v1.10.0", "", "v1.10.1 is a patch bump of v1.10.0"},
+		{"minor", "", "", "", "v0.1.0"},
+	}
+	for _, testCase := range testCases {
+		commands := []*runner.ExpectedCommand{runner.NewExpectedCommand(mainPath, "git tag --merged HEAD", testCase.tags, 0)}
+		if testCase.bump == "" || testCase.bump == "auto" {
+			revisions := "HEAD"
+			if testCase.previous != "" {
+				revisions = fmt.Sprintf("%s..HEAD", testCase.previous)
+			}
+
+			logCommand := regexp.QuoteMeta(fmt.Sprintf("%s %s --", nextVersionLogCommand, revisions))
+			commands = append(commands, runner.NewExpectedCommand(mainPath, logCommand, testCase.log, 0))
+		}
+
+		output, errOutput, err := runNextVersion(t, mainPath, testCase.bump, commands)
+		assert.Nil(t, err, testCase.tags)
+		next := strings.Fields(testCase.next)[0]
+		assert.Equal(t, fmt.Sprintf("%s\n", next), output, testCase.tags)
+		if testCase.previous == "" {
+			assert.Equal(t, "", errOutput)
+		} else {
+			assert.Equal(t, fmt.Sprintf("%s\n", testCase.next), errOutput, testCase.tags)
+		}
+	}
+}
+
+func TestNextVersionErrors(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	testCases := []struct {
+		bump     string
+		args     []string
+		commands []*runner.ExpectedCommand
+		err      string
+	}{
+		{"", []string{"v1.0.0"}, nil, "Usage: \"goRelease next-version --bump {auto|major|minor|patch}\""},
+		{"huge", nil, nil, "Unknown bump huge (expected one of auto, major, minor, patch)"},
+		{
+			"",
+			nil,
+			[]*runner.ExpectedCommand{runner.NewExpectedCommand(mainPath, "git tag --merged HEAD", "fatal: not a git repository", 128)},
+			"Unable to work out the next version: Unable to list the tags before HEAD: exit status 128",
+		},
+		{
+			"",
+			nil,
+			[]*runner.ExpectedCommand{
+				runner.NewExpectedCommand(mainPath, "git tag --merged HEAD", "v1.0.0\n", 0),
+				runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s v1.0.0..HEAD --", nextVersionLogCommand), "", 0),
+			},
+			"Unable to work out the next version: There are no commits since v1.0.0",
+		},
+		{
+			"auto",
+			nil,
+			[]*runner.ExpectedCommand{
+				runner.NewExpectedCommand(mainPath, "git tag --merged HEAD", "v1.0.0\n", 0),
+				runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s v1.0.0..HEAD --", nextVersionLogCommand), "fatal: bad revision", 128),
+			},
+			"Unable to work out the next version: Unable to list the commits in v1.0.0..HEAD: exit status 128",
+		},
+	}
+	for _, testCase := range testCases {
+		_, _, err := runNextVersion(t, mainPath, testCase.bump, testCase.commands, testCase.args...)
+		assert.EqualError(t, err, testCase.err)
+	}
+}
+
+func TestReleaseVersionCheck(t *testing.T) {
+	ts := getVersionTestServer(t, "v1.2.0", "v1.10.0", "latest")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	testCases := []struct {
+		tagName  string
+		checkTag bool
+		err      string
+	}{
+		{"1.11.0", false, "1.11.0 is not a semantic version such as v1.2.3, pass --skipVersionCheck to release it anyway"},
+		{"v1.11", false, "v1.11 is not a semantic version such as v1.2.3, pass --skipVersionCheck to release it anyway"},
+		{"v1.3.0", false, "v1.3.0 is not greater than the latest release v1.10.0, pass --skipVersionCheck to release it anyway"},
+		{"v1.10.0-rc.1", false, "v1.10.0-rc.1 is not greater than the latest release v1.10.0, pass --skipVersionCheck to release it anyway"},
+		{"v1.10.0", true, "Unable to check the working tree: exit status 128"},
+		{"v1.10.1", true, "Unable to check the working tree: exit status 128"},
+		{"latest", true, "Unable to check the working tree: exit status 128"},
+	}
+	for _, testCase := range testCases {
+		set := getVersionFlagSet(t, ts.URL, mainPath)
+		assert.Nil(t, set.Parse([]string{"owner", "repo", testCase.tagName, "projectName"}))
+		createFiles(t, mainPath, testCase.tagName)
+		writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+		expectedRunner := getVersionRunner(t, mainPath, testCase.checkTag)
+		app, _, _ := appWithTestWriters()
+		assert.EqualError(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)), testCase.err, testCase.tagName)
+		assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands, testCase.tagName)
+	}
+}
+
+func TestReleaseSkipVersionCheck(t *testing.T) {
+	ts := getVersionTestServer(t, "v1.10.0")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	for _, skip := range []func(*flag.FlagSet){
+		func(set *flag.FlagSet) { set.Bool("skipVersionCheck", true, "doc") },
+		func(set *flag.FlagSet) {
+			writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\nrelease:\n  skipVersionCheck: true\n")
+		},
+	} {
+		set := getVersionFlagSet(t, ts.URL, mainPath)
+		createFiles(t, mainPath, "1.2")
+		writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+		skip(set)
+		assert.Nil(t, set.Parse([]string{"owner", "repo", "1.2", "projectName"}))
+		expectedRunner := getVersionRunner(t, mainPath, true)
+		app, _, _ := appWithTestWriters()
+		assert.EqualError(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)), "Unable to check the working tree: exit status 128")
+		assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	}
+}
+
+func TestReleaseBump(t *testing.T) {
+	ts := getVersionTestServer(t, "v1.2.0", "v1.10.0")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	set := getVersionFlagSet(t, ts.URL, mainPath)
+	set.String("bump", "auto", "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "projectName"}))
+	createFiles(t, mainPath, "v1.11.0")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+	expectedRunner := getVersionRunner(t, mainPath, true)
+	expectedRunner.ExpectedCommands = append(
+		[]*runner.ExpectedCommand{
+			runner.NewExpectedCommand(mainPath, "git tag --merged HEAD", "v1.2.0\nv1.10.0\n", 0),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s v1.10.0..HEAD --", nextVersionLogCommand), "aaaaaaa1111\x1ffeat(api): add the v2 endpoints\x1f\x1e\n", 0),
+		},
+		expectedRunner.ExpectedCommands...,
+	)
+	app, _, errWriter := appWithTestWriters()
+	assert.EqualError(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)), "Unable to check the working tree: exit status 128")
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, "Inferred the tag v1.11.0 from a minor bump of v1.10.0\n", errWriter.String())
+}
+
+func TestReleaseBumpUsage(t *testing.T) {
+	for _, args := range [][]string{{"owner", "repo", "v1.0.0", "projectName"}, {"v1.0.0"}} {
+		set := flag.NewFlagSet("test", 0)
+		set.String("token", "fakeToken", "doc")
+		set.String("apiUrl", "http://127.0.0.1/", "doc")
+		set.String("bump", "patch", "doc")
+		assert.Nil(t, set.Parse(args))
+		app, _, _ := appWithTestWriters()
+		err := command.CmdRelease(&runner.Test{})(cli.NewContext(app, set, nil))
+		assert.EqualError(t, err, "Usage: \"goRelease {owner} {repo} {tagName} {projectName} --token {token} --apiUrl {apiUrl}\"")
+	}
+}
+
+// runNextVersion runs next-version with the git commands it is expected to run
+func runNextVersion(t *testing.T, mainPath, bump string, commands []*runner.ExpectedCommand, args ...string) (string, string, error) {
+	t.Helper()
+	set := flag.NewFlagSet("test", 0)
+	set.String("mainPath", mainPath, "doc")
+	if bump != "" {
+		set.String("bump", bump, "doc")
+	}
+
+	assert.Nil(t, set.Parse(args))
+	expectedRunner := &runner.Test{ExpectedCommands: append([]*runner.ExpectedCommand{}, commands...)}
+	app, writer, errWriter := appWithTestWriters()
+	err := command.CmdNextVersion(expectedRunner)(cli.NewContext(app, set, nil))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+	return writer.String(), errWriter.String(), err
+}
+
+func TestPublishVersionCheck(t *testing.T) {
+	ts := getVersionTestServer(t, "v1.10.0")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeTestFile(t, fmt.Sprintf("%s/dist/projectName.tar.gz", mainPath), "foo", 0644)
+	writeTestManifest(t, mainPath, "v1.3.0", "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae")
+	set := getVersionFlagSet(t, ts.URL, mainPath)
+	assert.Nil(t, set.Parse([]string{}))
+	app, _, _ := appWithTestWriters()
+	err := command.CmdPublish(&runner.Test{})(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "v1.3.0 is not greater than the latest release v1.10.0, pass --skipVersionCheck to release it anyway")
+
+	set = getVersionFlagSet(t, ts.URL, mainPath)
+	set.Bool("skipVersionCheck", true, "doc")
+	assert.Nil(t, set.Parse([]string{}))
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/v1.3.0^{commit}"), "", 128),
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git ls-remote --tags origin refs/tags/v1.3.0 refs/tags/v1.3.0^{}"), "fatal: not a git repository", 128),
+		},
+	}
+	err = command.CmdPublish(expectedRunner)(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Unable to look up v1.3.0 on origin: exit status 128")
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+}
+
+// getVersionTestServer lists a release for each of the tags
+func getVersionTestServer(t *testing.T, tags ...string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/owner/repo/releases?per_page=100", r.URL.String())
+		releases := []*github.RepositoryRelease{}
+		for index := range tags {
+			id := index + 1
+			releases = append(releases, &github.RepositoryRelease{TagName: &tags[index], ID: &id})
+		}
+
+		assert.Nil(t, json.NewEncoder(w).Encode(releases))
+	}))
+}
+
+func getVersionFlagSet(t *testing.T, url, mainPath string) *flag.FlagSet {
+	t.Helper()
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", url), "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.Int("maxAttempts", 1, "doc")
+	return set
+}
+
+// getVersionRunner expects the release to be planned, followed by a working tree check that fails if checkTag is set
+func getVersionRunner(t *testing.T, mainPath string, checkTag bool) *runner.Test {
+	t.Helper()
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	expectedCommands := []*runner.ExpectedCommand{
+		getDistListCommand(t, mainPath),
+		runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+	}
+	if checkTag {
+		expectedCommands = append(expectedCommands, runner.NewExpectedCommand(mainPath, "git status --porcelain -- :!dist", "fatal: not a git repository", 128))
+	}
+
+	return &runner.Test{ExpectedCommands: expectedCommands}
+}
@@ -0,0 +1,108 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/guywithnose/runner"
+	"github.com/urfave/cli"
+)
+
+// CmdPackage archives the binaries in the dist directory and writes their SBOMs, attestations, checksums and signatures
+// next to them, adding everything to the manifest for publish
+func CmdPackage(cmdWrapper runner.Builder) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		return cmdPackageHelper(c, cmdWrapper)
+	}
+}
+
+func cmdPackageHelper(c *cli.Context, cmdWrapper runner.Builder) error {
+	if c.NArg() != 0 {
+		return cli.NewExitError("Usage: \"goRelease package --dist {dist}\"", 1)
+	}
+
+	mainPath, err := getMainPath(c)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(c.String("config"), mainPath)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	manifest, err := readManifest(distDirectory(c, mainPath))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	err = applySBOMFlags(c, cfg)
+	if err != nil {
+		return err
+	}
+
+	applyProvenanceFlags(c, cfg, mainPath)
+	data := manifest.Data
+	if cfg.Provenance.enabled() && data.Commit == "" {
+		data.Commit, err = getCommit(cmdWrapper, mainPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	repository := repositoryURL(stringOption(c.String("apiUrl"), cfg.Release.APIURL), manifest.Owner, manifest.Repo)
+	sums, signers, attestor, err := preparePackaging(c, cfg, data, mainPath, manifest.dist, repository)
+	if err != nil {
+		return err
+	}
+
+	// Packaging again replaces whatever was packaged before, so only the binaries are kept from the manifest
+	binaries := []artifact{}
+	for _, asset := range manifest.Artifacts {
+		if asset.Type == artifactBinary {
+			binaries = append(binaries, asset)
+		}
+	}
+
+	manifest.Artifacts = binaries
+	for index := range manifest.Targets {
+		manifest.Targets[index].SBOMs = sbomFileNames(manifest.Targets[index].ArchivePath, cfg.SBOM.Formats)
+	}
+
+	allowPartial, requiredTargets := partialOptions(c, cfg)
+	err = checkRequiredTargets(requiredTargets, manifest.Targets)
+	if err != nil {
+		return err
+	}
+
+	buildConcurrency, err := concurrencyOption(c, "buildConcurrency", defaultBuildConcurrency)
+	if err != nil {
+		return err
+	}
+
+	uploadConcurrency, err := concurrencyOption(c, "uploadConcurrency", defaultUploadConcurrency)
+	if err != nil {
+		return err
+	}
+
+	results := newReleaseResults(c.App.ErrWriter, manifest.Targets)
+	packager := &uploader{
+		destination: manifest,
+		keep:        true,
+		sums:        sums,
+		signers:     signers,
+		results:     results,
+		stage:       stagePackage,
+		concurrency: uploadConcurrency,
+	}
+	packager.uploadBinaries(forEachTarget(manifest.Targets, buildConcurrency, func(target buildTarget) []artifact {
+		return packageTarget(target, attestor, results)
+	}))
+
+	err = manifest.write()
+	if err != nil {
+		return fmt.Errorf("Unable to write manifest: %v", err)
+	}
+
+	results.writeSummary(c.App.Writer)
+	return results.err(allowPartial, requiredTargets)
+}
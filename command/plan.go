@@ -0,0 +1,242 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// planFormats are the ways a dry run can print the plan
+var planFormats = []string{"text", "json"}
+
+// releasePlan is everything a release would do.  It is built with read only API calls so a dry run changes nothing.
+type releasePlan struct {
+	Owner       string           `json:"owner"`
+	Repo        string           `json:"repo"`
+	Tag         string           `json:"tag"`
+	TagCommands [][]string       `json:"tagCommands"`
+	Release     plannedRelease   `json:"release"`
+	Targets     []plannedTarget  `json:"targets"`
+	APICalls    []plannedAPICall `json:"apiCalls"`
+}
+
+type plannedRelease struct {
+	ID     int  `json:"id,omitempty"`
+	Exists bool `json:"exists"`
+	Draft  bool `json:"draft"`
+}
+
+type plannedTarget struct {
+	Target      string         `json:"target"`
+	Command     []string       `json:"command"`
+	Environment []string       `json:"environment"`
+	Archive     plannedArchive `json:"archive"`
+	Assets      []string       `json:"assets"`
+}
+
+type plannedArchive struct {
+	Format string               `json:"format"`
+	Path   string               `json:"path"`
+	Files  []plannedArchiveFile `json:"files"`
+}
+
+type plannedArchiveFile struct {
+	Source string `json:"source"`
+	Name   string `json:"name"`
+}
+
+type plannedAPICall struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+}
+
+// planRelease works out the builds, archives and API calls for a release, after the git commands that create and push
+// the tag.  The release and its assets are looked up but nothing is created, edited, deleted or uploaded.  Assets that would be skipped because the release already has
+// them are left out, and the ones that would be replaced are deleted first.  Assets are not built, so the plan cannot
+// tell which of them have not changed and would not be uploaded.
+func planRelease(
+	destination *githubRelease,
+	u *uploader,
+	tagCommands [][]string,
+	targets []buildTarget,
+	settings releaseSettings,
+	attest bool,
+) (*releasePlan, error) {
+	client, owner, repo, tagName := destination.client, destination.owner, destination.repo, settings.tagName
+	plan := &releasePlan{Owner: owner, Repo: repo, Tag: tagName, TagCommands: tagCommands, Release: plannedRelease{Draft: !settings.publish}}
+	release, err := findRelease(client, owner, repo, tagName)
+	if err != nil {
+		return nil, err
+	}
+
+	releaseID := "{id}"
+	if release == nil {
+		plan.addAPICall(client.BaseURL, "POST", fmt.Sprintf("repos/%s/%s/releases", owner, repo), "create release %s", tagName)
+	} else {
+		plan.Release = plannedRelease{ID: release.GetID(), Exists: true, Draft: release.GetDraft() && !settings.publish}
+		releaseID = fmt.Sprintf("%d", release.GetID())
+		if _, changed := settings.patch(release); len(changed) != 0 {
+			plan.addAPICall(client.BaseURL, "PATCH", fmt.Sprintf("repos/%s/%s/releases/%s", owner, repo, releaseID), "%s", settings.describeEdit(changed))
+		}
+	}
+
+	existing := map[string]*github.ReleaseAsset{}
+	if release != nil {
+		assets, err := getAssets(client, release.GetID(), owner, repo)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, asset := range assets {
+			existing[asset.GetName()] = asset
+		}
+	}
+
+	replace := func(name string) {
+		if asset, ok := existing[name]; ok {
+			plan.addAPICall(
+				client.BaseURL,
+				"DELETE",
+				fmt.Sprintf("repos/%s/%s/releases/assets/%d", owner, repo, asset.GetID()),
+				"delete asset %s",
+				name,
+			)
+		}
+
+		plan.addUpload(client.UploadURL, owner, repo, releaseID, name, "upload %s", name)
+	}
+
+	upload := func(fileName string) {
+		name := path.Base(fileName)
+		_, ok := existing[name]
+		switch {
+		case ok && destination.onConflict == onConflictSkip:
+			return
+		case ok && destination.onConflict == onConflictFail:
+			plan.addUpload(client.UploadURL, owner, repo, releaseID, name, "upload %s if it changed, which fails unless an earlier run uploaded it", name)
+		default:
+			replace(name)
+		}
+	}
+
+	checksummed := false
+	for _, target := range targets {
+		planned := plannedTarget{
+			Target:      target.String(),
+			Command:     target.Command,
+			Environment: target.Environment,
+			Archive:     plannedArchive{Format: target.ArchiveFormat, Path: target.ArchivePath},
+			Assets:      []string{target.ArchivePath},
+		}
+		for _, file := range target.archiveFiles() {
+			planned.Archive.Files = append(planned.Archive.Files, plannedArchiveFile{Source: file.Source, Name: path.Join(target.Directory, file.Name)})
+		}
+
+		for _, sbom := range target.SBOMs {
+			planned.Assets = append(planned.Assets, sbom.FileName)
+		}
+
+		if attest {
+			planned.Assets = append(planned.Assets, fmt.Sprintf("%s.intoto.jsonl", target.ArchivePath))
+		}
+
+		for _, asset := range planned.Assets {
+			for _, fileName := range u.plannedUploads(asset, false) {
+				upload(fileName)
+			}
+
+			checksummed = checksummed || (u.sums != nil && u.sums.includes(asset))
+		}
+
+		plan.Targets = append(plan.Targets, planned)
+	}
+
+	if checksummed {
+		for _, fileName := range u.plannedUploads(u.sums.fileName, true) {
+			upload(fileName)
+		}
+	}
+
+	replace(digestManifestName)
+	return plan, nil
+}
+
+// plannedUploads lists the files uploadBinaries would upload for an asset, in the order it would upload them
+func (u *uploader) plannedUploads(fileName string, isChecksums bool) []string {
+	uploads := []string{fileName}
+	for _, signer := range u.signers {
+		if isChecksums || signer.signsEveryAsset() {
+			uploads = append(uploads, signer.signatureName(fileName))
+		}
+	}
+
+	if !isChecksums && u.sums != nil && u.sums.sidecars && u.sums.includes(fileName) {
+		uploads = append(uploads, u.sums.sidecarName(fileName))
+	}
+
+	return uploads
+}
+
+func (plan *releasePlan) addAPICall(baseURL *url.URL, method, endpoint, format string, args ...interface{}) {
+	plan.APICalls = append(plan.APICalls, plannedAPICall{
+		Method:      method,
+		URL:         fmt.Sprintf("%s%s", baseURL, endpoint),
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+func (plan *releasePlan) addUpload(uploadURL *url.URL, owner, repo, releaseID, name, format string, args ...interface{}) {
+	plan.addAPICall(uploadURL, "POST", fmt.Sprintf("repos/%s/%s/releases/%s/assets?name=%s", owner, repo, releaseID, name), format, args...)
+}
+
+// writePlan prints the plan as text for reading or as JSON for diffing plans between releases
+func writePlan(writer io.Writer, plan *releasePlan, format string) error {
+	if format == "json" {
+		encoded, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(writer, "%s\n", encoded)
+		return err
+	}
+
+	release := "a new"
+	if plan.Release.Exists {
+		release = fmt.Sprintf("the existing (id %d)", plan.Release.ID)
+	}
+
+	draft := "published"
+	if plan.Release.Draft {
+		draft = "draft"
+	}
+
+	fmt.Fprintf(writer, "Dry run for %s/%s %s using %s %s release\n", plan.Owner, plan.Repo, plan.Tag, release, draft)
+	if len(plan.TagCommands) != 0 {
+		fmt.Fprintf(writer, "\nTag %s:\n", plan.Tag)
+		for _, command := range plan.TagCommands {
+			fmt.Fprintf(writer, "  %s\n", strings.Join(command, " "))
+		}
+	}
+
+	for _, target := range plan.Targets {
+		fmt.Fprintf(writer, "\nBuild %s:\n  %s %s\n", target.Target, strings.Join(target.Environment, " "), strings.Join(target.Command, " "))
+		fmt.Fprintf(writer, "Archive %s (%s):\n", target.Archive.Path, target.Archive.Format)
+		for _, file := range target.Archive.Files {
+			fmt.Fprintf(writer, "  %s <- %s\n", file.Name, file.Source)
+		}
+	}
+
+	fmt.Fprintf(writer, "\nAPI calls:\n")
+	for _, call := range plan.APICalls {
+		fmt.Fprintf(writer, "  %s %s (%s)\n", call.Method, call.URL, call.Description)
+	}
+
+	return nil
+}
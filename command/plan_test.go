@@ -0,0 +1,246 @@
+package command_test
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestReleaseDryRun(t *testing.T) {
+	requests := []string{}
+	ts := getPlanTestServer(t, &requests)
+	defer ts.Close()
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.Bool("dryRun", true, "doc")
+	set.Bool("publish", true, "doc")
+	set.Bool("removeOldAssets", true, "doc")
+	set.Bool("checksumSidecars", true, "doc")
+	err = set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			getDistListCommand(t, mainPath),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+			runner.NewExpectedCommand(mainPath, "git status --porcelain -- :!dist", "", 0),
+			runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "abc123\n", 0),
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/tag^{commit}"), "", 1),
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git ls-remote --tags origin refs/tags/tag refs/tags/tag^{}"), "", 0),
+		},
+	}
+	app, writer, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+	assert.Equal(t, "", errWriter.String())
+	assert.Equal(
+		t,
+		[]string{
+			"GET /repos/owner/repo/releases?per_page=100",
+			"GET /repos/owner/repo/releases?per_page=100",
+			"GET /repos/owner/repo/releases/1/assets?per_page=100",
+		},
+		requests,
+	)
+	assert.Equal(
+		t,
+		fmt.Sprintf(`Dry run for owner/repo tag using the existing (id 1) published release
+
+Tag tag:
+  git tag tag abc123
+  git push origin refs/tags/tag
+
+Build linux/amd64:
+  GOOS=linux GOARCH=amd64 GOPATH=%[3]s %[4]s build -o %[1]s/dist/linux_amd64/projectName-linux-amd64-go1.8-tag
+Archive %[1]s/dist/linux_amd64/projectName-linux-amd64-go1.8-tag.tar.gz (tar.gz):
+  projectName-linux-amd64-go1.8-tag/projectName <- %[1]s/dist/linux_amd64/projectName-linux-amd64-go1.8-tag
+
+API calls:
+  PATCH %[2]s/repos/owner/repo/releases/1 (publish release tag)
+  POST %[2]s/repos/owner/repo/releases/1/assets?name=projectName-linux-amd64-go1.8-tag.tar.gz (upload projectName-linux-amd64-go1.8-tag.tar.gz)
+  POST %[2]s/repos/owner/repo/releases/1/assets?name=projectName-linux-amd64-go1.8-tag.tar.gz.sha256 (upload projectName-linux-amd64-go1.8-tag.tar.gz.sha256)
+  DELETE %[2]s/repos/owner/repo/releases/assets/4 (delete asset projectName_tag_checksums.txt)
+  POST %[2]s/repos/owner/repo/releases/1/assets?name=projectName_tag_checksums.txt (upload projectName_tag_checksums.txt)
+  POST %[2]s/repos/owner/repo/releases/1/assets?name=digests.json (upload digests.json)
+`, mainPath, ts.URL, os.Getenv("GOPATH"), goExecutable),
+		writer.String(),
+	)
+
+	// Nothing was built or archived
+	_, err = os.Stat(distFile(mainPath, "linux", "amd64", "projectName-linux-amd64-go1.8-tag"))
+	assert.Nil(t, err)
+	_, err = os.Stat(distFile(mainPath, "linux", "amd64", "projectName-linux-amd64-go1.8-tag.tar.gz"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(fmt.Sprintf("%s/dist/artifacts.json", mainPath))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReleaseDryRunJSON(t *testing.T) {
+	requests := []string{}
+	ts := getPlanTestServer(t, &requests)
+	defer ts.Close()
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.Bool("dryRun", true, "doc")
+	set.String("planFormat", "json", "doc")
+	set.String("minisignArtifacts", "all", "doc")
+	set.Bool("skipVersionCheck", true, "doc")
+	err = set.Parse([]string{"owner", "repo", "v2", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "v2")
+	writeConfig(t, mainPath, "builds:\n  targets: [windows/amd64]\nsbom:\n  formats: [spdx]\n")
+	assert.Nil(t, os.Setenv("GO_RELEASE_MINISIGN_KEY", testUnencryptedMinisignKey))
+	defer func() {
+		assert.Nil(t, os.Unsetenv("GO_RELEASE_MINISIGN_KEY"))
+	}()
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			getDistListCommand(t, mainPath),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+			runner.NewExpectedCommand(mainPath, "git status --porcelain -- :!dist", "", 0),
+			runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "abc123\n", 0),
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/v2^{commit}"), "", 1),
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git ls-remote --tags origin refs/tags/v2 refs/tags/v2^{}"), "", 0),
+		},
+	}
+	app, writer, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+	assert.Equal(t, "", errWriter.String())
+	assert.Equal(t, []string{"GET /repos/owner/repo/releases?per_page=100"}, requests)
+
+	plan := struct {
+		Tag         string
+		TagCommands [][]string
+		Release     struct {
+			Exists bool
+			Draft  bool
+		}
+		Targets []struct {
+			Target  string
+			Command []string
+			Archive struct {
+				Format string
+				Path   string
+			}
+			Assets []string
+		}
+		APICalls []struct {
+			Method      string
+			URL         string
+			Description string
+		}
+	}{}
+	assert.Nil(t, json.Unmarshal(writer.Bytes(), &plan))
+	assert.Equal(t, "v2", plan.Tag)
+	assert.Equal(t, [][]string{{"git", "tag", "v2", "abc123"}, {"git", "push", "origin", "refs/tags/v2"}}, plan.TagCommands)
+	assert.False(t, plan.Release.Exists)
+	assert.True(t, plan.Release.Draft)
+	assert.Equal(t, 1, len(plan.Targets))
+	assert.Equal(t, "windows/amd64", plan.Targets[0].Target)
+	assert.Equal(t, "zip", plan.Targets[0].Archive.Format)
+	assert.Equal(
+		t,
+		[]string{
+			distFile(mainPath, "windows", "amd64", "projectName-windows-amd64-go1.8-v2.zip"),
+			distFile(mainPath, "windows", "amd64", "projectName-windows-amd64-go1.8-v2.zip.sbom.json"),
+		},
+		plan.Targets[0].Assets,
+	)
+	descriptions := []string{}
+	for _, call := range plan.APICalls {
+		descriptions = append(descriptions, fmt.Sprintf("%s %s", call.Method, call.Description))
+	}
+
+	assert.Equal(
+		t,
+		[]string{
+			"POST create release v2",
+			"POST upload projectName-windows-amd64-go1.8-v2.zip",
+			"POST upload projectName-windows-amd64-go1.8-v2.zip.minisig",
+			"POST upload projectName-windows-amd64-go1.8-v2.zip.sbom.json",
+			"POST upload projectName-windows-amd64-go1.8-v2.zip.sbom.json.minisig",
+			"POST upload projectName_v2_checksums.txt",
+			"POST upload projectName_v2_checksums.txt.minisig",
+			"POST upload digests.json",
+		},
+		descriptions,
+	)
+	assert.Equal(t, fmt.Sprintf("%s/repos/owner/repo/releases/{id}/assets?name=projectName_v2_checksums.txt", ts.URL), plan.APICalls[5].URL)
+}
+
+func TestReleaseDryRunInvalidFormat(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.Bool("dryRun", true, "doc")
+	set.String("planFormat", "yaml", "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	app, _, _ := appWithTestWriters()
+	err = command.CmdRelease(&runner.Test{})(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Unknown plan format yaml (expected one of text, json)")
+}
+
+// getPlanTestServer serves a draft release for the tag "tag" with two assets and fails anything that is not a GET
+func getPlanTestServer(t *testing.T, requests *[]string) *httptest.Server {
+	t.Helper()
+	requestsMutex := sync.Mutex{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsMutex.Lock()
+		*requests = append(*requests, fmt.Sprintf("%s %s", r.Method, r.URL))
+		requestsMutex.Unlock()
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var response interface{}
+		switch r.URL.String() {
+		case "/repos/owner/repo/releases?per_page=100":
+			tag := "tag"
+			id := 1
+			draft := true
+			response = []*github.RepositoryRelease{{TagName: &tag, ID: &id, Draft: &draft}}
+		case "/repos/owner/repo/releases/1/assets?per_page=100":
+			idThree, idFour := 3, 4
+			tarName, zipName := "old.tar.gz", "projectName_tag_checksums.txt"
+			response = []*github.ReleaseAsset{{ID: &idThree, Name: &tarName}, {ID: &idFour, Name: &zipName}}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		bytes, _ := json.Marshal(response)
+		fmt.Fprint(w, string(bytes))
+	}))
+}
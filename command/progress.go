@@ -0,0 +1,200 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is how often the uploads in flight are redrawn on a terminal
+const progressInterval = 200 * time.Millisecond
+
+// progress reports uploads as they happen.  A line is printed as each upload finishes and, on a terminal, the uploads
+// in flight are redrawn below those lines with how much has been sent and how fast.
+type progress struct {
+	writer   io.Writer
+	live     bool
+	mutex    sync.Mutex
+	uploads  []*transfer
+	finished int
+	drawn    int
+	done     chan struct{}
+	stopped  sync.WaitGroup
+}
+
+// transfer is a single upload, sent is updated as the request body is read
+type transfer struct {
+	name    string
+	size    int64
+	sent    int64
+	started time.Time
+}
+
+func newProgress(writer io.Writer) *progress {
+	uploads := &progress{writer: writer, live: isTerminal(writer), done: make(chan struct{})}
+	if uploads.live {
+		uploads.stopped.Add(1)
+		go uploads.redraw()
+	}
+
+	return uploads
+}
+
+// isTerminal reports whether writer is a terminal that the uploads in flight can be redrawn on
+func isTerminal(writer io.Writer) bool {
+	file, ok := writer.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// start records that an upload began
+func (uploads *progress) start(name string, size int64) *transfer {
+	upload := &transfer{name: name, size: size, started: time.Now()}
+	uploads.mutex.Lock()
+	defer uploads.mutex.Unlock()
+	uploads.uploads = append(uploads.uploads, upload)
+	return upload
+}
+
+// finish records that an upload is over and prints a line for it when it worked.  Failures are reported with the
+// release results.
+func (uploads *progress) finish(upload *transfer, err error) {
+	uploads.mutex.Lock()
+	defer uploads.mutex.Unlock()
+	for index, inFlight := range uploads.uploads {
+		if inFlight == upload {
+			uploads.uploads = append(uploads.uploads[:index], uploads.uploads[index+1:]...)
+			break
+		}
+	}
+
+	if err != nil {
+		return
+	}
+
+	uploads.finished++
+	uploads.clear()
+	elapsed := time.Since(upload.started)
+	fmt.Fprintf(uploads.writer, "[%d] Uploaded %s (%s in %v, %s/s)\n", uploads.finished, upload.name, formatBytes(upload.size), elapsed.Round(time.Millisecond), formatBytes(rate(upload.size, elapsed)))
+	uploads.draw()
+}
+
+// skip prints a line for an asset that was not uploaded and why
+func (uploads *progress) skip(name, reason string) {
+	uploads.mutex.Lock()
+	defer uploads.mutex.Unlock()
+	uploads.clear()
+	fmt.Fprintf(uploads.writer, "Skipped %s, %s\n", name, reason)
+	uploads.draw()
+}
+
+// stop clears the uploads in flight from the terminal
+func (uploads *progress) stop() {
+	if !uploads.live {
+		return
+	}
+
+	close(uploads.done)
+	uploads.stopped.Wait()
+	uploads.mutex.Lock()
+	defer uploads.mutex.Unlock()
+	uploads.clear()
+}
+
+func (uploads *progress) redraw() {
+	defer uploads.stopped.Done()
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-uploads.done:
+			return
+		case <-ticker.C:
+			uploads.mutex.Lock()
+			uploads.clear()
+			uploads.draw()
+			uploads.mutex.Unlock()
+		}
+	}
+}
+
+// clear moves back over the uploads in flight and erases them
+func (uploads *progress) clear() {
+	if uploads.drawn == 0 {
+		return
+	}
+
+	fmt.Fprintf(uploads.writer, "\033[%dA\033[J", uploads.drawn)
+	uploads.drawn = 0
+}
+
+// draw prints the uploads in flight and how many are done
+func (uploads *progress) draw() {
+	if !uploads.live || len(uploads.uploads) == 0 {
+		return
+	}
+
+	lines := []string{}
+	for _, upload := range uploads.uploads {
+		sent := atomic.LoadInt64(&upload.sent)
+		lines = append(
+			lines,
+			fmt.Sprintf("  %s  %s / %s  %s/s", upload.name, formatBytes(sent), formatBytes(upload.size), formatBytes(rate(sent, time.Since(upload.started)))),
+		)
+	}
+
+	lines = append(lines, fmt.Sprintf("%d uploaded, %d in progress", uploads.finished, len(uploads.uploads)))
+	fmt.Fprintln(uploads.writer, strings.Join(lines, "\n"))
+	uploads.drawn = len(lines)
+}
+
+// reader counts what is read from reader as sent.  Each attempt at an upload starts counting again.
+func (upload *transfer) reader(reader io.Reader) io.Reader {
+	atomic.StoreInt64(&upload.sent, 0)
+	return &countingReader{reader: reader, count: &upload.sent}
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  *int64
+}
+
+func (counting *countingReader) Read(buffer []byte) (int, error) {
+	read, err := counting.reader.Read(buffer)
+	atomic.AddInt64(counting.count, int64(read))
+	return read, err
+}
+
+// rate is how many bytes were sent per second
+func rate(bytes int64, elapsed time.Duration) int64 {
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return int64(float64(bytes) / elapsed.Seconds())
+}
+
+// formatBytes prints a size with a decimal unit, e.g. 1.5 MB
+func formatBytes(bytes int64) string {
+	if bytes < 1000 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	size := float64(bytes)
+	for _, unit := range []string{"kB", "MB", "GB"} {
+		size /= 1000
+		if size < 1000 {
+			return fmt.Sprintf("%.1f %s", size, unit)
+		}
+	}
+
+	return fmt.Sprintf("%.1f TB", size/1000)
+}
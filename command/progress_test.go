@@ -0,0 +1,69 @@
+package command_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestReleaseConcurrency(t *testing.T) {
+	uploads := make(map[string][]byte)
+	ts := getReleaseTestServerWithUploads(t, "", "", uploads)
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.Int("buildConcurrency", 1, "doc")
+	set.Int("uploadConcurrency", 3, "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	expectedRunner := &runner.Test{ExpectedCommands: getReleaseCommands(t, mainPath), AnyOrder: true}
+	app, writer, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "", errWriter.String())
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, len(testBuilds)+2, len(uploads))
+	lines := strings.Split(writer.String(), "\n")
+	for index := 0; index < len(uploads); index++ {
+		assert.True(t, strings.HasPrefix(lines[index], fmt.Sprintf("[%d] Uploaded ", index+1)), lines[index])
+	}
+
+	assert.Equal(t, fmt.Sprintf("[%d] Uploaded projectName_tag_checksums.txt", len(uploads)-1), strings.Split(lines[len(uploads)-2], " (")[0])
+	assert.Equal(t, fmt.Sprintf("[%d] Uploaded digests.json", len(uploads)), strings.Split(lines[len(uploads)-1], " (")[0])
+}
+
+func TestReleaseInvalidConcurrency(t *testing.T) {
+	for _, name := range []string{"buildConcurrency", "uploadConcurrency"} {
+		set := flag.NewFlagSet("test", 0)
+		set.String("token", "fakeToken", "doc")
+		set.Int(name, -1, "doc")
+		assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+		app, _, _ := appWithTestWriters()
+		err := command.CmdRelease(&runner.Test{})(cli.NewContext(app, set, nil))
+		assert.EqualError(t, err, fmt.Sprintf("--%s must be at least 1", name))
+	}
+}
+
+func TestPublishInvalidConcurrency(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeTestFile(t, fmt.Sprintf("%s/dist/artifacts.json", mainPath), `{"owner": "owner", "repo": "repo", "artifacts": [{"path": "projectName.tar.gz", "type": "archive"}]}`, 0644)
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.Int("uploadConcurrency", -2, "doc")
+	assert.Nil(t, set.Parse([]string{}))
+	app, _, _ := appWithTestWriters()
+	err := command.CmdPublish(&runner.Test{})(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "--uploadConcurrency must be at least 1")
+}
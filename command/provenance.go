@@ -0,0 +1,425 @@
+package command
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"time"
+)
+
+// Provenance is an in-toto statement (https://in-toto.io/Statement/v1) with an SLSA provenance predicate
+// (https://slsa.dev/provenance/v1), wrapped in a DSSE envelope (https://github.com/secure-systems-lab/dsse) and signed
+// with an Ed25519 or ECDSA P-256 key in PEM format.
+
+const (
+	defaultProvenanceKeyEnv = "GO_RELEASE_PROVENANCE_KEY"
+	inTotoStatementType     = "https://in-toto.io/Statement/v1"
+	inTotoPayloadType       = "application/vnd.in-toto+json"
+	slsaProvenanceType      = "https://slsa.dev/provenance/v1"
+	provenanceBuildType     = "https://github.com/guywithnose/goRelease/go-build@v1"
+	provenanceBuilderID     = "https://github.com/guywithnose/goRelease"
+)
+
+var errProvenanceSignature = errors.New("signature does not match the public key")
+
+type provenanceConfig struct {
+	Key       string `yaml:"key"`
+	KeyEnv    string `yaml:"keyEnv"`
+	PublicKey string `yaml:"publicKey"`
+}
+
+// enabled is true when there is a key file or the key environment variable is set
+func (cfg provenanceConfig) enabled() bool {
+	return cfg.Key != "" || os.Getenv(stringOption(cfg.KeyEnv, defaultProvenanceKeyEnv)) != ""
+}
+
+type provenancePublicKey struct {
+	key   crypto.PublicKey
+	keyID string
+}
+
+type provenancePrivateKey struct {
+	provenancePublicKey
+	key crypto.Signer
+}
+
+type dsseEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"`
+	Signatures  []dsseSignature `json:"signatures"`
+}
+
+type dsseSignature struct {
+	KeyID     string `json:"keyid"`
+	Signature string `json:"sig"`
+}
+
+type inTotoStatement struct {
+	Type          string              `json:"_type"`
+	Subject       []inTotoSubject     `json:"subject"`
+	PredicateType string              `json:"predicateType"`
+	Predicate     slsaProvenanceModel `json:"predicate"`
+}
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type slsaProvenanceModel struct {
+	BuildDefinition slsaBuildDefinition `json:"buildDefinition"`
+	RunDetails      slsaRunDetails      `json:"runDetails"`
+}
+
+type slsaBuildDefinition struct {
+	BuildType            string                   `json:"buildType"`
+	ExternalParameters   slsaExternalParameters   `json:"externalParameters"`
+	InternalParameters   slsaInternalParameters   `json:"internalParameters"`
+	ResolvedDependencies []slsaResourceDescriptor `json:"resolvedDependencies"`
+}
+
+type slsaExternalParameters struct {
+	Repository string `json:"repository"`
+	Ref        string `json:"ref"`
+	Target     string `json:"target"`
+}
+
+type slsaInternalParameters struct {
+	Command     []string `json:"command"`
+	Environment []string `json:"environment"`
+	GoVersion   string   `json:"goVersion"`
+}
+
+type slsaResourceDescriptor struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest"`
+}
+
+type slsaRunDetails struct {
+	Builder  slsaBuilder  `json:"builder"`
+	Metadata slsaMetadata `json:"metadata"`
+}
+
+type slsaBuilder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version"`
+}
+
+type slsaMetadata struct {
+	StartedOn  string `json:"startedOn"`
+	FinishedOn string `json:"finishedOn"`
+}
+
+// provenance writes a signed attestation (foo.tar.gz.intoto.jsonl) for each target once it is built and archived
+type provenance struct {
+	key        *provenancePrivateKey
+	repository string
+	tag        string
+	commit     string
+	goVersion  string
+}
+
+// newProvenance loads the provenance key from the key file, or from the key environment variable when there is no file.
+// Like the other signers a public key, when one is configured, must match before anything is built.
+func newProvenance(cfg provenanceConfig, data templateData, repository string) (*provenance, error) {
+	source := cfg.Key
+	keyData := []byte(os.Getenv(stringOption(cfg.KeyEnv, defaultProvenanceKeyEnv)))
+	if cfg.Key != "" {
+		var err error
+		keyData, err = ioutil.ReadFile(cfg.Key)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read provenance key: %v", err)
+		}
+	} else {
+		source = fmt.Sprintf("$%s", stringOption(cfg.KeyEnv, defaultProvenanceKeyEnv))
+	}
+
+	key, err := readProvenancePrivateKey(keyData)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load provenance key %s: %v", source, err)
+	}
+
+	if cfg.PublicKey != "" {
+		publicKey, err := loadProvenancePublicKey(cfg.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		message := []byte("goRelease signing check")
+		signature, err := key.sign(message)
+		if err == nil {
+			err = publicKey.verify(message, signature)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Provenance key %s does not match public key %s: %v", source, cfg.PublicKey, err)
+		}
+	}
+
+	return &provenance{
+		key:        key,
+		repository: repository,
+		tag:        data.Tag,
+		commit:     data.Commit,
+		goVersion:  data.GoVersion,
+	}, nil
+}
+
+func loadProvenancePublicKey(fileName string) (*provenancePublicKey, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read provenance public key: %v", err)
+	}
+
+	publicKey, err := readProvenancePublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load provenance public key %s: %v", fileName, err)
+	}
+
+	return publicKey, nil
+}
+
+// repositoryURL is the web address of the repository, which is on the same host as the API for GitHub Enterprise
+func repositoryURL(apiURL, owner, repo string) string {
+	parsed, err := url.Parse(apiURL)
+	if apiURL == "" || err != nil || parsed.Host == "" || parsed.Host == "api.github.com" {
+		return fmt.Sprintf("https://github.com/%s/%s", owner, repo)
+	}
+
+	return fmt.Sprintf("%s://%s/%s/%s", parsed.Scheme, parsed.Host, owner, repo)
+}
+
+// attest writes the attestation for a target.  The subjects are the binary (under the name it has in the archive),
+// the archive and any SBOMs, so the attestation can be checked against an extracted binary as well as the assets.
+func (p *provenance) attest(target buildTarget, sboms []string) (string, error) {
+	statement := inTotoStatement{
+		Type:          inTotoStatementType,
+		PredicateType: slsaProvenanceType,
+		Predicate: slsaProvenanceModel{
+			BuildDefinition: slsaBuildDefinition{
+				BuildType: provenanceBuildType,
+				ExternalParameters: slsaExternalParameters{
+					Repository: p.repository,
+					Ref:        fmt.Sprintf("refs/tags/%s", p.tag),
+					Target:     target.String(),
+				},
+				InternalParameters: slsaInternalParameters{
+					Command:     target.Command,
+					Environment: target.Environment,
+					GoVersion:   p.goVersion,
+				},
+				ResolvedDependencies: []slsaResourceDescriptor{
+					{
+						URI:    fmt.Sprintf("git+%s@refs/tags/%s", p.repository, p.tag),
+						Digest: map[string]string{"gitCommit": p.commit},
+					},
+				},
+			},
+			RunDetails: slsaRunDetails{
+				Builder: slsaBuilder{ID: provenanceBuilderID, Version: map[string]string{Name: Version}},
+				Metadata: slsaMetadata{
+					StartedOn:  target.BuildStarted.UTC().Format(time.RFC3339),
+					FinishedOn: target.BuildFinished.UTC().Format(time.RFC3339),
+				},
+			},
+		},
+	}
+
+	subjects := append([]string{target.FileName, target.ArchivePath}, sboms...)
+	for index, fileName := range subjects {
+		digest, err := fileSHA256(fileName)
+		if err != nil {
+			return "", err
+		}
+
+		name := path.Base(fileName)
+		if index == 0 {
+			name = target.BinaryName
+		}
+
+		statement.Subject = append(statement.Subject, inTotoSubject{Name: name, Digest: map[string]string{"sha256": digest}})
+	}
+
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := p.key.sign(dssePAE(inTotoPayloadType, payload))
+	if err != nil {
+		return "", err
+	}
+
+	envelope, err := json.Marshal(dsseEnvelope{
+		PayloadType: inTotoPayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []dsseSignature{{KeyID: p.key.keyID, Signature: base64.StdEncoding.EncodeToString(signature)}},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	fileName := fmt.Sprintf("%s.intoto.jsonl", target.ArchivePath)
+	return fileName, ioutil.WriteFile(fileName, append(envelope, '\n'), 0644)
+}
+
+// dssePAE is the DSSE pre-authentication encoding, which is what is actually signed
+func dssePAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// verifyAttestation checks the signature on each envelope in an attestation file and returns the first statement
+// signed by the public key
+func verifyAttestation(data []byte, publicKey *provenancePublicKey) (*inTotoStatement, error) {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		envelope := dsseEnvelope{}
+		err := json.Unmarshal(line, &envelope)
+		if err != nil {
+			return nil, fmt.Errorf("invalid envelope: %v", err)
+		}
+
+		if envelope.PayloadType != inTotoPayloadType {
+			continue
+		}
+
+		payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload: %v", err)
+		}
+
+		for _, signature := range envelope.Signatures {
+			decoded, err := base64.StdEncoding.DecodeString(signature.Signature)
+			if err != nil || publicKey.verify(dssePAE(envelope.PayloadType, payload), decoded) != nil {
+				continue
+			}
+
+			statement := &inTotoStatement{}
+			err = json.Unmarshal(payload, statement)
+			if err != nil {
+				return nil, fmt.Errorf("invalid statement: %v", err)
+			}
+
+			if statement.Type != inTotoStatementType || statement.PredicateType != slsaProvenanceType {
+				return nil, fmt.Errorf("unexpected statement type %s with predicate %s", statement.Type, statement.PredicateType)
+			}
+
+			return statement, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no statement is signed by key %s", publicKey.keyID)
+}
+
+func readProvenancePrivateKey(data []byte) (*provenancePrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM private key found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM type %s", block.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	publicKey, err := newProvenancePublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &provenancePrivateKey{provenancePublicKey: *publicKey, key: signer}, nil
+}
+
+func readProvenancePublicKey(data []byte) (*provenancePublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("no PEM public key found")
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return newProvenancePublicKey(parsed)
+}
+
+// newProvenancePublicKey checks the key type and identifies the key by the SHA-256 of its DER encoding
+func newProvenancePublicKey(key crypto.PublicKey) (*provenancePublicKey, error) {
+	switch typed := key.(type) {
+	case ed25519.PublicKey:
+	case *ecdsa.PublicKey:
+		if typed.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported curve %s", typed.Curve.Params().Name)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	keyID := sha256.Sum256(der)
+	return &provenancePublicKey{key: key, keyID: hex.EncodeToString(keyID[:])}, nil
+}
+
+func (key *provenancePrivateKey) sign(message []byte) ([]byte, error) {
+	if _, ok := key.key.(ed25519.PrivateKey); ok {
+		return key.key.Sign(rand.Reader, message, crypto.Hash(0))
+	}
+
+	digest := sha256.Sum256(message)
+	return key.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+func (key *provenancePublicKey) verify(message, signature []byte) error {
+	valid := false
+	switch typed := key.key.(type) {
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(typed, message, signature)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		valid = ecdsa.VerifyASN1(typed, digest[:], signature)
+	}
+
+	if !valid {
+		return errProvenanceSignature
+	}
+
+	return nil
+}
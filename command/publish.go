@@ -0,0 +1,137 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/guywithnose/runner"
+	"github.com/urfave/cli"
+)
+
+// CmdPublish uploads everything that was packaged into the dist directory to the github release
+func CmdPublish(cmdWrapper runner.Builder) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		return cmdPublishHelper(c, cmdWrapper)
+	}
+}
+
+func cmdPublishHelper(c *cli.Context, cmdWrapper runner.Builder) error {
+	token := c.String("token")
+	if token == "" {
+		return cli.NewExitError("You must specify a token", 1)
+	}
+
+	if c.NArg() != 0 {
+		return cli.NewExitError("Usage: \"goRelease publish --token {token} --dist {dist}\"", 1)
+	}
+
+	mainPath, err := getMainPath(c)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(c.String("config"), mainPath)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	dist := distDirectory(c, mainPath)
+	manifest, err := readManifest(dist)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	assets := []artifact{}
+	for _, asset := range manifest.Artifacts {
+		if asset.Type != artifactBinary {
+			assets = append(assets, asset)
+		}
+	}
+
+	if len(assets) == 0 {
+		return cli.NewExitError(fmt.Sprintf("There is nothing to publish in %s, run goRelease package first", manifest.dist), 1)
+	}
+
+	onConflict, err := conflictOption(c, cfg)
+	if err != nil {
+		return err
+	}
+
+	uploadConcurrency, err := concurrencyOption(c, "uploadConcurrency", defaultUploadConcurrency)
+	if err != nil {
+		return err
+	}
+
+	allowPartial, requiredTargets := partialOptions(c, cfg)
+	err = checkRequiredTargets(requiredTargets, manifest.Targets)
+	if err != nil {
+		return err
+	}
+
+	err = verifyArtifacts(assets)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	apiURL := stringOption(c.String("apiUrl"), cfg.Release.APIURL)
+	client, err := getGithubClient(&token, &apiURL, newRetrier(cfg.Retry, c.Int("maxAttempts"), c.App.ErrWriter))
+	if err != nil {
+		return err
+	}
+
+	if !c.Bool("skipVersionCheck") && !cfg.Release.SkipVersionCheck {
+		err = checkVersion(client, manifest.Owner, manifest.Repo, manifest.Data.Tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = checkTag(cmdWrapper, mainPath, manifest.Data.Tag, manifest.Data.Commit, false, c.App.Writer)
+	if err != nil {
+		return err
+	}
+
+	notes := releaseNotesFor(cmdWrapper, cfg, mainPath, manifest.Data.Project, manifest.Data.Tag, repositoryURL(apiURL, manifest.Owner, manifest.Repo))
+	settings, err := newReleaseSettings(c, cmdWrapper, cfg, mainPath, manifest.Data, c.Bool("publish") || cfg.Release.Publish, notes)
+	if err != nil {
+		return err
+	}
+
+	releaseResponse, err := getRelease(client, manifest.Owner, manifest.Repo, settings)
+	if err != nil {
+		return err
+	}
+
+	release := &githubRelease{client: client, owner: manifest.Owner, repo: manifest.Repo, id: releaseResponse.GetID(), onConflict: onConflict}
+	err = release.loadExistingAssets()
+	if err != nil {
+		return err
+	}
+
+	err = release.loadDigests(!c.Bool("uploadUnchanged"), c.App.ErrWriter)
+	if err != nil {
+		return err
+	}
+
+	release.progress = newProgress(c.App.Writer)
+
+	results := newReleaseResults(c.App.ErrWriter, manifest.Targets)
+	publisher := &uploader{destination: release, keep: true, results: results, stage: stageUpload}
+	queued := make(chan artifact, len(assets))
+	for _, asset := range assets {
+		queued <- asset
+	}
+
+	close(queued)
+	forEachAsset(queued, uploadConcurrency, func(asset artifact) {
+		publisher.uploadFile(asset)
+	})
+	err = release.uploadDigests(manifest.dist)
+	if err != nil {
+		results.failed(checksumsTarget, stageUpload, "Unable to upload %s: %v\n", digestManifestName, err)
+	}
+
+	release.progress.stop()
+
+	results.writeSummary(c.App.Writer)
+	return results.err(allowPartial, requiredTargets)
+}
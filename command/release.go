@@ -3,13 +3,16 @@ package command
 import (
 	"context"
 	"fmt"
-	"io"
+	"mime"
 	"net/url"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/guywithnose/runner"
@@ -26,83 +29,406 @@ func CmdRelease(cmdWrapper runner.Builder) func(c *cli.Context) error {
 
 func cmdReleaseHelper(c *cli.Context, cmdWrapper runner.Builder) error {
 	token := c.String("token")
-	apiURL := c.String("apiUrl")
-	publish := c.Bool("publish")
-	removeOldAssets := c.Bool("removeOldAssets")
-	_ = c.StringSlice("os")
-	mainPath := c.String("mainPath")
 	if token == "" {
 		return cli.NewExitError("You must specify a token", 1)
 	}
 
-	if c.NArg() != 4 {
-		return cli.NewExitError("Usage: \"goRelease {owner} {repo} {tagName} {projectName} --token {token} --apiUrl {apiUrl}\"", 1)
+	mainPath, err := getMainPath(c)
+	if err != nil {
+		return err
 	}
 
-	var err error
-	if mainPath == "" {
-		mainPath, err = os.Getwd()
+	cfg, err := loadConfig(c.String("config"), mainPath)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	owner, repo, tagName, projectName, err := releaseArguments(
+		c,
+		cmdWrapper,
+		cfg,
+		mainPath,
+		"Usage: \"goRelease {owner} {repo} {tagName} {projectName} --token {token} --apiUrl {apiUrl}\"",
+	)
+	if err != nil {
+		return err
+	}
+
+	apiURL := stringOption(c.String("apiUrl"), cfg.Release.APIURL)
+	publish := c.Bool("publish") || cfg.Release.Publish
+	planFormat := stringOption(c.String("planFormat"), "text")
+	if !contains(planFormats, planFormat) {
+		return cli.NewExitError(fmt.Sprintf("Unknown plan format %s (expected one of %s)", planFormat, strings.Join(planFormats, ", ")), 1)
+	}
+
+	onConflict, err := conflictOption(c, cfg)
+	if err != nil {
+		return err
+	}
+
+	buildConcurrency, err := concurrencyOption(c, "buildConcurrency", defaultBuildConcurrency)
+	if err != nil {
+		return err
+	}
+
+	uploadConcurrency, err := concurrencyOption(c, "uploadConcurrency", defaultUploadConcurrency)
+	if err != nil {
+		return err
+	}
+
+	dist := distDirectory(c, mainPath)
+	data, targets, err := prepareBuilds(c, cmdWrapper, cfg, mainPath, projectName, tagName, dist)
+	if err != nil {
+		return err
+	}
+
+	allowPartial, requiredTargets := partialOptions(c, cfg)
+	err = checkRequiredTargets(requiredTargets, targets)
+	if err != nil {
+		return err
+	}
+
+	sums, signers, attestor, err := preparePackaging(c, cfg, data, mainPath, dist, repositoryURL(apiURL, owner, repo))
+	if err != nil {
+		return err
+	}
+
+	client, err := getGithubClient(&token, &apiURL, newRetrier(cfg.Retry, c.Int("maxAttempts"), c.App.ErrWriter))
+	if err != nil {
+		return err
+	}
+
+	if !c.Bool("skipVersionCheck") && !cfg.Release.SkipVersionCheck {
+		err = checkVersion(client, owner, repo, tagName)
 		if err != nil {
-			return fmt.Errorf("Unable to get current working directory: %v", err)
+			return err
 		}
 	}
 
-	owner := c.Args().Get(0)
-	repo := c.Args().Get(1)
-	tagName := c.Args().Get(2)
-	projectName := c.Args().Get(3)
-	client, err := getGithubClient(&token, &apiURL)
+	err = checkWorkingTree(cmdWrapper, mainPath, dist, tagName, c.Bool("allowDirty") || cfg.Release.AllowDirty, c.App.ErrWriter)
 	if err != nil {
 		return err
 	}
 
-	releaseResponse, err := getRelease(client, owner, repo, tagName, publish)
+	tagCommands, err := checkTag(cmdWrapper, mainPath, tagName, "", c.Bool("dryRun"), c.App.Writer)
 	if err != nil {
 		return err
 	}
 
-	id := releaseResponse.GetID()
-
-	binaries, err := buildBinaries(cmdWrapper, mainPath, projectName, tagName, c.App.ErrWriter)
+	release := &githubRelease{client: client, owner: owner, repo: repo, onConflict: onConflict}
+	manifest := newManifest(owner, repo, data, dist)
+	keep := c.Bool("keep")
+	assetUploader := &uploader{
+		destination: &recordedDestination{destination: release, manifest: manifest},
+		keep:        keep,
+		sums:        sums,
+		signers:     signers,
+		results:     newReleaseResults(c.App.ErrWriter, targets),
+		stage:       stageUpload,
+		concurrency: uploadConcurrency,
+	}
+	notes := releaseNotesFor(cmdWrapper, cfg, mainPath, projectName, tagName, repositoryURL(apiURL, owner, repo))
+	settings, err := newReleaseSettings(c, cmdWrapper, cfg, mainPath, data, publish, notes)
 	if err != nil {
 		return err
 	}
 
-	if removeOldAssets {
-		err = clearAssets(client, id, owner, repo)
+	if c.Bool("dryRun") {
+		plan, err := planRelease(release, assetUploader, tagCommands, targets, settings, attestor != nil)
 		if err != nil {
 			return err
 		}
+
+		return writePlan(c.App.Writer, plan, planFormat)
+	}
+
+	releaseResponse, err := getRelease(client, owner, repo, settings)
+	if err != nil {
+		return err
+	}
+
+	release.id = releaseResponse.GetID()
+	err = release.loadExistingAssets()
+	if err != nil {
+		return err
+	}
+
+	err = release.loadDigests(!c.Bool("uploadUnchanged"), c.App.ErrWriter)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dist, 0755)
+	if err != nil {
+		return fmt.Errorf("Unable to create %s: %v", dist, err)
+	}
+
+	release.progress = newProgress(c.App.Writer)
+	binaries := forEachTarget(targets, buildConcurrency, func(target buildTarget) []artifact {
+		if !runBuild(cmdWrapper, &target, mainPath, assetUploader.results) {
+			return nil
+		}
+
+		err := manifest.addBinary(target)
+		if err != nil {
+			assetUploader.results.failed(target.String(), stageBuild, "Unable to record binary %s: %v\n", target.FileName, err)
+		}
+
+		if !keep {
+			defer func() {
+				_ = os.Remove(target.FileName)
+			}()
+		}
+
+		return packageTarget(target, attestor, assetUploader.results)
+	})
+
+	assetUploader.uploadBinaries(binaries)
+	err = release.uploadDigests(dist)
+	if err != nil {
+		assetUploader.results.failed(checksumsTarget, stageUpload, "Unable to upload %s: %v\n", digestManifestName, err)
+	}
+
+	release.progress.stop()
+	if !keep {
+		// The target folders are empty once everything is uploaded, this only removes the ones that are so nothing that
+		// was left behind is lost
+		for _, target := range targets {
+			_ = os.Remove(filepath.Dir(target.FileName))
+		}
+	}
+
+	err = manifest.write()
+	if err != nil {
+		return fmt.Errorf("Unable to write manifest: %v", err)
+	}
+
+	assetUploader.results.writeSummary(c.App.Writer)
+	return assetUploader.results.err(allowPartial, requiredTargets)
+}
+
+func getMainPath(c *cli.Context) (string, error) {
+	mainPath := c.String("mainPath")
+	if mainPath != "" {
+		return mainPath, nil
+	}
+
+	mainPath, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("Unable to get current working directory: %v", err)
+	}
+
+	return mainPath, nil
+}
+
+// distDirectory is where build, package and publish keep their files, dist in mainPath by default
+func distDirectory(c *cli.Context, mainPath string) string {
+	return stringOption(c.String("dist"), filepath.Join(mainPath, "dist"))
+}
+
+// How many builds and uploads run at once unless the flags say otherwise
+var (
+	defaultBuildConcurrency  = runtime.NumCPU()
+	defaultUploadConcurrency = 4
+)
+
+// concurrencyOption reads how many builds or uploads can run at once from a flag
+func concurrencyOption(c *cli.Context, name string, defaultValue int) (int, error) {
+	concurrency := c.Int(name)
+	if concurrency < 0 {
+		return 0, cli.NewExitError(fmt.Sprintf("--%s must be at least 1", name), 1)
+	}
+
+	if concurrency == 0 {
+		return defaultValue, nil
+	}
+
+	return concurrency, nil
+}
+
+// prepareBuilds applies the build flags to the config and plans a build for each target, writing the binaries and
+// archives to outputDir
+func prepareBuilds(
+	c *cli.Context,
+	cmdWrapper runner.Builder,
+	cfg *config,
+	mainPath,
+	projectName,
+	tagName,
+	outputDir string,
+) (templateData, []buildTarget, error) {
+	goExecutable, err := exec.LookPath("go")
+	if err != nil {
+		return templateData{}, nil, err
+	}
+
+	builds, err := discoverBuilds(cmdWrapper, mainPath, goExecutable, c.Bool("firstClassOnly") || cfg.Builds.FirstClassOnly)
+	if err != nil {
+		return templateData{}, nil, err
+	}
+
+	builds, err = filterBuilds(
+		cfg.applyArchiveFormats(builds),
+		sliceOption(c.StringSlice("os"), cfg.Builds.OperatingSystems),
+		sliceOption(c.StringSlice("arch"), cfg.Builds.Architectures),
+		sliceOption(c.StringSlice("target"), cfg.Builds.Targets),
+	)
+	if err != nil {
+		return templateData{}, nil, cli.NewExitError(err.Error(), 1)
+	}
+
+	cfg.Builds.Ldflags = stringOption(c.String("ldflags"), cfg.Builds.Ldflags)
+	cfg.Builds.Gcflags = stringOption(c.String("gcflags"), cfg.Builds.Gcflags)
+	cfg.Builds.Buildmode = stringOption(c.String("buildmode"), cfg.Builds.Buildmode)
+	cfg.Builds.Tags = sliceOption(c.StringSlice("tags"), cfg.Builds.Tags)
+	cfg.Builds.Trimpath = c.Bool("trimpath") || cfg.Builds.Trimpath
+	cfg.Builds.Reproducible = c.Bool("reproducible") || cfg.Builds.Reproducible
+	err = applySBOMFlags(c, cfg)
+	if err != nil {
+		return templateData{}, nil, err
+	}
+
+	applyProvenanceFlags(c, cfg, mainPath)
+	data, err := getTemplateData(cmdWrapper, cfg, mainPath, goExecutable, projectName, tagName)
+	if err != nil {
+		return templateData{}, nil, err
+	}
+
+	targets, err := planBuilds(builds, cfg, data, goExecutable, mainPath, outputDir)
+	if err != nil {
+		return templateData{}, nil, cli.NewExitError(err.Error(), 1)
+	}
+
+	return data, targets, nil
+}
+
+func applySBOMFlags(c *cli.Context, cfg *config) error {
+	cfg.SBOM.Formats = sliceOption(c.StringSlice("sbom"), cfg.SBOM.Formats)
+	for _, format := range cfg.SBOM.Formats {
+		if !contains(sbomFormats, format) {
+			return cli.NewExitError(fmt.Sprintf("Unknown SBOM format %s (expected one of %s)", format, strings.Join(sbomFormats, ", ")), 1)
+		}
 	}
 
-	uploadBinaries(client, owner, repo, id, binaries, c.App.ErrWriter)
 	return nil
 }
 
-func clearAssets(client *github.Client, id int, owner, repo string) error {
-	assets, err := getAssets(client, id, owner, repo)
+func applyProvenanceFlags(c *cli.Context, cfg *config, mainPath string) {
+	cfg.Provenance.Key = stringOption(c.String("provenanceKey"), pathRelativeTo(mainPath, cfg.Provenance.Key))
+	cfg.Provenance.PublicKey = stringOption(c.String("provenancePublicKey"), pathRelativeTo(mainPath, cfg.Provenance.PublicKey))
+}
+
+// preparePackaging loads the checksum settings, signing keys and provenance key so that any problem with them stops
+// the release before anything is built
+func preparePackaging(
+	c *cli.Context,
+	cfg *config,
+	data templateData,
+	mainPath,
+	outputDir,
+	repository string,
+) (*checksums, []assetSigner, *provenance, error) {
+	var sums *checksums
+	var err error
+	if !cfg.Checksums.Disable {
+		cfg.Checksums.Algorithm = stringOption(c.String("checksumAlgorithm"), cfg.Checksums.Algorithm)
+		cfg.Checksums.OnlyFor = sliceOption(c.StringSlice("checksumsOnlyFor"), cfg.Checksums.OnlyFor)
+		cfg.Checksums.Sidecars = c.Bool("checksumSidecars") || cfg.Checksums.Sidecars
+		sums, err = newChecksums(cfg.Checksums, data, outputDir)
+		if err != nil {
+			return nil, nil, nil, cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	signers, err := getSigners(c, cfg, mainPath, data.Tag, sums != nil)
 	if err != nil {
-		return err
+		return nil, nil, nil, cli.NewExitError(err.Error(), 1)
 	}
 
-	for _, asset := range assets {
-		_, err = client.Repositories.DeleteReleaseAsset(context.Background(), owner, repo, asset.GetID())
+	var attestor *provenance
+	if cfg.Provenance.enabled() {
+		attestor, err = newProvenance(cfg.Provenance, data, repository)
 		if err != nil {
-			return err
+			return nil, nil, nil, cli.NewExitError(err.Error(), 1)
 		}
 	}
 
-	return err
+	return sums, signers, attestor, nil
+}
+
+// getSigners loads the OpenPGP and minisign keys that are given on the command line or in the config
+func getSigners(c *cli.Context, cfg *config, mainPath, tagName string, checksumsEnabled bool) ([]assetSigner, error) {
+	cfg.Signing.Key = stringOption(c.String("signingKey"), pathRelativeTo(mainPath, cfg.Signing.Key))
+	cfg.Signing.PublicKey = stringOption(c.String("signingPublicKey"), pathRelativeTo(mainPath, cfg.Signing.PublicKey))
+	cfg.Signing.Artifacts = stringOption(c.String("signArtifacts"), cfg.Signing.Artifacts)
+	cfg.Minisign.Key = stringOption(c.String("minisignKey"), pathRelativeTo(mainPath, cfg.Minisign.Key))
+	cfg.Minisign.PublicKey = stringOption(c.String("minisignPublicKey"), pathRelativeTo(mainPath, cfg.Minisign.PublicKey))
+	cfg.Minisign.Artifacts = stringOption(c.String("minisignArtifacts"), cfg.Minisign.Artifacts)
+
+	signers := []assetSigner{}
+	if cfg.Signing.Key != "" {
+		signer, err := newPgpSigner(cfg.Signing)
+		if err != nil {
+			return nil, err
+		}
+
+		signers = append(signers, signer)
+	}
+
+	if cfg.Minisign.Key != "" || os.Getenv(stringOption(cfg.Minisign.KeyEnv, defaultMinisignKeyEnv)) != "" {
+		signer, err := newMinisignSigner(cfg.Minisign, tagName)
+		if err != nil {
+			return nil, err
+		}
+
+		signers = append(signers, signer)
+	}
+
+	for _, signer := range signers {
+		if !signer.signsEveryAsset() && !checksumsEnabled {
+			return nil, fmt.Errorf("Signing only the checksums file requires checksums, sign every asset to sign without checksums")
+		}
+	}
+
+	return signers, nil
+}
+
+func stringOption(flagValue, configValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+
+	return configValue
+}
+
+func sliceOption(flagValue, configValue []string) []string {
+	if len(flagValue) != 0 {
+		return flagValue
+	}
+
+	return configValue
+}
+
+func deleteAsset(client *githubClient, owner, repo string, asset *github.ReleaseAsset) error {
+	return client.retry.do(fmt.Sprintf("deleting asset %s", asset.GetName()), func() (*github.Response, error) {
+		return client.Repositories.DeleteReleaseAsset(context.Background(), owner, repo, asset.GetID())
+	})
 }
 
-func getAssets(client *github.Client, id int, owner, repo string) ([]*github.ReleaseAsset, error) {
+func getAssets(client *githubClient, id int, owner, repo string) ([]*github.ReleaseAsset, error) {
 	opt := github.ListOptions{
 		PerPage: 100,
 	}
 
 	allAssets := make([]*github.ReleaseAsset, 0, 100)
 	for {
-		assets, resp, err := client.Repositories.ListReleaseAssets(context.Background(), owner, repo, id, &opt)
+		var assets []*github.ReleaseAsset
+		var resp *github.Response
+		err := client.retry.do("listing release assets", func() (*github.Response, error) {
+			var err error
+			assets, resp, err = client.Repositories.ListReleaseAssets(context.Background(), owner, repo, id, &opt)
+			return resp, err
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -117,143 +443,436 @@ func getAssets(client *github.Client, id int, owner, repo string) ([]*github.Rel
 	}
 }
 
-func uploadBinaries(client *github.Client, owner, repo string, id int, binaries <-chan string, errWriter io.Writer) {
-	for fileName := range binaries {
-		err := uploadToRelease(client, id, owner, repo, fileName)
+// assetDestination is where the uploader puts each finished asset: a github release, or the manifest of a dist directory
+type assetDestination interface {
+	upload(asset artifact) error
+}
+
+// githubRelease uploads assets to a release.  Assets that are on the release with the same digest are not uploaded
+// again.  An asset with the same name as one in existing is handled according to onConflict, everything else on the
+// release is left alone.
+type githubRelease struct {
+	client     *githubClient
+	owner      string
+	repo       string
+	id         int
+	progress   *progress
+	onConflict string
+	existing   map[string]*github.ReleaseAsset
+	digests    *releaseDigests
+}
+
+func (release *githubRelease) upload(asset artifact) error {
+	name := path.Base(asset.Path)
+	sum, err := fileSHA256(asset.Path)
+	if err != nil {
+		return err
+	}
+
+	digest := fmt.Sprintf("sha256:%s", sum)
+	if release.unchanged(name, digest) {
+		release.progress.skip(name, "it has not changed")
+		release.recordDigest(name, digest)
+		return nil
+	}
+
+	upload, err := release.resolveConflict(name, digest)
+	if err != nil || !upload {
+		return err
+	}
+
+	err = uploadToRelease(release.client, release.id, release.owner, release.repo, asset.Path, release.progress)
+	if err != nil {
+		return err
+	}
+
+	release.recordDigest(name, digest)
+	return nil
+}
+
+// uploader uploads the assets for a release.  When sums is not nil the checksum of every uploaded asset is recorded
+// and the checksums file is uploaded once all of the assets are.  Each of the signers signs the checksums file, or
+// every asset, and the signatures are uploaded next to the files they sign.  Files are removed once they are uploaded
+// unless keep is set.  Failures are recorded in results as part of stage.  Up to concurrency assets are uploaded at
+// once.
+type uploader struct {
+	destination assetDestination
+	keep        bool
+	sums        *checksums
+	signers     []assetSigner
+	results     *releaseResults
+	stage       string
+	concurrency int
+}
+
+func (u *uploader) uploadBinaries(binaries <-chan artifact) {
+	forEachAsset(binaries, u.concurrency, u.uploadBinary)
+	if u.sums == nil || len(u.sums.sums) == 0 {
+		return
+	}
+
+	fileName, err := u.sums.write()
+	if err != nil {
+		u.results.failed(checksumsTarget, u.stage, "Unable to write checksums %s: %v\n", fileName, err)
+		return
+	}
+
+	u.uploadSigned(artifact{Path: fileName, Type: artifactChecksums})
+}
+
+// uploadBinary uploads an asset with its signatures and checksum sidecar and records its checksum
+func (u *uploader) uploadBinary(asset artifact) {
+	sum := ""
+	if u.sums != nil && u.sums.includes(asset.Path) {
+		var err error
+		sum, err = u.sums.sum(asset.Path)
 		if err != nil {
-			fmt.Fprintf(errWriter, "Unable to upload binary %s: %v\n", fileName, err)
-		} else {
-			err := os.Remove(fileName)
-			if err != nil {
-				fmt.Fprintf(errWriter, "Unable to cleanup binary %s: %v\n", fileName, err)
-			}
+			u.results.failed(assetTarget(asset), u.stage, "Unable to checksum %s %s: %v\n", asset.Type, asset.Path, err)
+		}
+	}
+
+	if !u.uploadSigned(asset) || sum == "" {
+		return
+	}
+
+	u.sums.add(asset.Path, sum)
+	if u.sums.sidecars {
+		sidecarName, err := u.sums.writeSidecar(asset.Path, sum)
+		if err != nil {
+			u.results.failed(assetTarget(asset), u.stage, "Unable to write checksum %s: %v\n", sidecarName, err)
+			return
+		}
+
+		u.uploadFile(asset.related(sidecarName, artifactChecksum))
+	}
+}
+
+// uploadSigned signs a file with each signer that applies to it and uploads it with its signatures.  Nothing is
+// uploaded if the file cannot be signed.
+func (u *uploader) uploadSigned(asset artifact) bool {
+	signatures := []artifact{}
+	for _, signer := range u.signers {
+		if asset.Type != artifactChecksums && !signer.signsEveryAsset() {
+			continue
+		}
+
+		signatureName, err := signer.signFile(asset.Path)
+		if err != nil {
+			u.results.failed(assetTarget(asset), u.stage, "Unable to sign %s %s: %v\n", asset.Type, asset.Path, err)
+			removeArtifacts(signatures)
+			return false
 		}
+
+		signatures = append(signatures, asset.related(signatureName, artifactSignature))
+	}
+
+	if !u.uploadFile(asset) {
+		removeArtifacts(signatures)
+		return false
+	}
+
+	for _, signature := range signatures {
+		u.uploadFile(signature)
+	}
+
+	return true
+}
+
+func removeFiles(fileNames []string) {
+	for _, fileName := range fileNames {
+		_ = os.Remove(fileName)
+	}
+}
+
+func removeArtifacts(assets []artifact) {
+	for _, asset := range assets {
+		_ = os.Remove(asset.Path)
+	}
+}
+
+// uploadFile uploads a file to the destination and removes it once it is uploaded
+func (u *uploader) uploadFile(asset artifact) bool {
+	err := u.destination.upload(asset)
+	if err != nil {
+		u.results.failed(assetTarget(asset), u.stage, "Unable to upload %s %s: %v\n", asset.Type, asset.Path, err)
+		return false
+	}
+
+	u.results.succeeded(assetTarget(asset), u.stage)
+	if u.keep {
+		return true
+	}
+
+	err = os.Remove(asset.Path)
+	if err != nil {
+		fmt.Fprintf(u.results.errWriter, "Unable to cleanup %s %s: %v\n", asset.Type, asset.Path, err)
 	}
+
+	return true
 }
 
-func uploadToRelease(client *github.Client, id int, owner, repo, fileName string) error {
-	file, err := os.Open(fileName)
+// uploadToRelease uploads a file as a release asset.  A failed upload can leave a broken asset behind on github, so
+// any asset with the same name is deleted before the upload is retried.
+func uploadToRelease(client *githubClient, id int, owner, repo, fileName string, uploads *progress) error {
+	name := path.Base(fileName)
+	info, err := os.Stat(fileName)
 	if err != nil {
 		return err
 	}
 
-	_, _, err = client.Repositories.UploadReleaseAsset(context.Background(), owner, repo, id, &github.UploadOptions{Name: path.Base(fileName)}, file)
+	upload := uploads.start(name, info.Size())
+	attempted := false
+	err = client.retry.do(fmt.Sprintf("uploading %s", name), func() (*github.Response, error) {
+		if attempted {
+			// The cleanup is part of this attempt, a failure is retried along with the upload
+			err := deleteAssetNamed(client.once(), id, owner, repo, name)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		attempted = true
+		file, err := os.Open(fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		defer func() {
+			_ = file.Close()
+		}()
+
+		// The request is built here rather than with UploadReleaseAsset so that the bytes sent can be counted
+		uploadURL := fmt.Sprintf("repos/%s/%s/releases/%d/assets?%s", owner, repo, id, url.Values{"name": {name}}.Encode())
+		request, err := client.NewUploadRequest(uploadURL, upload.reader(file), info.Size(), mime.TypeByExtension(filepath.Ext(name)))
+		if err != nil {
+			return nil, err
+		}
+
+		return client.Do(context.Background(), request, new(github.ReleaseAsset))
+	})
+	uploads.finish(upload, err)
 	return err
 }
 
-func buildBinaries(cmdWrapper runner.Builder, mainPath, projectName, tagName string, errWriter io.Writer) (<-chan string, error) {
-	files := make(chan string, 10)
-	goExecutable, err := exec.LookPath("go")
+func deleteAssetNamed(client *githubClient, id int, owner, repo, name string) error {
+	assets, err := getAssets(client, id, owner, repo)
 	if err != nil {
-		return files, err
+		return err
 	}
 
-	wg := sync.WaitGroup{}
-	for _, build := range ValidBuilds {
-		for _, architecture := range build.Architectures {
-			wg.Add(1)
-			go func(build osBuildInfo, architecture string) {
-				defer wg.Done()
-				cmd := cmdWrapper.New(mainPath, goExecutable, "version")
-				versionInfo, _ := cmd.CombinedOutput()
-				goVersion := "UNKNOWN"
-				versionParts := strings.Split(string(versionInfo), " ")
-				if len(versionParts) >= 3 {
-					goVersion = strings.Split(string(versionInfo), " ")[2]
-				}
-
-				fileName := fmt.Sprintf("%s/%s-%s-%s-%s-%s%s", mainPath, projectName, build.OperatingSystem, architecture, goVersion, tagName, build.Extension)
-				environment := []string{
-					fmt.Sprintf("GOOS=%s", build.OperatingSystem),
-					fmt.Sprintf("GOARCH=%s", architecture),
-					fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH")),
-				}
-				cmd = cmdWrapper.NewWithEnvironment(mainPath, environment, goExecutable, "build", "-o", fileName)
-				output, err := cmd.CombinedOutput()
-				if err != nil {
-					fmt.Fprintf(errWriter, "Could not run build for %s/%s: %v\nOutput: %s\n", build.OperatingSystem, architecture, err, output)
-				} else {
-					_, err := exec.LookPath(build.CompressBinary)
-					if err != nil {
-						fmt.Fprintf(errWriter, "Could not compress binary for %s/%s: %v\n", build.OperatingSystem, architecture, err)
-						files <- fileName
-					}
-
-					compressedBinary := fmt.Sprintf("%s%s", fileName, build.CompressExtension)
-					var command []string
-					if build.IncludeTargetParameter {
-						command = []string{build.CompressBinary, compressedBinary, fileName}
-					} else {
-						command = []string{build.CompressBinary, fileName}
-					}
-
-					cmd := cmdWrapper.New(mainPath, command...)
-					output, err := cmd.CombinedOutput()
-					if err != nil {
-						fmt.Fprintf(errWriter, "Could not compress binary for %s/%s: %v\nOutput: %s\n", build.OperatingSystem, architecture, err, output)
-						files <- fileName
-					} else {
-						files <- compressedBinary
-						_ = os.Remove(fileName)
-					}
-				}
-			}(build, architecture)
+	for _, asset := range assets {
+		if asset.GetName() == name {
+			return deleteAsset(client, owner, repo, asset)
 		}
 	}
 
+	return nil
+}
+
+// forEachTarget runs process for up to concurrency targets at once and passes on the artifacts for each target as it
+// finishes
+func forEachTarget(targets []buildTarget, concurrency int, process func(target buildTarget) []artifact) <-chan artifact {
+	files := make(chan artifact, 10)
 	go func() {
-		wg.Wait()
+		eachTarget(targets, concurrency, process, func(asset artifact) {
+			files <- asset
+		})
 		close(files)
 	}()
 
-	return files, nil
+	return files
 }
 
-func getRelease(client *github.Client, owner, repo, tagName string, publish bool) (*github.RepositoryRelease, error) {
-	draft := !publish
-	releases, err := getReleases(client, owner, repo)
-	if err != nil {
-		return nil, err
+// eachTarget runs process for up to concurrency targets at once and returns once every target is finished.  The
+// artifacts for each target are passed to send, if there is one, after the next target has been started.
+func eachTarget(targets []buildTarget, concurrency int, process func(target buildTarget) []artifact, send func(asset artifact)) {
+	wg := sync.WaitGroup{}
+	slots := make(chan struct{}, concurrency)
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target buildTarget) {
+			defer wg.Done()
+			slots <- struct{}{}
+			assets := process(target)
+			<-slots
+			if send == nil {
+				return
+			}
+
+			for _, asset := range assets {
+				send(asset)
+			}
+		}(target)
 	}
 
-	for _, release := range releases {
-		if release.GetTagName() == tagName {
-			if release.GetDraft() && publish {
-				releasePatch := github.RepositoryRelease{
-					Draft: &draft,
-				}
-
-				_, _, err = client.Repositories.EditRelease(context.Background(), owner, repo, release.GetID(), &releasePatch)
-				if err != nil {
-					return nil, err
-				}
+	wg.Wait()
+}
+
+// forEachAsset runs process for every asset with concurrency workers and returns once they are all processed
+func forEachAsset(assets <-chan artifact, concurrency int, process func(asset artifact)) {
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for asset := range assets {
+				process(asset)
 			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// runBuild builds the binary for a target into its folder and records when the build ran
+func runBuild(cmdWrapper runner.Builder, target *buildTarget, mainPath string, results *releaseResults) bool {
+	err := os.MkdirAll(filepath.Dir(target.FileName), 0755)
+	if err != nil {
+		results.failed(target.String(), stageBuild, "Could not create the output folder for %s: %v\n", target, err)
+		return false
+	}
+
+	target.BuildStarted = time.Now().UTC()
+	cmd := cmdWrapper.NewWithEnvironment(mainPath, target.Environment, target.Command...)
+	output, err := cmd.CombinedOutput()
+	target.BuildFinished = time.Now().UTC()
+	if err != nil {
+		results.failed(target.String(), stageBuild, "Could not run build for %s: %v\nOutput: %s\n", target, err, output)
+		return false
+	}
+
+	results.succeeded(target.String(), stageBuild)
+	return true
+}
+
+// packageTarget archives a built binary and returns the assets to upload for it.  A failed SBOM or attestation fails
+// the package stage but the archive is still uploaded.
+func packageTarget(target buildTarget, attestor *provenance, results *releaseResults) []artifact {
+	sboms := []string{}
+	for _, sbom := range target.SBOMs {
+		sboms = append(sboms, sbom.FileName)
+	}
+
+	err := writeSBOMs(target)
+	if err != nil {
+		results.failed(target.String(), stagePackage, "Could not create SBOM for %s: %v\n", target, err)
+		removeFiles(sboms)
+		sboms = nil
+	}
+
+	err = writeArchive(target.ArchiveFormat, target.ArchivePath, target.Directory, target.archiveFiles(), target.Data.archiveModTime())
+	if err != nil {
+		results.failed(target.String(), stagePackage, "Could not archive binary for %s: %v\n", target, err)
+		removeFiles(sboms)
+		return nil
+	}
 
+	archive := artifact{Path: target.ArchivePath, OS: target.OperatingSystem, Arch: target.Architecture, Type: artifactArchive}
+	assets := []artifact{archive}
+	for _, sbom := range sboms {
+		assets = append(assets, archive.related(sbom, artifactSBOM))
+	}
+
+	if attestor != nil {
+		attestation, err := attestor.attest(target, sboms)
+		if err != nil {
+			results.failed(target.String(), stagePackage, "Could not create provenance for %s: %v\n", target, err)
+			_ = os.Remove(attestation)
+			return assets
+		}
+
+		assets = append(assets, archive.related(attestation, artifactProvenance))
+	}
+
+	results.succeeded(target.String(), stagePackage)
+	return assets
+}
+
+// getRelease finds the release for a tag and applies the settings to it, publishing it if it is a draft and publish is
+// set, or creates it
+func getRelease(client *githubClient, owner, repo string, settings releaseSettings) (*github.RepositoryRelease, error) {
+	release, err := findRelease(client, owner, repo, settings.tagName)
+	if err != nil {
+		return nil, err
+	}
+
+	if release != nil {
+		releasePatch, changed := settings.patch(release)
+		if len(changed) == 0 {
 			return release, nil
 		}
+
+		err = client.retry.do(fmt.Sprintf("updating release %s", settings.tagName), func() (*github.Response, error) {
+			_, resp, err := client.Repositories.EditRelease(context.Background(), owner, repo, release.GetID(), releasePatch)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return release, nil
+	}
+
+	newRelease, err := settings.newRelease()
+	if err != nil {
+		return nil, err
 	}
 
-	release := github.RepositoryRelease{
-		TagName: &tagName,
-		Draft:   &draft,
+	var createdRelease *github.RepositoryRelease
+	attempts := 0
+	err = client.retry.do(fmt.Sprintf("creating release %s", settings.tagName), func() (*github.Response, error) {
+		// github may have created the release before the last attempt failed, it is used rather than creating another
+		attempts++
+		if attempts > 1 {
+			existing, err := findRelease(client, owner, repo, settings.tagName)
+			if err != nil || existing != nil {
+				createdRelease = existing
+				return nil, err
+			}
+		}
+
+		var resp *github.Response
+		var err error
+		createdRelease, resp, err = client.Repositories.CreateRelease(context.Background(), owner, repo, newRelease)
+		return resp, err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	newRelease, _, err := client.Repositories.CreateRelease(context.Background(), owner, repo, &release)
+	return createdRelease, nil
+}
+
+// findRelease looks up the release for a tag without changing anything.  It returns nil if there is no release.
+func findRelease(client *githubClient, owner, repo, tagName string) (*github.RepositoryRelease, error) {
+	releases, err := getReleases(client, owner, repo)
 	if err != nil {
 		return nil, err
 	}
 
-	return newRelease, nil
+	for _, release := range releases {
+		if release.GetTagName() == tagName {
+			return release, nil
+		}
+	}
+
+	return nil, nil
 }
 
-func getReleases(client *github.Client, owner, repo string) ([]*github.RepositoryRelease, error) {
+func getReleases(client *githubClient, owner, repo string) ([]*github.RepositoryRelease, error) {
 	opt := github.ListOptions{
 		PerPage: 100,
 	}
 
 	allReleases := make([]*github.RepositoryRelease, 0, 100)
 	for {
-		releases, resp, err := client.Repositories.ListReleases(context.Background(), owner, repo, &opt)
+		var releases []*github.RepositoryRelease
+		var resp *github.Response
+		err := client.retry.do("listing releases", func() (*github.Response, error) {
+			var err error
+			releases, resp, err = client.Repositories.ListReleases(context.Background(), owner, repo, &opt)
+			return resp, err
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -268,7 +887,18 @@ func getReleases(client *github.Client, owner, repo string) ([]*github.Repositor
 	}
 }
 
-func getGithubClient(token, apiURL *string) (*github.Client, error) {
+// githubClient is a github client that retries its calls with retry
+type githubClient struct {
+	*github.Client
+	retry *retrier
+}
+
+// once is the same client without retries
+func (client *githubClient) once() *githubClient {
+	return &githubClient{Client: client.Client, retry: &retrier{maxAttempts: 1, errWriter: client.retry.errWriter}}
+}
+
+func getGithubClient(token, apiURL *string, retry *retrier) (*githubClient, error) {
 	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: *token})
 	tokenClient := oauth2.NewClient(context.Background(), tokenSource)
 
@@ -283,5 +913,5 @@ func getGithubClient(token, apiURL *string) (*github.Client, error) {
 		client.UploadURL = url
 	}
 
-	return client, nil
+	return &githubClient{Client: client, retry: retry}, nil
 }
@@ -0,0 +1,254 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/guywithnose/runner"
+)
+
+// defaultReleaseNotesTemplate lists the commits in each section followed by a link to the full diff
+const defaultReleaseNotesTemplate = `{{range .Sections}}## {{.Title}}
+{{range .Commits}}
+- {{if .Scope}}**{{.Scope}}:** {{end}}{{.Description}} ({{.ShortHash}}){{end}}
+
+{{end}}{{if .CompareURL}}**Full Changelog**: {{.CompareURL}}
+{{end}}`
+
+type releaseNotesConfig struct {
+	Template string   `yaml:"template"`
+	Include  []string `yaml:"include"`
+	Exclude  []string `yaml:"exclude"`
+	Disable  bool     `yaml:"disable"`
+}
+
+// conventionalCommitPattern splits a Conventional Commits (https://www.conventionalcommits.org) subject such as
+// "feat(api)!: remove the v1 endpoints" into its type, scope, breaking marker and description
+var conventionalCommitPattern = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^)]*)\))?(!)?: +(.+)$`)
+
+var breakingChangePattern = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE: `)
+
+// releaseNote is a commit as it appears in the release notes.  Commits that do not follow Conventional Commits have
+// their whole subject as the description and no type.
+type releaseNote struct {
+	Hash        string
+	ShortHash   string
+	Subject     string
+	Type        string
+	Scope       string
+	Description string
+	Breaking    bool
+}
+
+type releaseNotesSection struct {
+	Title   string
+	Commits []releaseNote
+}
+
+// releaseNotesData is available to the release notes template
+type releaseNotesData struct {
+	Project     string
+	Tag         string
+	PreviousTag string
+	CompareURL  string
+	Sections    []releaseNotesSection
+	Commits     []releaseNote
+}
+
+// releaseNotesSections are the section titles in the order they are written and which commits go in them
+var releaseNotesSections = []struct {
+	title    string
+	includes func(note releaseNote) bool
+}{
+	{"Breaking Changes", func(note releaseNote) bool { return note.Breaking }},
+	{"Features", func(note releaseNote) bool { return note.Type == "feat" }},
+	{"Bug Fixes", func(note releaseNote) bool { return note.Type == "fix" }},
+	{"Performance Improvements", func(note releaseNote) bool { return note.Type == "perf" }},
+	{"Other Changes", func(releaseNote) bool { return true }},
+}
+
+// releaseNotesFor returns a function that writes the body of a new release from the commits since the previous tag.
+// The notes are only written when a release is created without a body.
+func releaseNotesFor(cmdWrapper runner.Builder, cfg *config, mainPath, projectName, tagName, repository string) func() (string, error) {
+	return func() (string, error) {
+		if cfg.ReleaseNotes.Disable {
+			return "", nil
+		}
+
+		notes, err := generateReleaseNotes(cmdWrapper, cfg.ReleaseNotes, mainPath, projectName, tagName, repository)
+		if err != nil {
+			return "", fmt.Errorf("Unable to generate release notes: %v", err)
+		}
+
+		return notes, nil
+	}
+}
+
+func generateReleaseNotes(cmdWrapper runner.Builder, cfg releaseNotesConfig, mainPath, projectName, tagName, repository string) (string, error) {
+	text := defaultReleaseNotesTemplate
+	if cfg.Template != "" {
+		contents, err := ioutil.ReadFile(pathRelativeTo(mainPath, cfg.Template))
+		if err != nil {
+			return "", err
+		}
+
+		text = string(contents)
+	}
+
+	include, err := compilePatterns(cfg.Include)
+	if err != nil {
+		return "", err
+	}
+
+	exclude, err := compilePatterns(cfg.Exclude)
+	if err != nil {
+		return "", err
+	}
+
+	previousTag, err := getPreviousTag(cmdWrapper, mainPath, tagName)
+	if err != nil {
+		return "", err
+	}
+
+	commits, err := getCommitsSince(cmdWrapper, mainPath, previousTag, tagName)
+	if err != nil {
+		return "", err
+	}
+
+	data := releaseNotesData{Project: projectName, Tag: tagName, PreviousTag: previousTag, Commits: []releaseNote{}}
+	if previousTag != "" {
+		data.CompareURL = fmt.Sprintf("%s/compare/%s...%s", repository, previousTag, tagName)
+	}
+
+	for _, commit := range commits {
+		if (len(include) == 0 || matchesAny(include, commit.Subject)) && !matchesAny(exclude, commit.Subject) {
+			data.Commits = append(data.Commits, commit)
+		}
+	}
+
+	data.Sections = groupReleaseNotes(data.Commits)
+	return renderTemplate("releaseNotes", text, data)
+}
+
+// groupReleaseNotes puts each commit in the first section that it belongs in, leaving out the empty sections
+func groupReleaseNotes(commits []releaseNote) []releaseNotesSection {
+	grouped := make([][]releaseNote, len(releaseNotesSections))
+	for _, commit := range commits {
+		for index, section := range releaseNotesSections {
+			if section.includes(commit) {
+				grouped[index] = append(grouped[index], commit)
+				break
+			}
+		}
+	}
+
+	sections := []releaseNotesSection{}
+	for index, section := range releaseNotesSections {
+		if len(grouped[index]) != 0 {
+			sections = append(sections, releaseNotesSection{Title: section.title, Commits: grouped[index]})
+		}
+	}
+
+	return sections
+}
+
+// getPreviousTag finds the newest semver tag that comes before tagName in its history.  Prereleases are skipped for a
+// release that is not a prerelease, so the notes for v1.1.0 cover everything since v1.0.0 rather than since v1.1.0-rc.1.
+// It returns an empty string when there is no previous tag.
+func getPreviousTag(cmdWrapper runner.Builder, mainPath, tagName string) (string, error) {
+	output, err := cmdWrapper.New(mainPath, "git", "tag", "--merged", tagName).Output()
+	if err != nil {
+		return "", fmt.Errorf("Unable to list the tags before %s: %v", tagName, err)
+	}
+
+	current, currentIsSemver := parseSemver(tagName)
+	previousTag := ""
+	var previous semver
+	for _, tag := range strings.Split(string(output), "\n") {
+		tag = strings.TrimSpace(tag)
+		version, ok := parseSemver(tag)
+		if !ok || tag == tagName {
+			continue
+		}
+
+		if currentIsSemver && (version.compare(current) >= 0 || (version.isPrerelease() && !current.isPrerelease())) {
+			continue
+		}
+
+		if previousTag == "" || version.compare(previous) > 0 {
+			previousTag, previous = tag, version
+		}
+	}
+
+	return previousTag, nil
+}
+
+// getCommitsSince lists the commits after previousTag up to tagName, newest first, leaving out merges.  Every commit up
+// to tagName is listed when there is no previous tag.
+func getCommitsSince(cmdWrapper runner.Builder, mainPath, previousTag, tagName string) ([]releaseNote, error) {
+	revisions := tagName
+	if previousTag != "" {
+		revisions = fmt.Sprintf("%s..%s", previousTag, tagName)
+	}
+
+	output, err := cmdWrapper.New(mainPath, "git", "log", "--no-merges", "--format=%H%x1f%s%x1f%b%x1e", revisions, "--").Output()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the commits in %s: %v", revisions, err)
+	}
+
+	commits := []releaseNote{}
+	for _, record := range strings.Split(string(output), "\x1e") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x1f", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		commits = append(commits, parseReleaseNote(fields[0], fields[1], fields[2]))
+	}
+
+	return commits, nil
+}
+
+func parseReleaseNote(hash, subject, body string) releaseNote {
+	note := releaseNote{Hash: hash, ShortHash: hash, Subject: subject, Description: subject}
+	if len(hash) > 7 {
+		note.ShortHash = hash[:7]
+	}
+
+	match := conventionalCommitPattern.FindStringSubmatch(subject)
+	if match != nil {
+		note.Type = strings.ToLower(match[1])
+		note.Scope = match[2]
+		note.Breaking = match[3] == "!"
+		note.Description = match[4]
+	}
+
+	note.Breaking = note.Breaking || breakingChangePattern.MatchString(body)
+	return note
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		expression, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		compiled = append(compiled, expression)
+	}
+
+	return compiled, nil
+}
+
+func matchesAny(patterns []*regexp.Regexp, text string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,227 @@
+package command_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+const releaseNotesLog = "aaaaaaa1111\x1ffeat(api): add the v2 endpoints\x1f\x1e\n" +
+	"bbbbbbb2222\x1ffix: handle empty tags\x1f\x1e\n" +
+	"ccccccc3333\x1frefactor!: drop go1.7\x1f\x1e\n" +
+	"ddddddd4444\x1fchore: update dependencies\x1f\x1e\n" +
+	"eeeeeee5555\x1fperf: cache the dist list\x1fThe cache is on by default.\n\nBREAKING CHANGE: pass --noCache to turn it off\n\x1e\n" +
+	"fffffff6666\x1fUpdate the README\x1f\x1e\n"
+
+func TestReleaseNotes(t *testing.T) {
+	created := []*github.RepositoryRelease{}
+	ts, release := getReleaseNotesTestServer(t, &created)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\nreleaseNotes:\n  exclude: [\"^chore\"]\n")
+	err := runReleaseNotes(
+		t,
+		ts.URL,
+		mainPath,
+		"v1.1.0",
+		nil,
+		runner.NewExpectedCommand(mainPath, "git tag --merged v1.1.0", "v0.9.0\nv1.0.0\nv1.1.0-rc.1\nv1.1.0\nlatest\n", 0),
+		runner.NewExpectedCommand(mainPath, "git log --no-merges --format=%H%x1f%s%x1f%b%x1e v1.0.0..v1.1.0 --", releaseNotesLog, 0),
+	)
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(created)) {
+		assert.Equal(
+			t,
+			"## Breaking Changes\n\n"+
+				"- drop go1.7 (ccccccc)\n"+
+				"- cache the dist list (eeeeeee)\n\n"+
+				"## Features\n\n"+
+				"- **api:** add the v2 endpoints (aaaaaaa)\n\n"+
+				"## Bug Fixes\n\n"+
+				"- handle empty tags (bbbbbbb)\n\n"+
+				"## Other Changes\n\n"+
+				"- Update the README (fffffff)\n\n"+
+				fmt.Sprintf("**Full Changelog**: %s/owner/repo/compare/v1.0.0...v1.1.0\n", ts.URL),
+			created[0].GetBody(),
+		)
+	}
+}
+
+func TestReleaseNotesTemplate(t *testing.T) {
+	created := []*github.RepositoryRelease{}
+	ts, release := getReleaseNotesTestServer(t, &created)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\nreleaseNotes:\n  template: notes.tmpl\n  include: [\"^(feat|fix)\"]\n")
+	writeTestFile(t, fmt.Sprintf("%s/notes.tmpl", mainPath), "{{.Project}} {{.Tag}} since {{.PreviousTag}}:{{range .Commits}} {{.Subject}};{{end}}", 0644)
+	err := runReleaseNotes(
+		t,
+		ts.URL,
+		mainPath,
+		"v1.1.0-rc.2",
+		nil,
+		runner.NewExpectedCommand(mainPath, "git tag --merged v1.1.0-rc.2", "v1.0.0\nv1.1.0-rc.1\nv1.1.0-rc.2\n", 0),
+		runner.NewExpectedCommand(mainPath, "git log --no-merges --format=%H%x1f%s%x1f%b%x1e v1.1.0-rc.1..v1.1.0-rc.2 --", releaseNotesLog, 0),
+	)
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(created)) {
+		assert.Equal(
+			t,
+			"projectName v1.1.0-rc.2 since v1.1.0-rc.1: feat(api): add the v2 endpoints; fix: handle empty tags;",
+			created[0].GetBody(),
+		)
+	}
+}
+
+func TestReleaseNotesFirstRelease(t *testing.T) {
+	created := []*github.RepositoryRelease{}
+	ts, release := getReleaseNotesTestServer(t, &created)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+	err := runReleaseNotes(
+		t,
+		ts.URL,
+		mainPath,
+		"v1.0.0",
+		nil,
+		runner.NewExpectedCommand(mainPath, "git tag --merged v1.0.0", "v1.0.0\n", 0),
+		runner.NewExpectedCommand(mainPath, "git log --no-merges --format=%H%x1f%s%x1f%b%x1e v1.0.0 --", "abcdef0123\x1fInitial commit\x1f\x1e\n", 0),
+	)
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(created)) {
+		assert.Equal(t, "## Other Changes\n\n- Initial commit (abcdef0)\n\n", created[0].GetBody())
+	}
+}
+
+func TestReleaseNotesFile(t *testing.T) {
+	created := []*github.RepositoryRelease{}
+	ts, release := getReleaseNotesTestServer(t, &created)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+	notesFile := fmt.Sprintf("%s/NOTES.md", mainPath)
+	writeTestFile(t, notesFile, "Hand written notes\n", 0644)
+	err := runReleaseNotes(t, ts.URL, mainPath, "v1.1.0", map[string]string{"releaseNotesFile": notesFile})
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(created)) {
+		assert.Equal(t, "Hand written notes\n", created[0].GetBody())
+	}
+}
+
+func TestReleaseNotesDisabled(t *testing.T) {
+	created := []*github.RepositoryRelease{}
+	ts, release := getReleaseNotesTestServer(t, &created)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\nreleaseNotes:\n  disable: true\n")
+	err := runReleaseNotes(t, ts.URL, mainPath, "v1.1.0", nil)
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(created)) {
+		assert.Nil(t, created[0].Body)
+	}
+}
+
+func TestReleaseNotesGitFailure(t *testing.T) {
+	created := []*github.RepositoryRelease{}
+	ts, release := getReleaseNotesTestServer(t, &created)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	set := getSummaryFlagSet(t, ts.URL, mainPath)
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "v1.1.0", "projectName"}))
+	expectedRunner := &runner.Test{
+		ExpectedCommands: append(
+			append(
+				[]*runner.ExpectedCommand{
+					getDistListCommand(t, mainPath),
+					runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+				},
+				getTagCommands(t, mainPath, "v1.1.0")...,
+			),
+			runner.NewExpectedCommand(mainPath, "git tag --merged v1.1.0", "error: malformed object name v1.1.0", 129),
+		),
+	}
+	app, _, _ := appWithTestWriters()
+	err = command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Unable to generate release notes: Unable to list the tags before v1.1.0: exit status 129")
+	assert.Equal(t, 0, len(created))
+}
+
+// runReleaseNotes releases tagName for linux/amd64 with the git commands that the release notes run
+func runReleaseNotes(t *testing.T, url, mainPath, tagName string, flags map[string]string, gitCommands ...*runner.ExpectedCommand) error {
+	t.Helper()
+	set := getSummaryFlagSet(t, url, mainPath)
+	for name, value := range flags {
+		set.String(name, value, "doc")
+	}
+
+	assert.Nil(t, set.Parse([]string{"owner", "repo", tagName, "projectName"}))
+	fileName := distFile(mainPath, "linux", "amd64", fmt.Sprintf("projectName-linux-amd64-go1.8-%s", tagName))
+	writeTestFile(t, fileName, "foo", 0777)
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	expectedCommands := append(
+		[]*runner.ExpectedCommand{
+			getDistListCommand(t, mainPath),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+		},
+		getTagCommands(t, mainPath, tagName)...,
+	)
+	expectedCommands = append(expectedCommands, gitCommands...)
+	expectedCommands = append(
+		expectedCommands,
+		runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s build -o %s", goExecutable, fileName), "", 0).WithEnvironment([]string{
+			"GOOS=linux",
+			"GOARCH=amd64",
+			fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH")),
+		}),
+	)
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands}
+	app, _, errWriter := appWithTestWriters()
+	err = command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+	assert.Equal(t, "", errWriter.String())
+	return err
+}
+
+// getReleaseNotesTestServer is a release test server that records the releases it creates, giving them id 3
+func getReleaseNotesTestServer(t *testing.T, created *[]*github.RepositoryRelease) (*httptest.Server, *httptest.Server) {
+	t.Helper()
+	return getRetryTestServer(t, nil, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != "POST" || r.URL.String() != "/repos/owner/repo/releases" {
+			return false
+		}
+
+		newRelease := &github.RepositoryRelease{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(newRelease))
+		*created = append(*created, newRelease)
+		fmt.Fprint(w, `{"id": 3}`)
+		return true
+	})
+}
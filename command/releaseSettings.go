@@ -0,0 +1,185 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/guywithnose/runner"
+	"github.com/urfave/cli"
+)
+
+// releaseSettings are the fields a release is created with.  The ones that are set are also applied to a release that
+// already exists, so a rerun does not leave it stale.
+type releaseSettings struct {
+	tagName         string
+	publish         bool
+	name            *string
+	body            *string
+	targetCommitish *string
+	prerelease      *bool
+	// notes writes the body of a new release when no body is given
+	notes func() (string, error)
+}
+
+// newReleaseSettings renders the release templates from the flags or config.  The body is the file given with
+// --releaseNotesFile, then --releaseBody or release.body, and otherwise the notes are generated for a new release.  A
+// semantic version tag with a prerelease is released as a prerelease unless --prerelease or release.prerelease says
+// otherwise.
+func newReleaseSettings(
+	c *cli.Context,
+	cmdWrapper runner.Builder,
+	cfg *config,
+	mainPath string,
+	data templateData,
+	publish bool,
+	notes func() (string, error),
+) (releaseSettings, error) {
+	settings := releaseSettings{tagName: data.Tag, publish: publish}
+	templates := map[string]string{
+		"name":            stringOption(c.String("releaseName"), cfg.Release.Name),
+		"body":            stringOption(c.String("releaseBody"), cfg.Release.Body),
+		"targetCommitish": stringOption(c.String("targetCommitish"), cfg.Release.TargetCommitish),
+	}
+
+	if data.Commit == "" && anyTemplateUses([]string{templates["name"], templates["body"], templates["targetCommitish"]}, "Commit") {
+		commit, err := getCommit(cmdWrapper, mainPath)
+		if err != nil {
+			return settings, err
+		}
+
+		data.Commit = commit
+	}
+
+	rendered := map[string]*string{}
+	for field, text := range templates {
+		if text == "" {
+			continue
+		}
+
+		value, err := renderTemplate(field, text, data)
+		if err != nil {
+			return settings, cli.NewExitError(fmt.Sprintf("Invalid release %s template: %v", field, err), 1)
+		}
+
+		rendered[field] = &value
+	}
+
+	settings.name, settings.body, settings.targetCommitish = rendered["name"], rendered["body"], rendered["targetCommitish"]
+	if c.String("releaseNotesFile") != "" {
+		contents, err := ioutil.ReadFile(c.String("releaseNotesFile"))
+		if err != nil {
+			return settings, cli.NewExitError(fmt.Sprintf("Unable to read release notes: %v", err), 1)
+		}
+
+		body := string(contents)
+		settings.body = &body
+	}
+
+	if settings.body == nil {
+		settings.notes = notes
+	}
+
+	settings.prerelease = cfg.Release.Prerelease
+	if version, ok := parseSemver(data.Tag); ok && settings.prerelease == nil {
+		prerelease := version.isPrerelease()
+		settings.prerelease = &prerelease
+	}
+
+	if c.String("prerelease") != "" {
+		prerelease, err := strconv.ParseBool(c.String("prerelease"))
+		if err != nil {
+			return settings, cli.NewExitError(fmt.Sprintf("Invalid --prerelease %s (expected true or false)", c.String("prerelease")), 1)
+		}
+
+		settings.prerelease = &prerelease
+	}
+
+	return settings, nil
+}
+
+// newRelease is the release to create, with the body written by the notes when none was given
+func (settings releaseSettings) newRelease() (*github.RepositoryRelease, error) {
+	draft := !settings.publish
+	release := &github.RepositoryRelease{
+		TagName:         &settings.tagName,
+		Draft:           &draft,
+		Name:            settings.name,
+		Body:            settings.body,
+		TargetCommitish: settings.targetCommitish,
+		Prerelease:      settings.prerelease,
+	}
+
+	if release.Body == nil && settings.notes != nil {
+		body, err := settings.notes()
+		if err != nil {
+			return nil, err
+		}
+
+		if body != "" {
+			release.Body = &body
+		}
+	}
+
+	return release, nil
+}
+
+// patch is the edit that brings an existing release in line with the settings, publishing it if it is a draft and
+// publish is set.  It also returns the names of the fields that change, which are empty if nothing does.
+func (settings releaseSettings) patch(release *github.RepositoryRelease) (*github.RepositoryRelease, []string) {
+	patch := &github.RepositoryRelease{}
+	changed := []string{}
+	if release.GetDraft() && settings.publish {
+		draft := false
+		patch.Draft = &draft
+		changed = append(changed, "draft")
+	}
+
+	for _, field := range []struct {
+		name     string
+		value    *string
+		existing string
+		patched  **string
+	}{
+		{"name", settings.name, release.GetName(), &patch.Name},
+		{"body", settings.body, release.GetBody(), &patch.Body},
+		{"target commitish", settings.targetCommitish, release.GetTargetCommitish(), &patch.TargetCommitish},
+	} {
+		if field.value != nil && *field.value != field.existing {
+			*field.patched = field.value
+			changed = append(changed, field.name)
+		}
+	}
+
+	if settings.prerelease != nil && *settings.prerelease != release.GetPrerelease() {
+		patch.Prerelease = settings.prerelease
+		changed = append(changed, "prerelease")
+	}
+
+	return patch, changed
+}
+
+// describeEdit says what patching the release does, e.g. "publish release v1.0.0 and update its name"
+func (settings releaseSettings) describeEdit(changed []string) string {
+	publishing := false
+	fields := []string{}
+	for _, field := range changed {
+		if field == "draft" {
+			publishing = true
+			continue
+		}
+
+		fields = append(fields, field)
+	}
+
+	switch {
+	case len(fields) == 0:
+		return fmt.Sprintf("publish release %s", settings.tagName)
+	case publishing:
+		return fmt.Sprintf("publish release %s and update its %s", settings.tagName, strings.Join(fields, ", "))
+	}
+
+	return fmt.Sprintf("update the %s of release %s", strings.Join(fields, ", "), settings.tagName)
+}
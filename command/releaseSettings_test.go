@@ -0,0 +1,221 @@
+package command_test
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestReleaseSettings(t *testing.T) {
+	created := []*github.RepositoryRelease{}
+	ts, release := getReleaseNotesTestServer(t, &created)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\nrelease:\n  name: \"{{.Project}} {{.Version}}\"\n  targetCommitish: main\n")
+	err := runReleaseNotes(t, ts.URL, mainPath, "v1.1.0-rc.1", map[string]string{"releaseBody": "Try out {{.Tag}}"})
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(created)) {
+		assert.Equal(t, "v1.1.0-rc.1", created[0].GetTagName())
+		assert.Equal(t, "projectName 1.1.0-rc.1", created[0].GetName())
+		assert.Equal(t, "Try out v1.1.0-rc.1", created[0].GetBody())
+		assert.Equal(t, "main", created[0].GetTargetCommitish())
+		assert.True(t, created[0].GetDraft())
+		assert.True(t, created[0].GetPrerelease())
+	}
+}
+
+func TestReleaseSettingsStableTag(t *testing.T) {
+	created := []*github.RepositoryRelease{}
+	ts, release := getReleaseNotesTestServer(t, &created)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\nrelease:\n  body: Notes\n")
+	err := runReleaseNotes(t, ts.URL, mainPath, "v1.1.0", nil)
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(created)) {
+		assert.Nil(t, created[0].Name)
+		assert.Nil(t, created[0].TargetCommitish)
+		if assert.NotNil(t, created[0].Prerelease) {
+			assert.False(t, created[0].GetPrerelease())
+		}
+	}
+}
+
+func TestReleaseSettingsExplicitPrerelease(t *testing.T) {
+	created := []*github.RepositoryRelease{}
+	ts, release := getReleaseNotesTestServer(t, &created)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\nrelease:\n  body: Notes\n  prerelease: false\n")
+	err := runReleaseNotes(t, ts.URL, mainPath, "v1.1.0-rc.1", nil)
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(created)) && assert.NotNil(t, created[0].Prerelease) {
+		assert.False(t, created[0].GetPrerelease())
+	}
+}
+
+func TestReleaseSettingsCommit(t *testing.T) {
+	created := []*github.RepositoryRelease{}
+	ts, release := getReleaseNotesTestServer(t, &created)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\nrelease:\n  body: Notes\n")
+	err := runReleaseNotes(
+		t,
+		ts.URL,
+		mainPath,
+		"v1.1.0",
+		map[string]string{"releaseName": "{{.Project}} at {{.Commit}}", "targetCommitish": "{{.Commit}}"},
+		runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "abc123\n", 0),
+	)
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(created)) {
+		assert.Equal(t, "projectName at abc123", created[0].GetName())
+		assert.Equal(t, "abc123", created[0].GetTargetCommitish())
+	}
+}
+
+func TestReleaseSettingsPrereleaseFlag(t *testing.T) {
+	created := []*github.RepositoryRelease{}
+	ts, release := getReleaseNotesTestServer(t, &created)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\nrelease:\n  body: Notes\n  prerelease: true\n")
+	err := runReleaseNotes(t, ts.URL, mainPath, "v1.1.0-rc.1", map[string]string{"prerelease": "false"})
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(created)) && assert.NotNil(t, created[0].Prerelease) {
+		assert.False(t, created[0].GetPrerelease())
+	}
+}
+
+func TestReleaseSettingsUpdateExisting(t *testing.T) {
+	patches := []*github.RepositoryRelease{}
+	ts, release := getRetryTestServer(t, nil, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != "PATCH" || r.URL.String() != "/repos/owner/repo/releases/1" {
+			return false
+		}
+
+		patch := &github.RepositoryRelease{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(patch))
+		patches = append(patches, patch)
+		fmt.Fprint(w, `{"id": 1}`)
+		return true
+	})
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\nrelease:\n  prerelease: true\n")
+	err := runReleaseNotes(t, ts.URL, mainPath, "tag", map[string]string{"releaseName": "{{.Project}} {{.Tag}}", "targetCommitish": "main"})
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(patches)) {
+		assert.Equal(t, "projectName tag", patches[0].GetName())
+		assert.Equal(t, "main", patches[0].GetTargetCommitish())
+		assert.True(t, patches[0].GetPrerelease())
+		assert.Nil(t, patches[0].Body)
+		assert.Nil(t, patches[0].Draft)
+		assert.Nil(t, patches[0].TagName)
+	}
+}
+
+func TestReleaseSettingsBadTemplate(t *testing.T) {
+	created := []*github.RepositoryRelease{}
+	ts, release := getReleaseNotesTestServer(t, &created)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	set := getSummaryFlagSet(t, ts.URL, mainPath)
+	set.String("releaseName", "{{.Nope}}", "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "v1.1.0", "projectName"}))
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			getDistListCommand(t, mainPath),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+		},
+	}
+	app, _, _ := appWithTestWriters()
+	err = command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "Invalid release name template: ")
+	}
+
+	assert.Equal(t, 0, len(created))
+}
+
+func TestReleaseSettingsInvalidPrerelease(t *testing.T) {
+	created := []*github.RepositoryRelease{}
+	ts, release := getReleaseNotesTestServer(t, &created)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	set := getSummaryFlagSet(t, ts.URL, mainPath)
+	set.String("prerelease", "maybe", "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "v1.1.0", "projectName"}))
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			getDistListCommand(t, mainPath),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+		},
+	}
+	app, _, _ := appWithTestWriters()
+	err = command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Invalid --prerelease maybe (expected true or false)")
+	assert.Equal(t, 0, len(created))
+}
+
+func TestReleaseSettingsDryRun(t *testing.T) {
+	requests := []string{}
+	ts := getPlanTestServer(t, &requests)
+	defer ts.Close()
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.Bool("dryRun", true, "doc")
+	set.Bool("publish", true, "doc")
+	set.String("releaseName", "{{.Project}} {{.Tag}}", "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\nrelease:\n  prerelease: true\n")
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			getDistListCommand(t, mainPath),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+		},
+	}
+	app, writer, _ := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Contains(t, writer.String(), fmt.Sprintf("PATCH %s/repos/owner/repo/releases/1 (publish release tag and update its name, prerelease)\n", ts.URL))
+	assert.NotContains(t, requests, "PATCH /repos/owner/repo/releases/1")
+}
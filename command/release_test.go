@@ -9,7 +9,9 @@ import (
 	"net/http/httptest"
 	"os"
 	"os/exec"
+	"regexp"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/google/go-github/github"
@@ -31,7 +33,7 @@ func TestRelease(t *testing.T) {
 	assert.Nil(t, err)
 	defer cleanUp(t, mainPath)
 	createFiles(t, mainPath, "tag")
-	expectedCommands := getExpectedCommands(t, mainPath)
+	expectedCommands := getReleaseCommands(t, mainPath)
 	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
 	app, _, _ := appWithTestWriters()
 	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
@@ -53,18 +55,16 @@ func TestReleaseBuildError(t *testing.T) {
 	createFiles(t, mainPath, "tag")
 	goExecutable, err := exec.LookPath("go")
 	assert.Nil(t, err)
-	expectedCommands := getExpectedCommands(t, mainPath)
-	// Skip taring the errored build
-	expectedCommands = append(expectedCommands[0:1], expectedCommands[2:]...)
-	expectedCommands[0] = runner.NewExpectedCommand(
+	expectedCommands := getReleaseCommands(t, mainPath)
+	expectedCommands[2+len(getTagCommands(t, mainPath, "tag"))] = runner.NewExpectedCommand(
 		mainPath,
-		fmt.Sprintf("%s build -o /tmp/build/projectName-linux-386-go1.8-tag", goExecutable),
+		fmt.Sprintf("%s build -o /tmp/build/dist/linux_386/projectName-linux-386-go1.8-tag", goExecutable),
 		"Build error",
 		2,
 	).WithEnvironment([]string{"GOOS=linux", "GOARCH=386", fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH"))})
 	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
 	app, _, errWriter := appWithTestWriters()
-	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.EqualError(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)), "Failed targets: linux/386")
 	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
 	assert.Equal(t, []error(nil), expectedRunner.Errors)
 	output := strings.Split(errWriter.String(), "\n")
@@ -79,7 +79,7 @@ func TestReleaseBuildError(t *testing.T) {
 	)
 }
 
-func TestReleaseCompressError(t *testing.T) {
+func TestReleaseArchiveError(t *testing.T) {
 	ts := getReleaseTestServer(t, "", "")
 	defer ts.Close()
 	mainPath := fmt.Sprintf("%s/build", os.TempDir())
@@ -91,25 +91,19 @@ func TestReleaseCompressError(t *testing.T) {
 	assert.Nil(t, err)
 	defer cleanUp(t, mainPath)
 	createFiles(t, mainPath, "tag")
-	assert.Nil(t, err)
-	expectedCommands := getExpectedCommands(t, mainPath)
-	expectedCommands[1] = runner.NewExpectedCommand(
-		mainPath,
-		"gzip /tmp/build/projectName-linux-386-go1.8-tag",
-		"Build error",
-		2,
-	)
+	assert.Nil(t, os.Remove(distFile(mainPath, "linux", "386", "projectName-linux-386-go1.8-tag")))
+	expectedCommands := getReleaseCommands(t, mainPath)
 	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
 	app, _, errWriter := appWithTestWriters()
-	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.EqualError(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)), "Failed targets: linux/386")
 	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
 	assert.Equal(t, []error(nil), expectedRunner.Errors)
 	output := strings.Split(errWriter.String(), "\n")
 	assert.Equal(
 		t,
 		[]string{
-			"Could not compress binary for linux/386: exit status 2",
-			"Output: Build error",
+			"Unable to record binary /tmp/build/dist/linux_386/projectName-linux-386-go1.8-tag: stat /tmp/build/dist/linux_386/projectName-linux-386-go1.8-tag: no such file or directory",
+			"Could not archive binary for linux/386: open /tmp/build/dist/linux_386/projectName-linux-386-go1.8-tag: no such file or directory",
 			"",
 		},
 		output,
@@ -122,10 +116,11 @@ func TestReleaseReleaseFailure(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.String("token", "fakeToken", "doc")
 	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.Int("maxAttempts", 1, "doc")
 	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
 	assert.Nil(t, err)
 	app, _, _ := appWithTestWriters()
-	err = command.CmdRelease(&runner.Test{})(cli.NewContext(app, set, nil))
+	err = command.CmdRelease(getDistListRunner(t, ""))(cli.NewContext(app, set, nil))
 	assert.EqualError(t, err, fmt.Sprintf("GET %s/repos/owner/repo/releases?per_page=100: 500  []", ts.URL))
 }
 
@@ -136,21 +131,25 @@ func TestReleaseReleaseBadApiUrl(t *testing.T) {
 	err := set.Parse([]string{"owner", "repo", "doesntexist", "projectName"})
 	assert.Nil(t, err)
 	app, _, _ := appWithTestWriters()
-	err = command.CmdRelease(&runner.Test{})(cli.NewContext(app, set, nil))
+	err = command.CmdRelease(getDistListRunner(t, ""))(cli.NewContext(app, set, nil))
 	assert.EqualError(t, err, "parse %s/mockApi: invalid URL escape \"%s/\"")
 }
 
 func TestReleaseReleaseCreate(t *testing.T) {
 	ts := getReleaseTestServer(t, "", "")
 	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
 	set := flag.NewFlagSet("test", 0)
 	set.String("token", "fakeToken", "doc")
 	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.Bool("skipVersionCheck", true, "doc")
 	err := set.Parse([]string{"owner", "repo", "doesntexist", "projectName"})
 	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
 	app, _, _ := appWithTestWriters()
-	err = command.CmdRelease(&runner.Test{})(cli.NewContext(app, set, nil))
-	assert.Nil(t, err)
+	err = command.CmdRelease(getDistListRunner(t, mainPath))(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Failed targets: darwin/amd64, darwin/arm64, linux/386, linux/amd64, linux/arm64, linux/s390x, solaris/amd64, windows/386, windows/amd64")
 }
 
 func TestReleaseReleaseCreateFail(t *testing.T) {
@@ -159,10 +158,12 @@ func TestReleaseReleaseCreateFail(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.String("token", "fakeToken", "doc")
 	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.Int("maxAttempts", 1, "doc")
+	set.Bool("skipVersionCheck", true, "doc")
 	err := set.Parse([]string{"owner", "repo", "doesntexist", "projectName"})
 	assert.Nil(t, err)
 	app, _, _ := appWithTestWriters()
-	err = command.CmdRelease(&runner.Test{})(cli.NewContext(app, set, nil))
+	err = command.CmdRelease(getDistListRunner(t, ""))(cli.NewContext(app, set, nil))
 	assert.EqualError(t, err, fmt.Sprintf("POST %s/repos/owner/repo/releases: 500  []", ts.URL))
 }
 
@@ -180,8 +181,8 @@ func TestReleaseReleaseUpdatePublish(t *testing.T) {
 	err := set.Parse([]string{"owner", "repo", "draft", "projectName"})
 	assert.Nil(t, err)
 	app, _, _ := appWithTestWriters()
-	err = command.CmdRelease(&runner.Test{})(cli.NewContext(app, set, nil))
-	assert.Nil(t, err)
+	err = command.CmdRelease(getDistListRunner(t, mainPath))(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Failed targets: darwin/amd64, darwin/arm64, linux/386, linux/amd64, linux/arm64, linux/s390x, solaris/amd64, windows/386, windows/amd64")
 }
 
 func TestReleaseReleaseUpdatePublishFailure(t *testing.T) {
@@ -190,11 +191,12 @@ func TestReleaseReleaseUpdatePublishFailure(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.String("token", "fakeToken", "doc")
 	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.Int("maxAttempts", 1, "doc")
 	set.Bool("publish", true, "doc")
 	err := set.Parse([]string{"owner", "repo", "draft", "projectName"})
 	assert.Nil(t, err)
 	app, _, _ := appWithTestWriters()
-	err = command.CmdRelease(&runner.Test{})(cli.NewContext(app, set, nil))
+	err = command.CmdRelease(getDistListRunner(t, ""))(cli.NewContext(app, set, nil))
 	assert.EqualError(t, err, fmt.Sprintf("PATCH %s/repos/owner/repo/releases/2: 500  []", ts.URL))
 }
 
@@ -214,8 +216,136 @@ func TestReleaseReleaseSetOses(t *testing.T) {
 	err := set.Parse([]string{"owner", "repo", "draft", "projectName"})
 	assert.Nil(t, err)
 	app, _, _ := appWithTestWriters()
-	err = command.CmdRelease(&runner.Test{})(cli.NewContext(app, set, nil))
+	err = command.CmdRelease(getDistListRunner(t, mainPath))(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Failed targets: linux/386, linux/amd64, linux/arm64, linux/s390x")
+}
+
+func TestReleaseFilterTargets(t *testing.T) {
+	ts := getReleaseTestServer(t, "", "")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	osFlag := cli.StringSlice{"linux", "windows"}
+	set.Var(&osFlag, "os", "doc")
+	archFlag := cli.StringSlice{"amd64"}
+	set.Var(&archFlag, "arch", "doc")
+	targetFlag := cli.StringSlice{"!windows/amd64"}
+	set.Var(&targetFlag, "target", "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
 	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return operatingSystem == "linux" && architecture == "amd64"
+	})
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+	app, _, _ := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+}
+
+func TestReleaseIncludeTargets(t *testing.T) {
+	ts := getReleaseTestServer(t, "", "")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	targetFlag := cli.StringSlice{"darwin/arm64", "windows/386"}
+	set.Var(&targetFlag, "target", "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return (operatingSystem == "darwin" && architecture == "arm64") || (operatingSystem == "windows" && architecture == "386")
+	})
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+	app, _, _ := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+}
+
+func TestReleaseFirstClassOnly(t *testing.T) {
+	ts := getReleaseTestServer(t, "", "")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	set.Bool("firstClassOnly", true, "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return !(operatingSystem == "linux" && architecture == "s390x") && operatingSystem != "solaris"
+	})
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+	app, _, _ := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+}
+
+func TestReleaseDistListFailure(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			runner.NewExpectedCommand(cwd, fmt.Sprintf("%s tool dist list -json", goExecutable), "", -1),
+		},
+	}
+	app, _, _ := appWithTestWriters()
+	err = command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Unable to list supported targets: Error running command")
+}
+
+func TestReleaseInvalidTargets(t *testing.T) {
+	testCases := []struct {
+		flagName string
+		values   cli.StringSlice
+		err      string
+	}{
+		{"os", cli.StringSlice{"linux", "beos"}, "Unknown OS: beos"},
+		{"arch", cli.StringSlice{"vax"}, "Unknown architecture: vax"},
+		{"target", cli.StringSlice{"linux"}, "Invalid target linux, expected os/arch"},
+		{"target", cli.StringSlice{"!linux/arm"}, "Unknown target: linux/arm"},
+		{"target", cli.StringSlice{"windows/arm64"}, "Unknown target: windows/arm64"},
+		{"os", cli.StringSlice{"solaris"}, ""},
+	}
+	for _, testCase := range testCases {
+		set := flag.NewFlagSet("test", 0)
+		set.String("token", "fakeToken", "doc")
+		set.Var(&testCase.values, testCase.flagName, "doc")
+		archFlag := cli.StringSlice{"arm64"}
+		if testCase.err == "" {
+			set.Var(&archFlag, "arch", "doc")
+		}
+
+		err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+		assert.Nil(t, err)
+		app, _, _ := appWithTestWriters()
+		err = command.CmdRelease(getDistListRunner(t, ""))(cli.NewContext(app, set, nil))
+		if testCase.err == "" {
+			assert.EqualError(t, err, "No builds match the requested targets")
+		} else {
+			assert.EqualError(t, err, testCase.err)
+		}
+	}
 }
 
 func TestReleaseUsage(t *testing.T) {
@@ -228,6 +358,7 @@ func TestReleaseUsage(t *testing.T) {
 	set.String("mainPath", mainPath, "doc")
 	defer cleanUp(t, mainPath)
 	createFiles(t, mainPath, "tag")
+	assert.Nil(t, set.Parse([]string{"owner", "repo"}))
 	app, _, _ := appWithTestWriters()
 	err := command.CmdRelease(&runner.Test{})(cli.NewContext(app, set, nil))
 	assert.EqualError(t, err, "Usage: \"goRelease {owner} {repo} {tagName} {projectName} --token {token} --apiUrl {apiUrl}\"")
@@ -250,6 +381,13 @@ func TestReleaseNoToken(t *testing.T) {
 
 func getReleaseTestServer(t *testing.T, failureURL, failureMethod string) *httptest.Server {
 	t.Helper()
+	return getReleaseTestServerWithUploads(t, failureURL, failureMethod, nil)
+}
+
+// getReleaseTestServerWithUploads records the body of every uploaded asset in uploads
+func getReleaseTestServerWithUploads(t *testing.T, failureURL, failureMethod string, uploads map[string][]byte) *httptest.Server {
+	t.Helper()
+	uploadsMutex := sync.Mutex{}
 	var server *httptest.Server
 	draftID := 2
 	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -320,26 +458,6 @@ func getReleaseTestServer(t *testing.T, failureURL, failureMethod string) *httpt
 
 		asset := github.ReleaseAsset{}
 		bytes, _ = json.Marshal(asset)
-		for _, build := range command.ValidBuilds {
-			for _, architecture := range build.Architectures {
-				responses[fmt.Sprintf(
-					"/repos/owner/repo/releases/1/assets?name=projectName-%s-%s-go1.8-tag%s%s",
-					build.OperatingSystem,
-					architecture,
-					build.Extension,
-					build.CompressExtension,
-				)] = string(bytes)
-				responses[fmt.Sprintf(
-					"/repos/owner/repo/releases/2/assets?name=projectName-%s-%s-go1.8-draft%s%s",
-					build.OperatingSystem,
-					architecture,
-					build.Extension,
-					build.CompressExtension,
-				)] = string(bytes)
-			}
-		}
-
-		responses["/repos/owner/repo/releases/1/assets?name=projectName-linux-386-go1.8-tag"] = string(bytes)
 
 		resp, ok := responses[r.URL.String()]
 		if ok {
@@ -347,6 +465,24 @@ func getReleaseTestServer(t *testing.T, failureURL, failureMethod string) *httpt
 			return
 		}
 
+		if r.Method == "GET" && strings.HasSuffix(r.URL.String(), "/assets?per_page=100") {
+			fmt.Fprint(w, "[]")
+			return
+		}
+
+		if r.Method == "POST" && strings.Contains(r.URL.String(), "/assets?name=") {
+			if uploads != nil {
+				body, err := ioutil.ReadAll(r.Body)
+				assert.Nil(t, err)
+				uploadsMutex.Lock()
+				uploads[r.URL.Query().Get("name")] = body
+				uploadsMutex.Unlock()
+			}
+
+			fmt.Fprint(w, string(bytes))
+			return
+		}
+
 		fmt.Printf("Unexpected request: %s\n", r.URL.String())
 		w.WriteHeader(http.StatusInternalServerError)
 	}))
@@ -354,6 +490,104 @@ func getReleaseTestServer(t *testing.T, failureURL, failureMethod string) *httpt
 	return server
 }
 
+type testBuild struct {
+	OperatingSystem string
+	Architecture    string
+	FirstClass      bool
+	ArchiveFormat   string
+	Extension       string
+}
+
+var testBuilds = []testBuild{
+	{OperatingSystem: "linux", Architecture: "386", FirstClass: true, ArchiveFormat: "tar.gz"},
+	{OperatingSystem: "linux", Architecture: "amd64", FirstClass: true, ArchiveFormat: "tar.gz"},
+	{OperatingSystem: "linux", Architecture: "arm64", FirstClass: true, ArchiveFormat: "tar.gz"},
+	{OperatingSystem: "linux", Architecture: "s390x", ArchiveFormat: "tar.gz"},
+	{OperatingSystem: "darwin", Architecture: "amd64", FirstClass: true, ArchiveFormat: "tar.gz"},
+	{OperatingSystem: "darwin", Architecture: "arm64", FirstClass: true, ArchiveFormat: "tar.gz"},
+	{OperatingSystem: "solaris", Architecture: "amd64", ArchiveFormat: "tar.gz"},
+	{
+		OperatingSystem: "windows",
+		Architecture:    "386",
+		FirstClass:      true,
+		ArchiveFormat:   "zip",
+		Extension:       ".exe",
+	},
+	{
+		OperatingSystem: "windows",
+		Architecture:    "amd64",
+		FirstClass:      true,
+		ArchiveFormat:   "zip",
+		Extension:       ".exe",
+	},
+}
+
+func getDistListCommand(t *testing.T, mainPath string) *runner.ExpectedCommand {
+	t.Helper()
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	if mainPath == "" {
+		mainPath, err = os.Getwd()
+		assert.Nil(t, err)
+	}
+
+	targets := make([]map[string]interface{}, 0, len(testBuilds))
+	for _, build := range testBuilds {
+		targets = append(targets, map[string]interface{}{
+			"GOOS":         build.OperatingSystem,
+			"GOARCH":       build.Architecture,
+			"CgoSupported": true,
+			"FirstClass":   build.FirstClass,
+		})
+	}
+
+	bytes, err := json.Marshal(targets)
+	assert.Nil(t, err)
+	return runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s tool dist list -json", goExecutable), string(bytes), 0)
+}
+
+func getDistListRunner(t *testing.T, mainPath string) *runner.Test {
+	t.Helper()
+	return &runner.Test{ExpectedCommands: []*runner.ExpectedCommand{getDistListCommand(t, mainPath)}}
+}
+
+// getTagCommands are the git commands that find a clean working tree with tagName at HEAD, both here and on origin
+func getTagCommands(t *testing.T, mainPath, tagName string) []*runner.ExpectedCommand {
+	t.Helper()
+	return getTagCommandsAt(t, mainPath, tagName, "abc123")
+}
+
+// getTagCommandsAt are the git commands that find a clean working tree with tagName at HEAD when HEAD is commit
+func getTagCommandsAt(t *testing.T, mainPath, tagName, commit string) []*runner.ExpectedCommand {
+	t.Helper()
+	if mainPath == "" {
+		var err error
+		mainPath, err = os.Getwd()
+		assert.Nil(t, err)
+	}
+
+	ref := fmt.Sprintf("refs/tags/%s", tagName)
+	return []*runner.ExpectedCommand{
+		runner.NewExpectedCommand(mainPath, "git status --porcelain -- :!dist", "", 0),
+		runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", fmt.Sprintf("%s\n", commit), 0),
+		runner.NewExpectedCommand(mainPath, regexp.QuoteMeta(fmt.Sprintf("git rev-parse --verify --quiet %s^{commit}", ref)), fmt.Sprintf("%s\n", commit), 0),
+		runner.NewExpectedCommand(mainPath, regexp.QuoteMeta(fmt.Sprintf("git ls-remote --tags origin %[1]s %[1]s^{}", ref)), fmt.Sprintf("%s\t%s\n", commit, ref), 0),
+	}
+}
+
+// getReleaseCommands are the commands that releasing every test build of tag runs, checking the tag before building
+func getReleaseCommands(t *testing.T, mainPath string) []*runner.ExpectedCommand {
+	t.Helper()
+	return getReleaseCommandsFiltered(t, mainPath, func(string, string) bool { return true })
+}
+
+// getReleaseCommandsFiltered are the commands that releasing the test builds of tag that are included runs
+func getReleaseCommandsFiltered(t *testing.T, mainPath string, include func(operatingSystem, architecture string) bool) []*runner.ExpectedCommand {
+	t.Helper()
+	expectedCommands := getExpectedCommandsFiltered(t, mainPath, include)
+	return append(expectedCommands[:2], append(getTagCommands(t, mainPath, "tag"), expectedCommands[2:]...)...)
+}
+
 func TestHelperProcess(*testing.T) {
 	runner.ErrorCodeHelper()
 }
@@ -364,72 +598,46 @@ func cleanUp(t *testing.T, mainPath string) {
 	assert.Nil(t, err)
 }
 
+// createFiles stands in for the binaries that go build would write to the dist directory in path
 func createFiles(t *testing.T, path, tagName string) {
 	t.Helper()
-	err := os.Mkdir(path, 0777)
-	assert.Nil(t, err)
-	for _, build := range command.ValidBuilds {
-		for _, architecture := range build.Architectures {
-			err = ioutil.WriteFile(
-				fmt.Sprintf("%s/projectName-%s-%s-go1.8-%s%s", path, build.OperatingSystem, architecture, tagName, build.Extension),
-				[]byte("foo"),
-				0777,
-			)
-			assert.Nil(t, err)
-			err = ioutil.WriteFile(
-				fmt.Sprintf("%s/projectName-%s-%s-go1.8-%s%s%s", path, build.OperatingSystem, architecture, tagName, build.Extension, build.CompressExtension),
-				[]byte("foo"),
-				0777,
-			)
-			assert.Nil(t, err)
-		}
+	for _, build := range testBuilds {
+		writeTestFile(t, distFile(path, build.OperatingSystem, build.Architecture, fmt.Sprintf("projectName-%s-%s-go1.8-%s%s", build.OperatingSystem, build.Architecture, tagName, build.Extension)), "foo", 0777)
 	}
 }
 
-func getExpectedCommands(t *testing.T, mainPath string) []*runner.ExpectedCommand {
+// distFile is the path of a file in the folder for a target in the dist directory of mainPath
+func distFile(mainPath, operatingSystem, architecture, fileName string) string {
+	return fmt.Sprintf("%s/dist/%s_%s/%s", mainPath, operatingSystem, architecture, fileName)
+}
+
+func getExpectedCommandsFiltered(t *testing.T, mainPath string, include func(operatingSystem, architecture string) bool) []*runner.ExpectedCommand {
 	t.Helper()
 	goExecutable, err := exec.LookPath("go")
 	assert.Nil(t, err)
-	expectedCommands := []*runner.ExpectedCommand{}
-	for _, build := range command.ValidBuilds {
-		for _, architecture := range build.Architectures {
-			fileName := fmt.Sprintf("%s/projectName-%s-%s-go1.8-tag%s", mainPath, build.OperatingSystem, architecture, build.Extension)
-			extra := ""
-			if build.IncludeTargetParameter {
-				extra = fmt.Sprintf("%s%s ", fileName, build.CompressExtension)
-			}
-
-			expectedCommands = append(
-				expectedCommands,
-				runner.NewExpectedCommand(
-					mainPath,
-					fmt.Sprintf("%s build -o %s", goExecutable, fileName),
-					"",
-					0,
-				).WithEnvironment([]string{
-					fmt.Sprintf("GOOS=%s", build.OperatingSystem),
-					fmt.Sprintf("GOARCH=%s", architecture),
-					fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH")),
-				}),
-				runner.NewExpectedCommand(
-					mainPath,
-					fmt.Sprintf(
-						"%s %s%s",
-						build.CompressBinary,
-						extra,
-						fileName,
-					),
-					"",
-					0,
-				),
-				runner.NewExpectedCommand(
-					mainPath,
-					fmt.Sprintf("%s version", goExecutable),
-					"go version go1.8",
-					0,
-				),
-			)
+	expectedCommands := []*runner.ExpectedCommand{
+		getDistListCommand(t, mainPath),
+		runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+	}
+	for _, build := range testBuilds {
+		if !include(build.OperatingSystem, build.Architecture) {
+			continue
 		}
+
+		fileName := distFile(mainPath, build.OperatingSystem, build.Architecture, fmt.Sprintf("projectName-%s-%s-go1.8-tag%s", build.OperatingSystem, build.Architecture, build.Extension))
+		expectedCommands = append(
+			expectedCommands,
+			runner.NewExpectedCommand(
+				mainPath,
+				fmt.Sprintf("%s build -o %s", goExecutable, fileName),
+				"",
+				0,
+			).WithEnvironment([]string{
+				fmt.Sprintf("GOOS=%s", build.OperatingSystem),
+				fmt.Sprintf("GOARCH=%s", build.Architecture),
+				fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH")),
+			}),
+		)
 	}
 
 	return expectedCommands
@@ -0,0 +1,223 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/guywithnose/runner"
+	"github.com/urfave/cli"
+)
+
+// ReproduceFlags are the valid reproduce parameters
+var ReproduceFlags = append(
+	flagsNamed(
+		"token",
+		"apiUrl",
+		"mainPath",
+		"config",
+		"os",
+		"arch",
+		"target",
+		"firstClassOnly",
+		"ldflags",
+		"tags",
+		"trimpath",
+		"buildmode",
+		"gcflags",
+		"checksumAlgorithm",
+		"dist",
+		"maxAttempts",
+		"buildConcurrency",
+	),
+	cli.StringFlag{
+		Name:  "checksums",
+		Usage: "The checksums file to compare the rebuilt archives with (default: the checksums file on the release)",
+	},
+)
+
+// CmdReproduce rebuilds the archives for a tag and checks that they match the checksums file of its release
+func CmdReproduce(cmdWrapper runner.Builder) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		return cmdReproduceHelper(c, cmdWrapper)
+	}
+}
+
+func cmdReproduceHelper(c *cli.Context, cmdWrapper runner.Builder) error {
+	if c.String("token") == "" && c.String("checksums") == "" {
+		return cli.NewExitError("You must specify a token or a checksums file", 1)
+	}
+
+	mainPath, err := getMainPath(c)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(c.String("config"), mainPath)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	// There is no point comparing builds that are not reproducible
+	cfg.Builds.Reproducible = true
+
+	owner, repo, tagName, projectName, err := releaseArguments(c, cmdWrapper, cfg, mainPath, "Usage: \"goRelease reproduce {owner} {repo} {tagName} {projectName} --token {token}\"")
+	if err != nil {
+		return err
+	}
+
+	buildConcurrency, err := concurrencyOption(c, "buildConcurrency", defaultBuildConcurrency)
+	if err != nil {
+		return err
+	}
+
+	err = checkTagCheckedOut(cmdWrapper, mainPath, tagName)
+	if err != nil {
+		return err
+	}
+
+	dist := c.String("dist")
+	if dist == "" {
+		dist, err = ioutil.TempDir("", "goRelease-reproduce")
+		if err != nil {
+			return fmt.Errorf("Unable to create a directory to build in: %v", err)
+		}
+
+		defer func() {
+			_ = os.RemoveAll(dist)
+		}()
+	}
+
+	data, targets, err := prepareBuilds(c, cmdWrapper, cfg, mainPath, projectName, tagName, dist)
+	if err != nil {
+		return err
+	}
+
+	cfg.Checksums.Algorithm = stringOption(c.String("checksumAlgorithm"), cfg.Checksums.Algorithm)
+	sums, err := newChecksums(cfg.Checksums, data, dist)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	released, err := releasedChecksums(c, cfg, owner, repo, tagName, path.Base(sums.fileName))
+	if err != nil {
+		return err
+	}
+
+	results := newReleaseResults(c.App.ErrWriter, targets)
+	eachTarget(targets, buildConcurrency, func(target buildTarget) []artifact {
+		if !runBuild(cmdWrapper, &target, mainPath, results) {
+			return nil
+		}
+
+		err := writeArchive(target.ArchiveFormat, target.ArchivePath, target.Directory, target.archiveFiles(), target.Data.archiveModTime())
+		if err != nil {
+			results.failed(target.String(), stagePackage, "Could not archive binary for %s: %v\n", target, err)
+			return nil
+		}
+
+		results.succeeded(target.String(), stagePackage)
+		compareArchive(target, sums, released, results)
+		return nil
+	}, nil)
+
+	results.writeSummary(c.App.Writer)
+	return results.err(false, nil)
+}
+
+// checkTagCheckedOut makes sure that what is about to be built is the tag
+func checkTagCheckedOut(cmdWrapper runner.Builder, mainPath, tagName string) error {
+	head, err := getCommit(cmdWrapper, mainPath)
+	if err != nil {
+		return err
+	}
+
+	output, err := cmdWrapper.New(mainPath, "git", "rev-parse", fmt.Sprintf("%s^{commit}", tagName)).Output()
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Unable to find the commit for %s: %v", tagName, err), 1)
+	}
+
+	tagCommit := strings.TrimSpace(string(output))
+	if tagCommit != head {
+		return cli.NewExitError(fmt.Sprintf("%s is %s but HEAD is %s, check out the tag to reproduce it", tagName, tagCommit, head), 1)
+	}
+
+	return nil
+}
+
+// releasedChecksums reads the checksums given with --checksums, or downloads the checksums file from the release
+func releasedChecksums(c *cli.Context, cfg *config, owner, repo, tagName, fileName string) (map[string]string, error) {
+	if c.String("checksums") != "" {
+		contents, err := ioutil.ReadFile(c.String("checksums"))
+		if err != nil {
+			return nil, cli.NewExitError(fmt.Sprintf("Unable to read checksums file: %v", err), 1)
+		}
+
+		return readChecksums(contents), nil
+	}
+
+	if cfg.Checksums.Disable {
+		return nil, cli.NewExitError("The release does not have a checksums file because checksums are disabled, use --checksums", 1)
+	}
+
+	token := c.String("token")
+	apiURL := stringOption(c.String("apiUrl"), cfg.Release.APIURL)
+	client, err := getGithubClient(&token, &apiURL, newRetrier(cfg.Retry, c.Int("maxAttempts"), c.App.ErrWriter))
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := findRelease(client, owner, repo, tagName)
+	if err != nil {
+		return nil, err
+	}
+
+	if release == nil {
+		return nil, cli.NewExitError(fmt.Sprintf("There is no release for %s", tagName), 1)
+	}
+
+	assets, err := getAssets(client, release.GetID(), owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, asset := range assets {
+		if asset.GetName() != fileName {
+			continue
+		}
+
+		contents, err := downloadAsset(client, owner, repo, asset)
+		if err != nil {
+			return nil, err
+		}
+
+		return readChecksums(contents), nil
+	}
+
+	return nil, cli.NewExitError(fmt.Sprintf("The release for %s does not have %s", tagName, fileName), 1)
+}
+
+// compareArchive checks the checksum of a rebuilt archive against the released one
+func compareArchive(target buildTarget, sums *checksums, released map[string]string, results *releaseResults) {
+	name := path.Base(target.ArchivePath)
+	expected, ok := released[name]
+	if !ok {
+		results.failed(target.String(), stageReproduce, "%s is not in the checksums file\n", name)
+		return
+	}
+
+	sum, err := sums.sum(target.ArchivePath)
+	if err != nil {
+		results.failed(target.String(), stageReproduce, "Could not checksum %s: %v\n", name, err)
+		return
+	}
+
+	if sum != expected {
+		results.failed(target.String(), stageReproduce, "%s does not match the release: %s %s was released but %s was built\n", name, sums.algorithm, expected, sum)
+		return
+	}
+
+	results.succeeded(target.String(), stageReproduce)
+}
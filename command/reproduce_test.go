@@ -0,0 +1,307 @@
+package command_test
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestReleaseReproducible(t *testing.T) {
+	uploads := make(map[string][]byte)
+	ts := getReleaseTestServerWithUploads(t, "", "", uploads)
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := getSummaryFlagSet(t, ts.URL, mainPath)
+	set.Bool("reproducible", true, "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n  ldflags: -X main.date={{.Date}}\n")
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			getDistListCommand(t, mainPath),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/tag^{commit}"), "abc123\n", 0),
+			runner.NewExpectedCommand(mainPath, "git log -1 --format=%ct tag --", "1500000000\n", 0),
+			runner.NewExpectedCommand(
+				mainPath,
+				fmt.Sprintf(
+					"%s build -trimpath -ldflags -X main.date=2017-07-14T02:40:00Z -buildid= -o %s",
+					goExecutable,
+					distFile(mainPath, "linux", "amd64", "projectName-linux-amd64-go1.8-tag"),
+				),
+				"",
+				0,
+			).WithEnvironment([]string{
+				"GOOS=linux",
+				"GOARCH=amd64",
+				fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH")),
+				"SOURCE_DATE_EPOCH=1500000000",
+			}),
+		},
+		AnyOrder: true,
+	}
+	expectedRunner.ExpectedCommands = append(expectedRunner.ExpectedCommands, getTagCommands(t, mainPath, "tag")...)
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+	assert.Equal(t, "", errWriter.String())
+
+	modTime := time.Unix(1500000000, 0).UTC()
+	assert.Equal(
+		t,
+		[]archiveEntry{
+			{Name: "projectName-linux-amd64-go1.8-tag/", Mode: os.ModeDir | 0755, ModTime: modTime},
+			{Name: "projectName-linux-amd64-go1.8-tag/projectName", Mode: 0755, ModTime: modTime, Content: "foo"},
+		},
+		readTarGz(t, uploads["projectName-linux-amd64-go1.8-tag.tar.gz"]),
+	)
+}
+
+func TestReleaseSourceDateEpoch(t *testing.T) {
+	assert.Nil(t, os.Setenv("SOURCE_DATE_EPOCH", "1500000000"))
+	defer func() {
+		assert.Nil(t, os.Unsetenv("SOURCE_DATE_EPOCH"))
+	}()
+	uploads := make(map[string][]byte)
+	ts := getReleaseTestServerWithUploads(t, "", "", uploads)
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := getSummaryFlagSet(t, ts.URL, mainPath)
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [windows/amd64]\n")
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			getDistListCommand(t, mainPath),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+			runner.NewExpectedCommand(
+				mainPath,
+				fmt.Sprintf("%s build -o %s", goExecutable, distFile(mainPath, "windows", "amd64", "projectName-windows-amd64-go1.8-tag.exe")),
+				"",
+				0,
+			).WithEnvironment([]string{
+				"GOOS=windows",
+				"GOARCH=amd64",
+				fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH")),
+				"SOURCE_DATE_EPOCH=1500000000",
+			}),
+		},
+		AnyOrder: true,
+	}
+	expectedRunner.ExpectedCommands = append(expectedRunner.ExpectedCommands, getTagCommands(t, mainPath, "tag")...)
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []error(nil), expectedRunner.Errors)
+	assert.Equal(t, "", errWriter.String())
+
+	modTime := time.Unix(1500000000, 0).UTC()
+	assert.Equal(
+		t,
+		[]archiveEntry{
+			{Name: "projectName-windows-amd64-go1.8-tag/", Mode: os.ModeDir | 0755, ModTime: modTime},
+			{Name: "projectName-windows-amd64-go1.8-tag/projectName.exe", Mode: 0755, ModTime: modTime, Content: "foo"},
+		},
+		readZip(t, uploads["projectName-windows-amd64-go1.8-tag.zip"]),
+	)
+}
+
+func TestReleaseInvalidSourceDateEpoch(t *testing.T) {
+	assert.Nil(t, os.Setenv("SOURCE_DATE_EPOCH", "yesterday"))
+	defer func() {
+		assert.Nil(t, os.Unsetenv("SOURCE_DATE_EPOCH"))
+	}()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := getSummaryFlagSet(t, "", mainPath)
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "")
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			getDistListCommand(t, mainPath),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+		},
+	}
+	app, _, _ := appWithTestWriters()
+	err = command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Invalid SOURCE_DATE_EPOCH yesterday: strconv.ParseInt: parsing \"yesterday\": invalid syntax")
+}
+
+func TestReproduce(t *testing.T) {
+	assets := newTestReleaseAssets()
+	ts, release := getDigestTestServer(t, assets)
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	releaseSet := getSummaryFlagSet(t, ts.URL, mainPath)
+	releaseSet.Bool("reproducible", true, "doc")
+	assert.Nil(t, releaseSet.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64, windows/amd64]\n")
+	releaseRunner := &runner.Test{ExpectedCommands: append(getReproducibleCommands(t, mainPath), getTagCommands(t, mainPath, "tag")...), AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(releaseRunner)(cli.NewContext(app, releaseSet, nil)))
+	assert.Equal(t, "", errWriter.String())
+
+	set := getReproduceFlagSet(t, ts.URL, mainPath)
+	createFiles(t, mainPath, "tag")
+	app, writer, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdReproduce(getReproduceRunner(t, mainPath, "abc123"))(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "", errWriter.String())
+	assert.Equal(
+		t,
+		"TARGET         BUILD  PACKAGE  REPRODUCE\n"+
+			"linux/amd64    ok     ok       ok\n"+
+			"windows/amd64  ok     ok       ok\n",
+		writer.String(),
+	)
+}
+
+func TestReproduceMismatch(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	checksumsFile := fmt.Sprintf("%s/checksums.txt", mainPath)
+	assert.Nil(t, ioutil.WriteFile(checksumsFile, []byte("0123  projectName-linux-amd64-go1.8-tag.tar.gz\n"), 0644))
+	set := getReproduceFlagSet(t, "", mainPath)
+	assert.Nil(t, set.Set("token", ""))
+	set.String("checksums", checksumsFile, "doc")
+	app, writer, errWriter := appWithTestWriters()
+	err := command.CmdReproduce(getReproduceRunner(t, mainPath, "abc123"))(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Failed targets: linux/amd64, windows/amd64")
+	assert.Regexp(
+		t,
+		"^projectName-linux-amd64-go1.8-tag.tar.gz does not match the release: sha256 0123 was released but [0-9a-f]{64} was built\n$",
+		regexp.MustCompile("(?m)^projectName-linux.*\n").FindString(errWriter.String()),
+	)
+	assert.Contains(t, errWriter.String(), "projectName-windows-amd64-go1.8-tag.zip is not in the checksums file\n")
+	assert.Equal(
+		t,
+		"TARGET         BUILD  PACKAGE  REPRODUCE\n"+
+			"linux/amd64    ok     ok       failed\n"+
+			"windows/amd64  ok     ok       failed\n",
+		writer.String(),
+	)
+}
+
+func TestReproduceTagNotCheckedOut(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	set := getReproduceFlagSet(t, "", mainPath)
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "def456\n", 0),
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse tag^{commit}"), "abc123\n", 0),
+		},
+	}
+	app, _, _ := appWithTestWriters()
+	err := command.CmdReproduce(expectedRunner)(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "tag is abc123 but HEAD is def456, check out the tag to reproduce it")
+}
+
+func TestReproduceNoRelease(t *testing.T) {
+	ts := getReleaseTestServer(t, "", "")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	defer cleanUp(t, mainPath)
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "other", "projectName"}))
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	expectedRunner := &runner.Test{
+		ExpectedCommands: []*runner.ExpectedCommand{
+			runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", "abc123\n", 0),
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse other^{commit}"), "abc123\n", 0),
+			getDistListCommand(t, mainPath),
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+			runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/other^{commit}"), "abc123\n", 0),
+			runner.NewExpectedCommand(mainPath, "git log -1 --format=%ct other --", "1500000000\n", 0),
+		},
+	}
+	app, _, _ := appWithTestWriters()
+	err = command.CmdReproduce(expectedRunner)(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "There is no release for other")
+}
+
+func TestReproduceUsage(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	app, _, _ := appWithTestWriters()
+	err := command.CmdReproduce(&runner.Test{})(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "You must specify a token or a checksums file")
+}
+
+// getReproduceFlagSet reproduces tag of owner/repo for linux/amd64 and windows/amd64, building in the dist directory
+// of mainPath
+func getReproduceFlagSet(t *testing.T, url, mainPath string) *flag.FlagSet {
+	t.Helper()
+	set := getSummaryFlagSet(t, url, mainPath)
+	set.String("dist", fmt.Sprintf("%s/dist", mainPath), "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64, windows/amd64]\n")
+	return set
+}
+
+// getReproduceRunner builds linux/amd64 and windows/amd64 with tag checked out at commit
+func getReproduceRunner(t *testing.T, mainPath, commit string) *runner.Test {
+	t.Helper()
+	expectedCommands := []*runner.ExpectedCommand{
+		runner.NewExpectedCommand(mainPath, "git rev-parse HEAD", fmt.Sprintf("%s\n", commit), 0),
+		runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse tag^{commit}"), fmt.Sprintf("%s\n", commit), 0),
+	}
+	return &runner.Test{ExpectedCommands: append(expectedCommands, getReproducibleCommands(t, mainPath)...), AnyOrder: true}
+}
+
+// getReproducibleCommands build linux/amd64 and windows/amd64 reproducibly from tag, which was committed at 1500000000
+func getReproducibleCommands(t *testing.T, mainPath string) []*runner.ExpectedCommand {
+	t.Helper()
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	expectedCommands := []*runner.ExpectedCommand{
+		getDistListCommand(t, mainPath),
+		runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s version", goExecutable), "go version go1.8", 0),
+		runner.NewExpectedCommand(mainPath, regexp.QuoteMeta("git rev-parse --verify --quiet refs/tags/tag^{commit}"), "abc123\n", 0),
+		runner.NewExpectedCommand(mainPath, "git log -1 --format=%ct tag --", "1500000000\n", 0),
+	}
+	for _, operatingSystem := range []string{"linux", "windows"} {
+		extension := ""
+		if operatingSystem == "windows" {
+			extension = ".exe"
+		}
+
+		fileName := distFile(mainPath, operatingSystem, "amd64", fmt.Sprintf("projectName-%s-amd64-go1.8-tag%s", operatingSystem, extension))
+		expectedCommands = append(
+			expectedCommands,
+			runner.NewExpectedCommand(mainPath, fmt.Sprintf("%s build -trimpath -ldflags -buildid= -o %s", goExecutable, fileName), "", 0).WithEnvironment([]string{
+				fmt.Sprintf("GOOS=%s", operatingSystem),
+				"GOARCH=amd64",
+				fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH")),
+				"SOURCE_DATE_EPOCH=1500000000",
+			}),
+		)
+	}
+
+	return expectedCommands
+}
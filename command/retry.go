@@ -0,0 +1,141 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultRetryDelay  = time.Second
+	maxRetryDelay      = time.Minute
+	// maxRateLimitWait is the longest a call waits for the rate limit to reset before it gives up
+	maxRateLimitWait = 5 * time.Minute
+)
+
+type retryConfig struct {
+	MaxAttempts int    `yaml:"maxAttempts"`
+	Delay       string `yaml:"delay"`
+}
+
+// retrier repeats github API calls that fail for reasons that may go away: network errors, server errors and rate
+// limits.  Between attempts it waits for the rate limit to reset, for as long as github asks with Retry-After, or
+// for an exponentially growing delay with jitter.  A rate limit that resets more than maxRateLimitWait from now fails
+// instead.
+type retrier struct {
+	maxAttempts int
+	delay       time.Duration
+	errWriter   io.Writer
+}
+
+func newRetrier(cfg retryConfig, maxAttempts int, errWriter io.Writer) *retrier {
+	if maxAttempts == 0 {
+		maxAttempts = cfg.MaxAttempts
+	}
+
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	delay := defaultRetryDelay
+	if cfg.Delay != "" {
+		// The config has already been validated
+		delay, _ = time.ParseDuration(cfg.Delay)
+	}
+
+	return &retrier{
+		maxAttempts: maxAttempts,
+		delay:       delay,
+		errWriter:   errWriter,
+	}
+}
+
+// do runs call until it succeeds, fails in a way that will not go away or runs out of attempts
+func (r *retrier) do(description string, call func() (*github.Response, error)) error {
+	for attempt := 1; ; attempt++ {
+		response, err := call()
+		if err == nil {
+			return nil
+		}
+
+		wait, retryable := r.wait(attempt, response, err)
+		if !retryable || attempt >= r.maxAttempts {
+			return err
+		}
+
+		if limit, ok := err.(*github.RateLimitError); ok && wait > maxRateLimitWait {
+			return fmt.Errorf("The github rate limit is used up until %s, which is too long to wait: %v", limit.Rate.Reset.Time.UTC().Format(time.RFC3339), err)
+		}
+
+		fmt.Fprintf(r.errWriter, "Retrying %s in %v (attempt %d of %d): %v\n", description, wait.Round(time.Millisecond), attempt+1, r.maxAttempts, err)
+		time.Sleep(wait)
+	}
+}
+
+// wait decides whether a failed call is worth trying again and how long to wait first
+func (r *retrier) wait(attempt int, response *github.Response, err error) (time.Duration, bool) {
+	switch typedErr := err.(type) {
+	case *github.RateLimitError:
+		wait := time.Until(typedErr.Rate.Reset.Time)
+		if wait < 0 {
+			wait = 0
+		}
+
+		return wait, true
+	case *github.AbuseRateLimitError:
+		if typedErr.RetryAfter != nil {
+			return *typedErr.RetryAfter, true
+		}
+
+		return r.backoff(attempt), true
+	case *github.ErrorResponse:
+		return r.statusWait(attempt, typedErr.Response)
+	case *url.Error:
+		return r.backoff(attempt), true
+	}
+
+	if response != nil {
+		return r.statusWait(attempt, response.Response)
+	}
+
+	return 0, false
+}
+
+// statusWait retries server errors and 429s, honoring a Retry-After header
+func (r *retrier) statusWait(attempt int, response *http.Response) (time.Duration, bool) {
+	if response == nil || (response.StatusCode < 500 && response.StatusCode != http.StatusTooManyRequests) {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	return r.backoff(attempt), true
+}
+
+// backoff doubles the delay for every attempt, up to maxRetryDelay, and picks a random wait between half of that and
+// all of it so that concurrent uploads do not retry in lockstep
+func (r *retrier) backoff(attempt int) time.Duration {
+	delay := r.delay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	if delay <= 1 {
+		return delay
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
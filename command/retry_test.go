@@ -0,0 +1,267 @@
+package command_test
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestReleaseRetryUpload(t *testing.T) {
+	checksumsName := "projectName_tag_checksums.txt"
+	uploads := make(map[string][]byte)
+	requests := []string{}
+	failed := false
+	ts, release := getRetryTestServer(t, uploads, func(w http.ResponseWriter, r *http.Request) bool {
+		switch {
+		case r.Method == "POST" && r.URL.Query().Get("name") == checksumsName && !failed:
+			failed = true
+			requests = append(requests, "POST")
+			w.WriteHeader(http.StatusBadGateway)
+			return true
+		case r.Method == "GET" && r.URL.String() == "/repos/owner/repo/releases/1/assets?per_page=100" && failed:
+			requests = append(requests, "GET")
+			name := checksumsName
+			id := 7
+			bytes, _ := json.Marshal([]*github.ReleaseAsset{{ID: &id, Name: &name}})
+			fmt.Fprint(w, string(bytes))
+			return true
+		case r.Method == "DELETE" && r.URL.String() == "/repos/owner/repo/releases/assets/7":
+			requests = append(requests, "DELETE")
+			w.WriteHeader(http.StatusNoContent)
+			return true
+		case r.Method == "POST" && r.URL.Query().Get("name") == checksumsName:
+			requests = append(requests, "POST")
+		}
+
+		return false
+	})
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := getRetryFlagSet(t, ts, mainPath)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "retry:\n  delay: 1ms\n")
+	expectedRunner := &runner.Test{ExpectedCommands: getReleaseCommands(t, mainPath), AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(
+		t,
+		fmt.Sprintf(
+			"Retrying uploading %s in 1ms (attempt 2 of 5): POST %s/repos/owner/repo/releases/1/assets?name=%s: 502  []\n",
+			checksumsName,
+			ts.URL,
+			checksumsName,
+		),
+		errWriter.String(),
+	)
+	assert.Equal(t, []string{"POST", "GET", "DELETE", "POST"}, requests)
+	assert.Contains(t, uploads, checksumsName)
+}
+
+func TestReleaseRetryRateLimit(t *testing.T) {
+	limited := false
+	ts, release := getRetryTestServer(t, nil, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.String() == "/repos/owner/repo/releases?per_page=100" && !limited {
+			limited = true
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Unix()))
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message":"API rate limit exceeded for 127.0.0.1."}`)
+			return true
+		}
+
+		return false
+	})
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := getRetryFlagSet(t, ts, mainPath)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	expectedRunner := &runner.Test{ExpectedCommands: getReleaseCommands(t, mainPath), AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.True(
+		t,
+		strings.HasPrefix(errWriter.String(), "Retrying listing releases in 0s (attempt 2 of 5): GET "),
+		"%q is not a rate limit retry",
+		errWriter.String(),
+	)
+	assert.True(t, limited)
+}
+
+func TestReleaseRateLimitTooLong(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	ts, release := getRetryTestServer(t, nil, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.String() != "/repos/owner/repo/releases?per_page=100" {
+			return false
+		}
+
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", reset.Unix()))
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message":"API rate limit exceeded for 127.0.0.1."}`)
+		return true
+	})
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := getRetryFlagSet(t, ts, mainPath)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	expectedRunner := &runner.Test{ExpectedCommands: getReleaseCommands(t, mainPath), AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	err := command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil))
+	if assert.NotNil(t, err) {
+		assert.True(
+			t,
+			strings.HasPrefix(err.Error(), fmt.Sprintf("The github rate limit is used up until %s, which is too long to wait: GET ", reset.Format(time.RFC3339))),
+			err.Error(),
+		)
+	}
+
+	assert.Equal(t, "", errWriter.String())
+}
+
+func TestReleaseRetryCreateRelease(t *testing.T) {
+	creates := 0
+	ts, release := getRetryTestServer(t, nil, func(w http.ResponseWriter, r *http.Request) bool {
+		switch {
+		case r.Method == "POST" && r.URL.String() == "/repos/owner/repo/releases":
+			// The release is created but the response is lost
+			creates++
+			w.WriteHeader(http.StatusBadGateway)
+			return true
+		case r.URL.String() == "/repos/owner/repo/releases?per_page=100":
+			releases := []*github.RepositoryRelease{}
+			if creates != 0 {
+				tagName, id, draft := "tag", 2, true
+				releases = append(releases, &github.RepositoryRelease{TagName: &tagName, ID: &id, Draft: &draft})
+			}
+
+			bytes, _ := json.Marshal(releases)
+			fmt.Fprint(w, string(bytes))
+			return true
+		}
+
+		return false
+	})
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := getRetryFlagSet(t, ts, mainPath)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "release:\n  skipVersionCheck: true\nretry:\n  delay: 1ms\n")
+	expectedRunner := &runner.Test{ExpectedCommands: getReleaseCommands(t, mainPath), AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(
+		t,
+		fmt.Sprintf("Retrying creating release tag in 1ms (attempt 2 of 5): POST %s/repos/owner/repo/releases: 502  []\n", ts.URL),
+		errWriter.String(),
+	)
+	assert.Equal(t, 1, creates)
+}
+
+func TestReleaseRetryAbuseRateLimit(t *testing.T) {
+	attempts := 0
+	ts, release := getRetryTestServer(t, nil, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.String() == "/repos/owner/repo/releases?per_page=100" {
+			attempts++
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message":"You have triggered an abuse detection mechanism","documentation_url":"https://developer.github.com/v3#abuse-rate-limits"}`)
+			return true
+		}
+
+		return false
+	})
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := getRetryFlagSet(t, ts, mainPath)
+	set.Int("maxAttempts", 2, "doc")
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	app, _, errWriter := appWithTestWriters()
+	err := command.CmdRelease(&runner.Test{ExpectedCommands: getReleaseCommands(t, mainPath), AnyOrder: true})(cli.NewContext(app, set, nil))
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.True(
+		t,
+		strings.HasPrefix(errWriter.String(), "Retrying listing releases in 0s (attempt 2 of 2): GET "),
+		"%q is not an abuse rate limit retry",
+		errWriter.String(),
+	)
+}
+
+func TestReleaseRetryClientError(t *testing.T) {
+	attempts := 0
+	ts, release := getRetryTestServer(t, nil, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.String() == "/repos/owner/repo/releases?per_page=100" {
+			attempts++
+			w.WriteHeader(http.StatusUnauthorized)
+			return true
+		}
+
+		return false
+	})
+	defer ts.Close()
+	defer release.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := getRetryFlagSet(t, ts, mainPath)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	app, _, errWriter := appWithTestWriters()
+	err := command.CmdRelease(&runner.Test{ExpectedCommands: getReleaseCommands(t, mainPath), AnyOrder: true})(cli.NewContext(app, set, nil))
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, "", errWriter.String())
+}
+
+func getRetryFlagSet(t *testing.T, ts *httptest.Server, mainPath string) *flag.FlagSet {
+	t.Helper()
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	return set
+}
+
+// getRetryTestServer puts intercept in front of the release test server, it returns true when it answered a request
+func getRetryTestServer(
+	t *testing.T,
+	uploads map[string][]byte,
+	intercept func(http.ResponseWriter, *http.Request) bool,
+) (*httptest.Server, *httptest.Server) {
+	t.Helper()
+	release := getReleaseTestServerWithUploads(t, "", "", uploads)
+	mutex := sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		handled := intercept(w, r)
+		mutex.Unlock()
+		if !handled {
+			release.Config.Handler.ServeHTTP(w, r)
+		}
+	}))
+	return server, release
+}
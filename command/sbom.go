@@ -0,0 +1,369 @@
+package command
+
+import (
+	"crypto/sha256"
+	"debug/buildinfo"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// sbomFormats are the SBOM formats that can be generated from the build info embedded in each binary
+var sbomFormats = []string{"cyclonedx", "spdx"}
+
+type sbomConfig struct {
+	Formats []string `yaml:"formats"`
+}
+
+// sbomFile is an SBOM that will be written for a target
+type sbomFile struct {
+	Format   string
+	FileName string
+}
+
+// sbomFileNames names the SBOMs for an asset.  A single SBOM is named <asset>.sbom.json, when there are several the
+// format is included in the name (<asset>.cyclonedx.sbom.json).
+func sbomFileNames(assetPath string, formats []string) []sbomFile {
+	files := make([]sbomFile, 0, len(formats))
+	for _, format := range formats {
+		fileName := fmt.Sprintf("%s.sbom.json", assetPath)
+		if len(formats) > 1 {
+			fileName = fmt.Sprintf("%s.%s.sbom.json", assetPath, format)
+		}
+
+		files = append(files, sbomFile{Format: format, FileName: fileName})
+	}
+
+	return files
+}
+
+// sbomModule is a module listed in an SBOM
+type sbomModule struct {
+	Path    string
+	Version string
+	Hash    string
+}
+
+func (module sbomModule) purl() string {
+	return fmt.Sprintf("pkg:golang/%s@%s", module.Path, module.Version)
+}
+
+// sbomInfo is everything about a binary that goes into its SBOMs
+type sbomInfo struct {
+	Asset        string
+	Main         sbomModule
+	Dependencies []sbomModule
+	GoVersion    string
+	Settings     []debug.BuildSetting
+	Timestamp    string
+}
+
+// writeSBOMs reads the build info from a target's binary and writes each of its SBOMs
+func writeSBOMs(target buildTarget) error {
+	if len(target.SBOMs) == 0 {
+		return nil
+	}
+
+	info, err := readSBOMInfo(target)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range target.SBOMs {
+		var document interface{}
+		if file.Format == "spdx" {
+			document = spdxDocument(info)
+		} else {
+			document = cycloneDXDocument(info)
+		}
+
+		contents, err := json.MarshalIndent(document, "", "  ")
+		if err == nil {
+			err = ioutil.WriteFile(file.FileName, append(contents, '\n'), 0644)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readSBOMInfo(target buildTarget) (sbomInfo, error) {
+	buildInfo, err := buildinfo.ReadFile(target.FileName)
+	if err != nil {
+		return sbomInfo{}, err
+	}
+
+	binaryHash, err := fileSHA256(target.FileName)
+	if err != nil {
+		return sbomInfo{}, err
+	}
+
+	mainPath := buildInfo.Main.Path
+	if mainPath == "" {
+		mainPath = buildInfo.Path
+	}
+
+	info := sbomInfo{
+		Asset:     path.Base(target.ArchivePath),
+		Main:      sbomModule{Path: mainPath, Version: target.Data.Tag, Hash: binaryHash},
+		GoVersion: buildInfo.GoVersion,
+		Settings:  buildInfo.Settings,
+		Timestamp: target.Data.Date,
+	}
+
+	for _, dependency := range buildInfo.Deps {
+		if dependency.Replace != nil {
+			dependency = dependency.Replace
+		}
+
+		info.Dependencies = append(info.Dependencies, sbomModule{
+			Path:    dependency.Path,
+			Version: dependency.Version,
+			Hash:    moduleHash(dependency.Sum),
+		})
+	}
+
+	sort.Slice(info.Dependencies, func(i, j int) bool {
+		return info.Dependencies[i].Path < info.Dependencies[j].Path
+	})
+
+	return info, nil
+}
+
+// moduleHash converts a go.sum hash (h1:base64) to hex.  h1 hashes are SHA-256 so they can be listed as SHA-256 hashes.
+func moduleHash(sum string) string {
+	if !strings.HasPrefix(sum, "h1:") {
+		return ""
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sum, "h1:"))
+	if err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(decoded)
+}
+
+func fileSHA256(fileName string) (string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	digest := sha256.New()
+	_, err = io.Copy(digest, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// sbomUUID derives a UUID from the binary's hash so the same binary always gets the same SBOM
+func sbomUUID(info sbomInfo, format string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%s:%s", format, info.Asset, info.Main.Hash)))
+	sum[6] = sum[6]&0x0F | 0x50
+	sum[8] = sum[8]&0x3F | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+type cycloneDXBOM struct {
+	BOMFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	SerialNumber string                `json:"serialNumber"`
+	Version      int                   `json:"version"`
+	Metadata     cycloneDXMetadata     `json:"metadata"`
+	Components   []cycloneDXComponent  `json:"components"`
+	Dependencies []cycloneDXDependency `json:"dependencies"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp,omitempty"`
+	Tools     cycloneDXTools     `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXTools struct {
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXComponent struct {
+	Type       string              `json:"type"`
+	BOMRef     string              `json:"bom-ref,omitempty"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	PURL       string              `json:"purl,omitempty"`
+	Hashes     []cycloneDXHash     `json:"hashes,omitempty"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+func cycloneDXDocument(info sbomInfo) cycloneDXBOM {
+	main := cycloneDXModule("application", info.Main)
+	main.Properties = []cycloneDXProperty{{Name: "cdx:gomod:binary:name", Value: info.Asset}, {Name: "go:version", Value: info.GoVersion}}
+	for _, setting := range info.Settings {
+		main.Properties = append(main.Properties, cycloneDXProperty{Name: fmt.Sprintf("go:build:%s", setting.Key), Value: setting.Value})
+	}
+
+	bom := cycloneDXBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: fmt.Sprintf("urn:uuid:%s", sbomUUID(info, "cyclonedx")),
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: info.Timestamp,
+			Tools:     cycloneDXTools{Components: []cycloneDXComponent{{Type: "application", Name: Name, Version: Version}}},
+			Component: main,
+		},
+		Components:   []cycloneDXComponent{},
+		Dependencies: []cycloneDXDependency{{Ref: main.BOMRef, DependsOn: []string{}}},
+	}
+
+	for _, dependency := range info.Dependencies {
+		component := cycloneDXModule("library", dependency)
+		bom.Components = append(bom.Components, component)
+		bom.Dependencies[0].DependsOn = append(bom.Dependencies[0].DependsOn, component.BOMRef)
+	}
+
+	return bom
+}
+
+func cycloneDXModule(componentType string, module sbomModule) cycloneDXComponent {
+	component := cycloneDXComponent{
+		Type:    componentType,
+		BOMRef:  module.purl(),
+		Name:    module.Path,
+		Version: module.Version,
+		PURL:    module.purl(),
+	}
+	if module.Hash != "" {
+		component.Hashes = []cycloneDXHash{{Algorithm: "SHA-256", Content: module.Hash}}
+	}
+
+	return component
+}
+
+type spdxDocumentJSON struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created,omitempty"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+	Comment          string            `json:"comment,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+func spdxDocument(info sbomInfo) spdxDocumentJSON {
+	settings := make([]string, 0, len(info.Settings))
+	for _, setting := range info.Settings {
+		settings = append(settings, fmt.Sprintf("%s=%s", setting.Key, setting.Value))
+	}
+
+	main := spdxModule("SPDXRef-Package-main", info.Main)
+	main.Comment = fmt.Sprintf("Built with %s. Build settings: %s", info.GoVersion, strings.Join(settings, " "))
+	document := spdxDocumentJSON{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              info.Asset,
+		DocumentNamespace: fmt.Sprintf("https://spdx.org/spdxdocs/%s-%s", info.Asset, sbomUUID(info, "spdx")),
+		CreationInfo:      spdxCreationInfo{Created: info.Timestamp, Creators: []string{fmt.Sprintf("Tool: %s-%s", Name, Version)}},
+		Packages:          []spdxPackage{main},
+		Relationships:     []spdxRelationship{{Element: "SPDXRef-DOCUMENT", Type: "DESCRIBES", Related: main.SPDXID}},
+	}
+
+	for index, dependency := range info.Dependencies {
+		dependencyPackage := spdxModule(fmt.Sprintf("SPDXRef-Package-%d", index+1), dependency)
+		document.Packages = append(document.Packages, dependencyPackage)
+		document.Relationships = append(
+			document.Relationships,
+			spdxRelationship{Element: main.SPDXID, Type: "DEPENDS_ON", Related: dependencyPackage.SPDXID},
+		)
+	}
+
+	return document
+}
+
+func spdxModule(id string, module sbomModule) spdxPackage {
+	spdxModule := spdxPackage{
+		SPDXID:           id,
+		Name:             module.Path,
+		VersionInfo:      module.Version,
+		DownloadLocation: "NOASSERTION",
+		LicenseConcluded: "NOASSERTION",
+		LicenseDeclared:  "NOASSERTION",
+		CopyrightText:    "NOASSERTION",
+		ExternalRefs:     []spdxExternalRef{{Category: "PACKAGE-MANAGER", Type: "purl", Locator: module.purl()}},
+	}
+	if module.Hash != "" {
+		spdxModule.Checksums = []spdxChecksum{{Algorithm: "SHA256", Value: module.Hash}}
+	}
+
+	return spdxModule
+}
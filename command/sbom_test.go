@@ -0,0 +1,144 @@
+package command_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestReleaseSBOM(t *testing.T) {
+	uploads := make(map[string][]byte)
+	ts := getReleaseTestServerWithUploads(t, "", "", uploads)
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	binary := copyTestBinary(t, distFile(mainPath, "linux", "amd64", "projectName-linux-amd64-go1.8-tag"))
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\nsbom:\n  formats: [cyclonedx, spdx]\n")
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return operatingSystem == "linux" && architecture == "amd64"
+	})
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.Nil(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, "", errWriter.String())
+	assert.Equal(t, 5, len(uploads))
+	binarySum := sha256.Sum256(binary)
+
+	var cycloneDX struct {
+		BOMFormat string `json:"bomFormat"`
+		Metadata  struct {
+			Component struct {
+				Name       string `json:"name"`
+				Version    string `json:"version"`
+				PURL       string `json:"purl"`
+				Hashes     []map[string]string
+				Properties []map[string]string
+			} `json:"component"`
+		} `json:"metadata"`
+	}
+	assert.Nil(t, json.Unmarshal(uploads["projectName-linux-amd64-go1.8-tag.tar.gz.cyclonedx.sbom.json"], &cycloneDX))
+	assert.Equal(t, "CycloneDX", cycloneDX.BOMFormat)
+	assert.Equal(t, "tag", cycloneDX.Metadata.Component.Version)
+	assert.Equal(t, fmt.Sprintf("pkg:golang/%s@tag", cycloneDX.Metadata.Component.Name), cycloneDX.Metadata.Component.PURL)
+	assert.Equal(t, []map[string]string{{"alg": "SHA-256", "content": hex.EncodeToString(binarySum[:])}}, cycloneDX.Metadata.Component.Hashes)
+	assert.Contains(t, cycloneDX.Metadata.Component.Properties, map[string]string{"name": "go:build:GOOS", "value": "linux"})
+
+	var spdx struct {
+		SPDXVersion string `json:"spdxVersion"`
+		Packages    []struct {
+			Checksums []map[string]string `json:"checksums"`
+		} `json:"packages"`
+		Relationships []map[string]string `json:"relationships"`
+	}
+	assert.Nil(t, json.Unmarshal(uploads["projectName-linux-amd64-go1.8-tag.tar.gz.spdx.sbom.json"], &spdx))
+	assert.Equal(t, "SPDX-2.3", spdx.SPDXVersion)
+	assert.Equal(t, []map[string]string{{"algorithm": "SHA256", "checksumValue": hex.EncodeToString(binarySum[:])}}, spdx.Packages[0].Checksums)
+	assert.Equal(
+		t,
+		map[string]string{"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": "SPDXRef-Package-main"},
+		spdx.Relationships[0],
+	)
+	assert.Contains(t, string(uploads["projectName_tag_checksums.txt"]), "projectName-linux-amd64-go1.8-tag.tar.gz.spdx.sbom.json\n")
+}
+
+func TestReleaseSBOMFlag(t *testing.T) {
+	uploads := make(map[string][]byte)
+	ts := getReleaseTestServerWithUploads(t, "", "", uploads)
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", ts.URL), "doc")
+	set.String("mainPath", mainPath, "doc")
+	formats := cli.StringSlice{"spdx"}
+	set.Var(&formats, "sbom", "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	copyTestBinary(t, distFile(mainPath, "linux", "amd64", "projectName-linux-amd64-go1.8-tag"))
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64, linux/386]\n")
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return operatingSystem == "linux" && (architecture == "amd64" || architecture == "386")
+	})
+	expectedRunner := &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+	app, _, errWriter := appWithTestWriters()
+	assert.EqualError(t, command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil)), "Failed targets: linux/386")
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(
+		t,
+		"Could not create SBOM for linux/386: could not read Go build info from "+
+			fmt.Sprintf("%s: unrecognized file format\n", distFile(mainPath, "linux", "386", "projectName-linux-386-go1.8-tag")),
+		errWriter.String(),
+	)
+	assert.Contains(t, uploads, "projectName-linux-amd64-go1.8-tag.tar.gz.sbom.json")
+	assert.Contains(t, uploads, "projectName-linux-386-go1.8-tag.tar.gz")
+	assert.Equal(t, 5, len(uploads))
+	_, err = os.Stat(distFile(mainPath, "linux", "386", "projectName-linux-386-go1.8-tag.tar.gz.sbom.json"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReleaseInvalidSBOMFormat(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("mainPath", mainPath, "doc")
+	formats := cli.StringSlice{"swid"}
+	set.Var(&formats, "sbom", "doc")
+	err := set.Parse([]string{"owner", "repo", "tag", "projectName"})
+	assert.Nil(t, err)
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	app, _, _ := appWithTestWriters()
+	err = command.CmdRelease(getDistListRunner(t, mainPath))(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Unknown SBOM format swid (expected one of cyclonedx, spdx)")
+}
+
+// copyTestBinary replaces a fake binary with the test binary so that it has build info to read
+func copyTestBinary(t *testing.T, fileName string) []byte {
+	t.Helper()
+	executable, err := os.Executable()
+	assert.Nil(t, err)
+	binary, err := ioutil.ReadFile(executable)
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(fileName, binary, 0755))
+	return binary
+}
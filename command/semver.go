@@ -0,0 +1,101 @@
+package command
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// semverPattern matches a semantic version (https://semver.org) with an optional leading v
+var semverPattern = regexp.MustCompile(
+	`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)` +
+		`(?:-((?:0|[1-9][0-9]*|[0-9]*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9][0-9]*|[0-9]*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+		`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`,
+)
+
+type semver struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease []string
+	Build      string
+}
+
+// parseSemver reads a tag such as v1.2.3 or 1.2.3-rc.1.  It reports whether the tag is a semantic version.
+func parseSemver(tag string) (semver, bool) {
+	match := semverPattern.FindStringSubmatch(tag)
+	if match == nil {
+		return semver{}, false
+	}
+
+	version := semver{Build: match[5]}
+	version.Major, _ = strconv.Atoi(match[1])
+	version.Minor, _ = strconv.Atoi(match[2])
+	version.Patch, _ = strconv.Atoi(match[3])
+	if match[4] != "" {
+		version.Prerelease = strings.Split(match[4], ".")
+	}
+
+	return version, true
+}
+
+func (version semver) isPrerelease() bool {
+	return len(version.Prerelease) != 0
+}
+
+// compare orders versions by precedence, returning -1, 0 or 1.  Build metadata does not count.
+func (version semver) compare(other semver) int {
+	for _, difference := range []int{version.Major - other.Major, version.Minor - other.Minor, version.Patch - other.Patch} {
+		if difference != 0 {
+			return sign(difference)
+		}
+	}
+
+	// A version without a prerelease comes after the same version with one
+	switch {
+	case !version.isPrerelease() && !other.isPrerelease():
+		return 0
+	case !version.isPrerelease():
+		return 1
+	case !other.isPrerelease():
+		return -1
+	}
+
+	for index := 0; index < len(version.Prerelease) && index < len(other.Prerelease); index++ {
+		difference := comparePrereleaseIdentifiers(version.Prerelease[index], other.Prerelease[index])
+		if difference != 0 {
+			return difference
+		}
+	}
+
+	return sign(len(version.Prerelease) - len(other.Prerelease))
+}
+
+// comparePrereleaseIdentifiers compares numeric identifiers as numbers and the rest as text, numbers coming first
+func comparePrereleaseIdentifiers(identifier, other string) int {
+	number, err := strconv.Atoi(identifier)
+	numeric := err == nil
+	otherNumber, err := strconv.Atoi(other)
+	otherNumeric := err == nil
+	switch {
+	case numeric && otherNumeric:
+		return sign(number - otherNumber)
+	case numeric:
+		return -1
+	case otherNumeric:
+		return 1
+	}
+
+	return strings.Compare(identifier, other)
+}
+
+func sign(difference int) int {
+	switch {
+	case difference < 0:
+		return -1
+	case difference > 0:
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,176 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	pgpErrors "golang.org/x/crypto/openpgp/errors"
+)
+
+const defaultSigningPassphraseEnv = "GO_RELEASE_SIGNING_PASSPHRASE"
+
+// signArtifactsOptions are the values for --signArtifacts and --minisignArtifacts.  checksum only signs the checksums file,
+// all signs every asset.
+var signArtifactsOptions = []string{"checksum", "all"}
+
+// assetSigner creates a detached signature for a file.  Every signer signs the checksums file, and signers that
+// sign every asset also sign each archive.
+type assetSigner interface {
+	signFile(fileName string) (string, error)
+	signatureName(fileName string) string
+	signsEveryAsset() bool
+}
+
+type signingConfig struct {
+	Key           string `yaml:"key"`
+	PassphraseEnv string `yaml:"passphraseEnv"`
+	PublicKey     string `yaml:"publicKey"`
+	Artifacts     string `yaml:"artifacts"`
+}
+
+// pgpSigner writes armored detached signatures (foo.tar.gz.asc) and checks them against the public key if there is one
+type pgpSigner struct {
+	key        *openpgp.Entity
+	publicKeys openpgp.EntityList
+	all        bool
+}
+
+// newPgpSigner loads the signing key, and the public key if one is configured.  Signing a test message up front means
+// a wrong passphrase or a key that does not match the public key fails the release before anything is built.
+func newPgpSigner(cfg signingConfig) (*pgpSigner, error) {
+	artifacts := stringOption(cfg.Artifacts, "checksum")
+	if !contains(signArtifactsOptions, artifacts) {
+		return nil, fmt.Errorf("Unknown signing artifacts %s (expected one of %s)", artifacts, strings.Join(signArtifactsOptions, ", "))
+	}
+
+	data, err := ioutil.ReadFile(cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read signing key: %v", err)
+	}
+
+	key, err := readPgpPrivateKey(data, os.Getenv(stringOption(cfg.PassphraseEnv, defaultSigningPassphraseEnv)))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load signing key %s: %v", cfg.Key, err)
+	}
+
+	signer := &pgpSigner{key: key, all: artifacts == "all"}
+	if cfg.PublicKey == "" {
+		return signer, nil
+	}
+
+	data, err = ioutil.ReadFile(cfg.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read public key: %v", err)
+	}
+
+	signer.publicKeys, err = readPgpKeyRing(data)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load public key %s: %v", cfg.PublicKey, err)
+	}
+
+	message := []byte("goRelease signing check")
+	signature := &bytes.Buffer{}
+	err = openpgp.ArmoredDetachSign(signature, key, bytes.NewReader(message), nil)
+	if err == nil {
+		err = verifyPgpSignature(signer.publicKeys, bytes.NewReader(message), signature.Bytes())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Signing key %s does not match public key %s: %v", cfg.Key, cfg.PublicKey, err)
+	}
+
+	return signer, nil
+}
+
+func (signer *pgpSigner) signsEveryAsset() bool {
+	return signer.all
+}
+
+// signFile writes the signature for a file next to it and returns the signature's file name
+func (signer *pgpSigner) signFile(fileName string) (string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+
+	signature := &bytes.Buffer{}
+	err = openpgp.ArmoredDetachSign(signature, signer.key, file, nil)
+	_ = file.Close()
+	if err != nil {
+		return "", err
+	}
+
+	if signer.publicKeys != nil {
+		err = signer.verifyFile(fileName, signature.Bytes())
+		if err != nil {
+			return "", fmt.Errorf("Signature verification failed: %v", err)
+		}
+	}
+
+	signatureName := signer.signatureName(fileName)
+	return signatureName, ioutil.WriteFile(signatureName, signature.Bytes(), 0644)
+}
+
+func (signer *pgpSigner) signatureName(fileName string) string {
+	return fmt.Sprintf("%s.asc", fileName)
+}
+
+func (signer *pgpSigner) verifyFile(fileName string, signature []byte) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return verifyPgpSignature(signer.publicKeys, file, signature)
+}
+
+// readPgpKeyRing reads the keys from an armored or binary key file
+func readPgpKeyRing(data []byte) (openpgp.EntityList, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN ")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	}
+
+	return openpgp.ReadKeyRing(bytes.NewReader(data))
+}
+
+// readPgpPrivateKey reads the first secret key from a key file and decrypts it with the passphrase if it is protected
+func readPgpPrivateKey(data []byte, passphrase string) (*openpgp.Entity, error) {
+	entities, err := readPgpKeyRing(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entity := range entities {
+		if entity.PrivateKey == nil {
+			continue
+		}
+
+		if entity.PrivateKey.Encrypted && entity.PrivateKey.Decrypt([]byte(passphrase)) != nil {
+			return nil, errors.New("wrong passphrase")
+		}
+
+		return entity, nil
+	}
+
+	return nil, errors.New("no secret key found")
+}
+
+// verifyPgpSignature checks an armored detached signature of the data against any of the keys
+func verifyPgpSignature(keys openpgp.EntityList, data io.Reader, signature []byte) error {
+	_, err := openpgp.CheckArmoredDetachedSignature(keys, data, bytes.NewReader(signature))
+	if err == pgpErrors.ErrUnknownIssuer {
+		return errors.New("signature was not made by the public key")
+	}
+
+	return err
+}
@@ -0,0 +1,220 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/urfave/cli"
+)
+
+// The stages that each target goes through in a release
+const (
+	stageBuild   = "build"
+	stagePackage = "package"
+	stageUpload  = "upload"
+	// stageReproduce is comparing a rebuilt archive with the release, in place of the upload
+	stageReproduce = "reproduce"
+)
+
+var stages = []string{stageBuild, stagePackage, stageUpload, stageReproduce}
+
+// checksumsTarget is the row in the summary for the assets that do not belong to a target, the checksums file and
+// its signatures
+const checksumsTarget = "checksums"
+
+// releaseResults collects how each stage went for every target so that a summary can be printed and the command can
+// fail when a target did not make it into the release.  Failures are reported to errWriter as they happen.
+type releaseResults struct {
+	errWriter io.Writer
+	mutex     sync.Mutex
+	targets   []string
+	stages    map[string]map[string]bool
+}
+
+func newReleaseResults(errWriter io.Writer, targets []buildTarget) *releaseResults {
+	results := &releaseResults{errWriter: errWriter, stages: make(map[string]map[string]bool)}
+	for _, target := range targets {
+		results.add(target.String())
+	}
+
+	return results
+}
+
+func (results *releaseResults) add(target string) map[string]bool {
+	targetStages, ok := results.stages[target]
+	if !ok {
+		targetStages = make(map[string]bool)
+		results.stages[target] = targetStages
+		results.targets = append(results.targets, target)
+	}
+
+	return targetStages
+}
+
+// succeeded records that a stage worked for a target unless it has already failed
+func (results *releaseResults) succeeded(target, stage string) {
+	results.mutex.Lock()
+	defer results.mutex.Unlock()
+	targetStages := results.add(target)
+	if _, ok := targetStages[stage]; !ok {
+		targetStages[stage] = true
+	}
+}
+
+// failed records that a stage did not work for a target and reports why
+func (results *releaseResults) failed(target, stage, format string, args ...interface{}) {
+	results.mutex.Lock()
+	defer results.mutex.Unlock()
+	results.add(target)[stage] = false
+	fmt.Fprintf(results.errWriter, format, args...)
+}
+
+// assetTarget is the summary row for an asset
+func assetTarget(asset artifact) string {
+	if asset.OS == "" {
+		return checksumsTarget
+	}
+
+	return fmt.Sprintf("%s/%s", asset.OS, asset.Arch)
+}
+
+func (results *releaseResults) failedTargets() []string {
+	failed := []string{}
+	for _, target := range results.targets {
+		for _, ok := range results.stages[target] {
+			if !ok {
+				failed = append(failed, target)
+				break
+			}
+		}
+	}
+
+	sort.Strings(failed)
+	return failed
+}
+
+// writeSummary prints a table with the outcome of each stage that ran for every target
+func (results *releaseResults) writeSummary(writer io.Writer) {
+	results.mutex.Lock()
+	defer results.mutex.Unlock()
+	columns := []string{}
+	for _, stage := range stages {
+		for _, targetStages := range results.stages {
+			if _, ok := targetStages[stage]; ok {
+				columns = append(columns, stage)
+				break
+			}
+		}
+	}
+
+	targets := append([]string{}, results.targets...)
+	sort.Strings(targets)
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(table, "TARGET\t%s\n", strings.ToUpper(strings.Join(columns, "\t")))
+	for _, target := range targets {
+		row := []string{target}
+		for _, stage := range columns {
+			row = append(row, results.status(target, stage))
+		}
+
+		fmt.Fprintln(table, strings.Join(row, "\t"))
+	}
+
+	_ = table.Flush()
+}
+
+// status is how a stage went for a target.  Stages after a failure are skipped.
+func (results *releaseResults) status(target, stage string) string {
+	targetStages := results.stages[target]
+	ok, ran := targetStages[stage]
+	if ran && ok {
+		return "ok"
+	}
+
+	if ran {
+		return "failed"
+	}
+
+	for _, previous := range stages {
+		if previous == stage {
+			break
+		}
+
+		if ok, ran := targetStages[previous]; ran && !ok {
+			return "skipped"
+		}
+	}
+
+	return "-"
+}
+
+// err fails the command when a target failed.  With allowPartial, or a list of required targets, only the required
+// targets and the checksums have to succeed, as long as at least one target made it.
+func (results *releaseResults) err(allowPartial bool, requiredTargets []string) error {
+	results.mutex.Lock()
+	defer results.mutex.Unlock()
+	failed := results.failedTargets()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	fatal := failed
+	if allowPartial || len(requiredTargets) != 0 {
+		fatal = []string{}
+		for _, target := range failed {
+			if target == checksumsTarget || contains(requiredTargets, target) {
+				fatal = append(fatal, target)
+			}
+		}
+
+		if len(fatal) == 0 && len(failed) < results.buildTargets() {
+			return nil
+		}
+
+		if len(fatal) == 0 {
+			fatal = failed
+		}
+	}
+
+	return cli.NewExitError(fmt.Sprintf("Failed targets: %s", strings.Join(fatal, ", ")), 1)
+}
+
+// buildTargets counts the rows for targets that were built, leaving out the checksums
+func (results *releaseResults) buildTargets() int {
+	count := 0
+	for _, target := range results.targets {
+		if target != checksumsTarget {
+			count++
+		}
+	}
+
+	return count
+}
+
+// partialOptions reads which failed targets are tolerated from the flags and config
+func partialOptions(c *cli.Context, cfg *config) (bool, []string) {
+	return c.Bool("allowPartial") || cfg.Release.AllowPartial, sliceOption(c.StringSlice("requiredTargets"), cfg.Release.RequiredTargets)
+}
+
+// checkRequiredTargets makes sure that every required target is going to be built
+func checkRequiredTargets(requiredTargets []string, targets []buildTarget) error {
+	for _, required := range requiredTargets {
+		found := false
+		for _, target := range targets {
+			if target.String() == required {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return cli.NewExitError(fmt.Sprintf("Required target %s is not being built", required), 1)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package command_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/guywithnose/goRelease/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestReleaseSummary(t *testing.T) {
+	ts := getReleaseTestServer(t, "", "")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := getSummaryFlagSet(t, ts.URL, mainPath)
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/386, linux/amd64]\n")
+	expectedRunner := getSummaryRunner(t, mainPath)
+	app, writer, errWriter := appWithTestWriters()
+	err := command.CmdRelease(expectedRunner)(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Failed targets: linux/386")
+	assert.Equal(t, []*runner.ExpectedCommand{}, expectedRunner.ExpectedCommands)
+	assert.Equal(t, "Could not run build for linux/386: exit status 2\nOutput: Build error\n", errWriter.String())
+	assert.Regexp(
+		t,
+		"^\\[1\\] Uploaded projectName-linux-amd64-go1.8-tag.tar.gz \\(145 B in [^,]+, [^)]+/s\\)\n"+
+			"\\[2\\] Uploaded projectName_tag_checksums.txt \\(107 B in [^,]+, [^)]+/s\\)\n"+
+			"\\[3\\] Uploaded digests.json \\([0-9]+ B in [^,]+, [^)]+/s\\)\n"+
+			"TARGET       BUILD   PACKAGE  UPLOAD\n"+
+			"checksums    -       -        ok\n"+
+			"linux/386    failed  skipped  skipped\n"+
+			"linux/amd64  ok      ok       ok\n$",
+		writer.String(),
+	)
+}
+
+func TestReleasePartialFailures(t *testing.T) {
+	ts := getReleaseTestServer(t, "", "")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	testCases := []struct {
+		allowPartial    bool
+		requiredTargets []string
+		config          string
+		expectedError   string
+	}{
+		{true, nil, "", ""},
+		{false, []string{"linux/amd64"}, "", ""},
+		{true, []string{"linux/386"}, "", "Failed targets: linux/386"},
+		{false, nil, "release:\n  allowPartial: true\n", ""},
+		{false, nil, "release:\n  requiredTargets: [linux/386]\n", "Failed targets: linux/386"},
+	}
+	for _, testCase := range testCases {
+		set := getSummaryFlagSet(t, ts.URL, mainPath)
+		set.Bool("allowPartial", testCase.allowPartial, "doc")
+		requiredTargets := cli.StringSlice(testCase.requiredTargets)
+		set.Var(&requiredTargets, "requiredTargets", "doc")
+		assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+		createFiles(t, mainPath, "tag")
+		writeConfig(t, mainPath, fmt.Sprintf("builds:\n  targets: [linux/386, linux/amd64]\n%s", testCase.config))
+		app, _, _ := appWithTestWriters()
+		err := command.CmdRelease(getSummaryRunner(t, mainPath))(cli.NewContext(app, set, nil))
+		if testCase.expectedError == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.EqualError(t, err, testCase.expectedError)
+		}
+
+		cleanUp(t, mainPath)
+	}
+}
+
+func TestReleaseEveryTargetFailed(t *testing.T) {
+	ts := getReleaseTestServer(t, "", "")
+	defer ts.Close()
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := getSummaryFlagSet(t, ts.URL, mainPath)
+	set.Bool("allowPartial", true, "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/386]\n")
+	app, writer, _ := appWithTestWriters()
+	err := command.CmdRelease(getSummaryRunner(t, mainPath))(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Failed targets: linux/386")
+	assert.Equal(t, "TARGET     BUILD\nlinux/386  failed\n", writer.String())
+}
+
+func TestReleaseEveryTargetFailedAfterChecksums(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	ts := getReleaseTestServer(t, "/repos/owner/repo/releases/1/assets?name=projectName-linux-amd64-go1.8-tag.tar.gz.sha256", "POST")
+	defer ts.Close()
+	set := getSummaryFlagSet(t, ts.URL, mainPath)
+	set.Bool("allowPartial", true, "doc")
+	set.Bool("checksumSidecars", true, "doc")
+	set.Int("maxAttempts", 1, "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	defer cleanUp(t, mainPath)
+	createFiles(t, mainPath, "tag")
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/amd64]\n")
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return operatingSystem == "linux" && architecture == "amd64"
+	})
+	app, writer, _ := appWithTestWriters()
+	err := command.CmdRelease(&runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true})(cli.NewContext(app, set, nil))
+
+	// The checksums were uploaded, but that does not count as a target that made it
+	assert.EqualError(t, err, "Failed targets: linux/amd64")
+	assert.True(t, strings.HasSuffix(writer.String(), "TARGET       BUILD  PACKAGE  UPLOAD\nchecksums    -      -        ok\nlinux/amd64  ok     ok       failed\n"))
+}
+
+func TestReleaseRequiredTargetNotBuilt(t *testing.T) {
+	mainPath := fmt.Sprintf("%s/build", os.TempDir())
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("mainPath", mainPath, "doc")
+	requiredTargets := cli.StringSlice{"windows/arm64"}
+	set.Var(&requiredTargets, "requiredTargets", "doc")
+	assert.Nil(t, set.Parse([]string{"owner", "repo", "tag", "projectName"}))
+	defer cleanUp(t, mainPath)
+	writeConfig(t, mainPath, "builds:\n  targets: [linux/386, linux/amd64]\n")
+	app, _, _ := appWithTestWriters()
+	err := command.CmdRelease(getSummaryRunner(t, mainPath))(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Required target windows/arm64 is not being built")
+}
+
+func getSummaryFlagSet(t *testing.T, url, mainPath string) *flag.FlagSet {
+	t.Helper()
+	set := flag.NewFlagSet("test", 0)
+	set.String("token", "fakeToken", "doc")
+	set.String("apiUrl", fmt.Sprintf("%s/", url), "doc")
+	set.String("mainPath", mainPath, "doc")
+	return set
+}
+
+// getSummaryRunner builds linux/amd64 and fails to build linux/386
+func getSummaryRunner(t *testing.T, mainPath string) *runner.Test {
+	t.Helper()
+	goExecutable, err := exec.LookPath("go")
+	assert.Nil(t, err)
+	expectedCommands := getReleaseCommandsFiltered(t, mainPath, func(operatingSystem, architecture string) bool {
+		return operatingSystem == "linux" && architecture == "amd64"
+	})
+	expectedCommands = append(
+		expectedCommands,
+		runner.NewExpectedCommand(
+			mainPath,
+			fmt.Sprintf("%s build -o %s", goExecutable, distFile(mainPath, "linux", "386", "projectName-linux-386-go1.8-tag")),
+			"Build error",
+			2,
+		).WithEnvironment([]string{"GOOS=linux", "GOARCH=386", fmt.Sprintf("GOPATH=%s", os.Getenv("GOPATH"))}),
+	)
+	return &runner.Test{ExpectedCommands: expectedCommands, AnyOrder: true}
+}
@@ -0,0 +1,123 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type targetFilter struct {
+	OperatingSystems []string
+	Architectures    []string
+	Include          map[string]bool
+	Exclude          map[string]bool
+}
+
+// newTargetFilter validates the requested oses, architectures and os/arch targets against the known builds
+func newTargetFilter(builds []osBuildInfo, operatingSystems, architectures, targets []string) (*targetFilter, error) {
+	knownOses := make(map[string]bool)
+	knownArchitectures := make(map[string]bool)
+	knownTargets := make(map[string]bool)
+	for _, build := range builds {
+		knownOses[build.OperatingSystem] = true
+		for _, architecture := range build.Architectures {
+			knownArchitectures[architecture] = true
+			knownTargets[targetName(build.OperatingSystem, architecture)] = true
+		}
+	}
+
+	for _, operatingSystem := range operatingSystems {
+		if !knownOses[operatingSystem] {
+			return nil, fmt.Errorf("Unknown OS: %s", operatingSystem)
+		}
+	}
+
+	for _, architecture := range architectures {
+		if !knownArchitectures[architecture] {
+			return nil, fmt.Errorf("Unknown architecture: %s", architecture)
+		}
+	}
+
+	filter := &targetFilter{
+		OperatingSystems: operatingSystems,
+		Architectures:    architectures,
+		Include:          make(map[string]bool),
+		Exclude:          make(map[string]bool),
+	}
+	for _, target := range targets {
+		name := strings.TrimPrefix(target, "!")
+		parts := strings.Split(name, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("Invalid target %s, expected os/arch", target)
+		}
+
+		if !knownTargets[name] {
+			return nil, fmt.Errorf("Unknown target: %s", name)
+		}
+
+		if strings.HasPrefix(target, "!") {
+			filter.Exclude[name] = true
+		} else {
+			filter.Include[name] = true
+		}
+	}
+
+	return filter, nil
+}
+
+func (filter *targetFilter) matches(operatingSystem, architecture string) bool {
+	name := targetName(operatingSystem, architecture)
+	if filter.Exclude[name] {
+		return false
+	}
+
+	if len(filter.Include) != 0 && !filter.Include[name] {
+		return false
+	}
+
+	return (len(filter.OperatingSystems) == 0 || contains(filter.OperatingSystems, operatingSystem)) &&
+		(len(filter.Architectures) == 0 || contains(filter.Architectures, architecture))
+}
+
+// filterBuilds returns the builds that should be created after applying the --os, --arch and --target flags
+func filterBuilds(builds []osBuildInfo, operatingSystems, architectures, targets []string) ([]osBuildInfo, error) {
+	filter, err := newTargetFilter(builds, operatingSystems, architectures, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	filteredBuilds := make([]osBuildInfo, 0, len(builds))
+	for _, build := range builds {
+		filteredArchitectures := make([]string, 0, len(build.Architectures))
+		for _, architecture := range build.Architectures {
+			if filter.matches(build.OperatingSystem, architecture) {
+				filteredArchitectures = append(filteredArchitectures, architecture)
+			}
+		}
+
+		if len(filteredArchitectures) != 0 {
+			build.Architectures = filteredArchitectures
+			filteredBuilds = append(filteredBuilds, build)
+		}
+	}
+
+	if len(filteredBuilds) == 0 {
+		return nil, errors.New("No builds match the requested targets")
+	}
+
+	return filteredBuilds, nil
+}
+
+func targetName(operatingSystem, architecture string) string {
+	return fmt.Sprintf("%s/%s", operatingSystem, architecture)
+}
+
+func contains(haystack []string, needle string) bool {
+	for _, value := range haystack {
+		if value == needle {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,70 +1,84 @@
 package command
 
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/guywithnose/runner"
+)
+
 type osBuildInfo struct {
-	OperatingSystem        string
-	Architectures          []string
-	CompressBinary         string
-	IncludeTargetParameter bool
-	CompressExtension      string
-	Extension              string
+	OperatingSystem string
+	Architectures   []string
+	ArchiveFormat   string
+	Extension       string
 }
 
-// ValidBuilds defines the builds that should be created and uploaded
-var ValidBuilds = []osBuildInfo{
-	{
-		OperatingSystem:   "linux",
-		Architectures:     []string{"386", "amd64", "arm", "arm64", "mips", "mips64", "mips64le", "mipsle", "ppc64", "ppc64le", "s390x"},
-		CompressBinary:    "gzip",
-		CompressExtension: ".gz",
-	},
-	{
-		OperatingSystem:   "darwin",
-		Architectures:     []string{"386", "amd64", "arm", "arm64"},
-		CompressBinary:    "gzip",
-		CompressExtension: ".gz",
-	},
-	{
-		OperatingSystem:   "nacl",
-		Architectures:     []string{"386", "amd64p32", "arm"},
-		CompressBinary:    "gzip",
-		CompressExtension: ".gz",
-	},
-	{
-		OperatingSystem:   "netbsd",
-		Architectures:     []string{"386", "amd64", "arm"},
-		CompressBinary:    "gzip",
-		CompressExtension: ".gz",
-	},
-	{
-		OperatingSystem:   "openbsd",
-		Architectures:     []string{"386", "amd64", "arm"},
-		CompressBinary:    "gzip",
-		CompressExtension: ".gz",
-	},
-	{
-		OperatingSystem:   "plan9",
-		Architectures:     []string{"386", "amd64", "arm"},
-		CompressBinary:    "gzip",
-		CompressExtension: ".gz",
-	},
-	{
-		OperatingSystem:   "solaris",
-		Architectures:     []string{"amd64"},
-		CompressBinary:    "gzip",
-		CompressExtension: ".gz",
+type distTarget struct {
+	OperatingSystem string `json:"GOOS"`
+	Architecture    string `json:"GOARCH"`
+	CgoSupported    bool   `json:"CgoSupported"`
+	FirstClass      bool   `json:"FirstClass"`
+}
+
+// defaultPackaging defines how binaries are packaged for any OS that is not listed in osPackaging
+var defaultPackaging = osBuildInfo{
+	ArchiveFormat: "tar.gz",
+}
+
+// osPackaging defines how binaries are packaged for OSes that differ from defaultPackaging
+var osPackaging = map[string]osBuildInfo{
+	"windows": {
+		ArchiveFormat: "zip",
+		Extension:     ".exe",
 	},
-	{
-		OperatingSystem:   "dragonfly",
-		Architectures:     []string{"amd64"},
-		CompressBinary:    "gzip",
-		CompressExtension: ".gz",
+	"js": {
+		ArchiveFormat: "tar.gz",
+		Extension:     ".wasm",
 	},
-	{
-		OperatingSystem:        "windows",
-		Architectures:          []string{"386", "amd64"},
-		CompressBinary:         "zip",
-		IncludeTargetParameter: true,
-		CompressExtension:      ".zip",
-		Extension:              ".exe",
+	"wasip1": {
+		ArchiveFormat: "tar.gz",
+		Extension:     ".wasm",
 	},
 }
+
+// discoverBuilds asks the go toolchain which targets it supports and merges them with the packaging defaults for each OS
+func discoverBuilds(cmdWrapper runner.Builder, mainPath, goExecutable string, firstClassOnly bool) ([]osBuildInfo, error) {
+	cmd := cmdWrapper.New(mainPath, goExecutable, "tool", "dist", "list", "-json")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list supported targets: %v", err)
+	}
+
+	var targets []distTarget
+	err = json.Unmarshal(output, &targets)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse supported targets: %v", err)
+	}
+
+	builds := make([]osBuildInfo, 0, len(targets))
+	buildIndexes := make(map[string]int)
+	for _, target := range targets {
+		if firstClassOnly && !target.FirstClass {
+			continue
+		}
+
+		index, ok := buildIndexes[target.OperatingSystem]
+		if !ok {
+			build, ok := osPackaging[target.OperatingSystem]
+			if !ok {
+				build = defaultPackaging
+			}
+
+			build.OperatingSystem = target.OperatingSystem
+			build.Architectures = nil
+			index = len(builds)
+			buildIndexes[target.OperatingSystem] = index
+			builds = append(builds, build)
+		}
+
+		builds[index].Architectures = append(builds[index].Architectures, target.Architecture)
+	}
+
+	return builds, nil
+}
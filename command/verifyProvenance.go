@@ -0,0 +1,76 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	"github.com/urfave/cli"
+)
+
+// VerifyProvenanceFlags are the valid verify-provenance parameters
+var VerifyProvenanceFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "publicKey, p",
+		Usage: "The PEM public key that the attestation must be signed with",
+	},
+	cli.StringFlag{
+		Name:  "attestation, a",
+		Usage: "The attestation to check the artifact against (default: {artifact}.intoto.jsonl)",
+	},
+}
+
+// CmdVerifyProvenance checks an artifact against its provenance attestation without contacting github
+func CmdVerifyProvenance(c *cli.Context) error {
+	if c.NArg() != 1 || c.String("publicKey") == "" {
+		return cli.NewExitError("Usage: \"goRelease verify-provenance --publicKey {publicKey} [--attestation {attestation}] {artifact}\"", 1)
+	}
+
+	artifact := c.Args().Get(0)
+	attestation := stringOption(c.String("attestation"), fmt.Sprintf("%s.intoto.jsonl", artifact))
+	publicKey, err := loadProvenancePublicKey(c.String("publicKey"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	data, err := ioutil.ReadFile(attestation)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Unable to read attestation: %v", err), 1)
+	}
+
+	statement, err := verifyAttestation(data, publicKey)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Invalid attestation %s: %v", attestation, err), 1)
+	}
+
+	digest, err := fileSHA256(artifact)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Unable to read artifact: %v", err), 1)
+	}
+
+	for _, subject := range statement.Subject {
+		if subject.Digest["sha256"] != digest {
+			continue
+		}
+
+		parameters := statement.Predicate.BuildDefinition.ExternalParameters
+		commit := ""
+		for _, dependency := range statement.Predicate.BuildDefinition.ResolvedDependencies {
+			commit = stringOption(commit, dependency.Digest["gitCommit"])
+		}
+
+		fmt.Fprintf(
+			c.App.Writer,
+			"Verified %s as %s for %s, built from %s %s (commit %s)\n",
+			path.Base(artifact),
+			subject.Name,
+			parameters.Target,
+			parameters.Repository,
+			parameters.Ref,
+			commit,
+		)
+		return nil
+	}
+
+	return cli.NewExitError(fmt.Sprintf("%s (sha256 %s) is not a subject of %s", artifact, digest, attestation), 1)
+}
@@ -0,0 +1,121 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlErrorPattern matches the line yaml.v3 puts at the start of syntax and type errors
+var yamlErrorPattern = regexp.MustCompile(`^(?:yaml: )?line ([0-9]+): (.*)$`)
+
+type yamlError struct {
+	line    int
+	message string
+}
+
+func (err *yamlError) Error() string {
+	return fmt.Sprintf("line %d: %s", err.line, err.message)
+}
+
+// decodeYaml strictly decodes a document into target, so unknown fields and duplicate keys are errors, and returns
+// the line of every field and list item in it
+func decodeYaml(data []byte, target interface{}) (map[string]int, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(target)
+	if err == io.EOF {
+		return make(map[string]int), nil
+	}
+
+	if err != nil {
+		return nil, yamlLineError(err)
+	}
+
+	document := &yaml.Node{}
+	err = yaml.Unmarshal(data, document)
+	if err != nil {
+		return nil, yamlLineError(err)
+	}
+
+	lines := make(map[string]int)
+	if len(document.Content) != 0 {
+		yamlLines(document.Content[0], "", lines)
+	}
+
+	return lines, nil
+}
+
+// yamlLineError pulls the line out of the first error yaml.v3 reports
+func yamlLineError(err error) error {
+	message := err.Error()
+	if typeErr, ok := err.(*yaml.TypeError); ok && len(typeErr.Errors) != 0 {
+		message = typeErr.Errors[0]
+	}
+
+	if match := yamlErrorPattern.FindStringSubmatch(message); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return &yamlError{line, match[2]}
+	}
+
+	return err
+}
+
+// yamlLines records the line of every key and list item under node.  Keys merged in with << do not override the ones
+// in the mapping itself.
+func yamlLines(node *yaml.Node, path string, lines map[string]int) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for index, item := range node.Content {
+			itemPath := fmt.Sprintf("%s[%d]", path, index)
+			lines[itemPath] = item.Line
+			yamlLines(item, itemPath, lines)
+		}
+	case yaml.MappingNode:
+		merged := []*yaml.Node{}
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			key, value := node.Content[index], node.Content[index+1]
+			if key.Tag == "!!merge" {
+				merged = append(merged, value)
+				continue
+			}
+
+			keyPath := joinYamlPath(path, key.Value)
+			lines[keyPath] = key.Line
+			yamlLines(value, keyPath, lines)
+		}
+
+		for _, value := range merged {
+			sources := []*yaml.Node{value}
+			if value.Kind == yaml.SequenceNode {
+				sources = value.Content
+			}
+
+			for _, source := range sources {
+				mergedLines := make(map[string]int)
+				yamlLines(source, path, mergedLines)
+				for mergedPath, line := range mergedLines {
+					if _, ok := lines[mergedPath]; !ok {
+						lines[mergedPath] = line
+					}
+				}
+			}
+		}
+	}
+}
+
+func joinYamlPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return fmt.Sprintf("%s.%s", path, key)
+}
@@ -25,6 +25,66 @@ func main() {
 
 	app.Flags = command.Flags
 	app.Action = command.CmdRelease(runner.Real{})
+	app.Commands = []cli.Command{
+		{
+			Name:         "build",
+			Usage:        "Build the binaries for a release into the dist directory",
+			ArgsUsage:    "{owner} {repo} {tagName} {projectName}",
+			Flags:        command.BuildFlags,
+			Action:       command.CmdBuild(runner.Real{}),
+			BashComplete: command.Completion,
+		},
+		{
+			Name:         "package",
+			Usage:        "Archive, checksum and sign the binaries in the dist directory",
+			Flags:        command.PackageFlags,
+			Action:       command.CmdPackage(runner.Real{}),
+			BashComplete: command.Completion,
+		},
+		{
+			Name:         "publish",
+			Usage:        "Upload the packaged dist directory to a github release",
+			Flags:        command.PublishFlags,
+			Action:       command.CmdPublish(runner.Real{}),
+			BashComplete: command.Completion,
+		},
+		{
+			Name:         "release",
+			Usage:        "Build, package and publish a release in one step",
+			ArgsUsage:    "{owner} {repo} {tagName} {projectName}",
+			Flags:        command.Flags,
+			Action:       command.CmdRelease(runner.Real{}),
+			BashComplete: command.Completion,
+		},
+		{
+			Name:         "reproduce",
+			Usage:        "Rebuild a tag and check that the archives match the checksums file of its release",
+			ArgsUsage:    "{owner} {repo} {tagName} {projectName}",
+			Flags:        command.ReproduceFlags,
+			Action:       command.CmdReproduce(runner.Real{}),
+			BashComplete: command.Completion,
+		},
+		{
+			Name:         "next-version",
+			Usage:        "Print the version after the last tag, going by the Conventional Commits since it",
+			Flags:        command.NextVersionFlags,
+			Action:       command.CmdNextVersion(runner.Real{}),
+			BashComplete: command.Completion,
+		},
+		{
+			Name:   "keygen",
+			Usage:  "Create a minisign key pair for signing releases",
+			Flags:  command.KeygenFlags,
+			Action: command.CmdKeygen,
+		},
+		{
+			Name:      "verify-provenance",
+			Usage:     "Check an artifact against its provenance attestation",
+			ArgsUsage: "{artifact}",
+			Flags:     command.VerifyProvenanceFlags,
+			Action:    command.CmdVerifyProvenance,
+		},
+	}
 	app.EnableBashCompletion = true
 	app.BashComplete = command.Completion
 	app.ErrWriter = os.Stderr